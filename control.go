@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/drj613/metrognome/internal/control"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// controlFlags are the flags of commands that take orders over the control
+// socket
+type controlFlags struct {
+	socket string
+}
+
+// addControlFlags registers the control socket flag on a flag set
+func addControlFlags(fs *flag.FlagSet) *controlFlags {
+	c := &controlFlags{}
+	fs.StringVar(&c.socket, "socket", control.SocketPath(), "Unix socket to take 'metrognome ctl' commands on, empty for none")
+	return c
+}
+
+// start listens for commands on the socket, returning a function that stops
+func (c *controlFlags) start(metro *metronome.Metronome, onChange func(string)) (func(), error) {
+	if c.socket == "" {
+		return func() {}, nil
+	}
+	l, err := control.Listen(c.socket)
+	if err != nil {
+		return nil, err
+	}
+	srv := control.NewServer(metro)
+	srv.OnChange = onChange
+	go srv.Serve(l)
+	return func() { l.Close() }, nil
+}
+
+// runCtl sends a command to a running metronome and prints the reply
+func runCtl(cmd *command, args []string) int {
+	fs := cmd.flags()
+	socket := fs.String("socket", control.SocketPath(), "Unix socket of the metronome to control")
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return cmd.usageError(errors.New("no command given, e.g. 'metrognome ctl bpm 120'"))
+	}
+
+	reply, err := control.Send(*socket, strings.Join(fs.Args(), " "))
+	if err != nil {
+		return cmd.fail(err)
+	}
+	fmt.Println(reply)
+	return exitOK
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/hooks"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// startHooks fires the hooks in the config as the metronome plays,
+// returning a function that stops
+func startHooks(metro *metronome.Metronome, onError func(error)) (func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	runner := hooks.New(metro, cfg.Hooks)
+	if runner.Empty() {
+		return func() {}, nil
+	}
+	runner.OnError = onError
+
+	// Subscribe before playback starts so the first beat isn't missed
+	beats, unsubscribe := metro.Subscribe()
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		runner.Run(beats, stop)
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}, nil
+}
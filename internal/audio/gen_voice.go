@@ -0,0 +1,285 @@
+//go:build ignore
+
+// gen_voice renders the spoken count samples in voice/ with a small formant
+// synthesizer, so the bundled voice needs no text-to-speech tools or network
+// access to rebuild. Run it with "go generate ./internal/audio".
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rate is the sample rate of the generated files
+const rate = 16000
+
+// phoneme describes the acoustic targets for one speech sound
+type phoneme struct {
+	f1, f2, f3 float64 // Formant frequencies in Hz
+	voice      float64 // Voicing amplitude
+	noise      float64 // Frication amplitude
+	noiseFreq  float64 // Center of the frication noise
+	ms         float64 // Duration
+	closure    bool    // Silent stop closure followed by a burst
+}
+
+// phonemes is a tiny ARPAbet-style inventory covering the count words
+var phonemes = map[string]phoneme{
+	// Vowels
+	"iy": {f1: 270, f2: 2290, f3: 3010, voice: 1, ms: 150},
+	"ih": {f1: 390, f2: 1990, f3: 2550, voice: 1, ms: 90},
+	"eh": {f1: 530, f2: 1840, f3: 2480, voice: 1, ms: 110},
+	"ae": {f1: 660, f2: 1720, f3: 2410, voice: 1, ms: 150},
+	"aa": {f1: 730, f2: 1090, f3: 2440, voice: 1, ms: 110},
+	"ao": {f1: 570, f2: 840, f3: 2410, voice: 1, ms: 160},
+	"uw": {f1: 300, f2: 870, f3: 2240, voice: 1, ms: 170},
+	"ah": {f1: 640, f2: 1190, f3: 2390, voice: 1, ms: 130},
+	"ax": {f1: 500, f2: 1500, f3: 2500, voice: 0.9, ms: 70},
+	"er": {f1: 490, f2: 1350, f3: 1690, voice: 1, ms: 140},
+
+	// Approximants and nasals
+	"w": {f1: 290, f2: 610, f3: 2150, voice: 0.7, ms: 60},
+	"r": {f1: 310, f2: 1060, f3: 1380, voice: 0.7, ms: 60},
+	"l": {f1: 310, f2: 1050, f3: 2880, voice: 0.7, ms: 60},
+	"n": {f1: 250, f2: 1700, f3: 2600, voice: 0.45, ms: 80},
+
+	// Fricatives
+	"s":  {f1: 320, f2: 1400, f3: 2700, noise: 0.5, noiseFreq: 5500, ms: 110},
+	"f":  {f1: 340, f2: 1100, f3: 2080, noise: 0.15, noiseFreq: 4500, ms: 100},
+	"th": {f1: 320, f2: 1290, f3: 2540, noise: 0.12, noiseFreq: 4000, ms: 90},
+	"v":  {f1: 220, f2: 1100, f3: 2080, voice: 0.4, noise: 0.08, noiseFreq: 4000, ms: 60},
+
+	// Stops
+	"t": {f1: 200, f2: 1700, f3: 2600, noise: 0.45, noiseFreq: 4200, ms: 70, closure: true},
+	"d": {f1: 200, f2: 1700, f3: 2600, voice: 0.2, noise: 0.25, noiseFreq: 3500, ms: 50, closure: true},
+	"k": {f1: 300, f2: 1990, f3: 2850, noise: 0.4, noiseFreq: 2500, ms: 70, closure: true},
+}
+
+// diphthongs glide between two vowels
+var diphthongs = map[string][2]string{
+	"ay": {"aa", "iy"},
+	"ey": {"eh", "iy"},
+}
+
+// words maps each sample name to its pronunciation
+var words = map[string]string{
+	"1":   "w ah n",
+	"2":   "t uw",
+	"3":   "th r iy",
+	"4":   "f ao r",
+	"5":   "f ay v",
+	"6":   "s ih k s",
+	"7":   "s eh v ax n",
+	"8":   "ey t",
+	"9":   "n ay n",
+	"10":  "t eh n",
+	"11":  "ih l eh v ax n",
+	"12":  "t w eh l v",
+	"13":  "th er t iy n",
+	"14":  "f ao r t iy n",
+	"15":  "f ih f t iy n",
+	"16":  "s ih k s t iy n",
+	"and": "ae n d",
+	"e":   "iy",
+	"a":   "ax",
+}
+
+// frame is the synthesizer state at a point in time
+type frame struct {
+	f1, f2, f3, voice, noise, noiseFreq float64
+}
+
+// segment is a phoneme placed on the timeline
+type segment struct {
+	start, end float64
+	from, to   frame
+	closure    bool
+}
+
+// expand turns a pronunciation into timed segments
+func expand(pron string) []segment {
+	var segs []segment
+	t := 0.0
+	for _, name := range strings.Fields(pron) {
+		if d, ok := diphthongs[name]; ok {
+			a, b := phonemes[d[0]], phonemes[d[1]]
+			dur := (a.ms + b.ms) / 1000 * 0.8
+			segs = append(segs, segment{start: t, end: t + dur, from: toFrame(a), to: toFrame(b)})
+			t += dur
+			continue
+		}
+		p, ok := phonemes[name]
+		if !ok {
+			log.Fatalf("unknown phoneme %q", name)
+		}
+		dur := p.ms / 1000
+		segs = append(segs, segment{start: t, end: t + dur, from: toFrame(p), to: toFrame(p), closure: p.closure})
+		t += dur
+	}
+	return segs
+}
+
+func toFrame(p phoneme) frame {
+	return frame{p.f1, p.f2, p.f3, p.voice, p.noise, p.noiseFreq}
+}
+
+// lerp blends two frames
+func lerp(a, b frame, x float64) frame {
+	mix := func(p, q float64) float64 { return p + (q-p)*x }
+	return frame{
+		mix(a.f1, b.f1), mix(a.f2, b.f2), mix(a.f3, b.f3),
+		mix(a.voice, b.voice), mix(a.noise, b.noise), mix(a.noiseFreq, b.noiseFreq),
+	}
+}
+
+// frameAt returns the synthesizer targets at time t, smoothing formant
+// transitions across segment boundaries
+func frameAt(segs []segment, t float64) (frame, bool) {
+	const transition = 0.03
+	for i, s := range segs {
+		if t >= s.end && i < len(segs)-1 {
+			continue
+		}
+		x := (t - s.start) / (s.end - s.start)
+		f := lerp(s.from, s.to, math.Max(0, math.Min(1, x)))
+
+		if s.closure {
+			// Silence for the first two thirds, then the burst
+			if x < 0.6 {
+				f.voice *= 0.1
+				f.noise = 0
+			} else {
+				f.noise *= 1.6 * (1 - x)
+			}
+			return f, true
+		}
+
+		// Glide formants in from the previous segment
+		if i > 0 && t-s.start < transition {
+			prev := segs[i-1].to
+			k := (t - s.start) / transition
+			f.f1 = prev.f1 + (f.f1-prev.f1)*k
+			f.f2 = prev.f2 + (f.f2-prev.f2)*k
+			f.f3 = prev.f3 + (f.f3-prev.f3)*k
+		}
+		return f, true
+	}
+	return frame{}, false
+}
+
+// resonator is a two-pole band-pass filter
+type resonator struct {
+	y1, y2 float64
+}
+
+func (r *resonator) step(x, freq, bw float64) float64 {
+	c := -math.Exp(-2 * math.Pi * bw / rate)
+	b := 2 * math.Exp(-math.Pi*bw/rate) * math.Cos(2*math.Pi*freq/rate)
+	a := 1 - b - c
+	y := a*x + b*r.y1 + c*r.y2
+	r.y2, r.y1 = r.y1, y
+	return y
+}
+
+// synth renders a pronunciation to samples
+func synth(pron string) []float64 {
+	segs := expand(pron)
+	total := segs[len(segs)-1].end
+	n := int((total + 0.05) * rate)
+
+	var r1, r2, r3, rn resonator
+	rng := rand.New(rand.NewSource(1))
+	out := make([]float64, n)
+	phase := 0.0
+	for i := range out {
+		t := float64(i) / rate
+		f, ok := frameAt(segs, t)
+		if !ok {
+			break
+		}
+
+		// Falling pitch sounds like a statement
+		f0 := 135 - 30*t/total
+		phase += f0 / rate
+		if phase >= 1 {
+			phase--
+		}
+		// Glottal pulse: a rising ramp with a sharp closure
+		glottal := 0.0
+		if phase < 0.6 {
+			glottal = math.Sin(math.Pi * phase / 0.6)
+		}
+		glottal *= f.voice
+
+		v := r1.step(glottal, f.f1, 60)
+		v = r2.step(v, f.f2, 90)
+		v = r3.step(v, f.f3, 150)
+
+		noise := 0.0
+		if f.noise > 0 {
+			noise = rn.step(rng.Float64()*2-1, f.noiseFreq, 1800) * f.noise * 6
+		}
+		out[i] = v*3 + noise
+	}
+
+	// Normalize and fade the edges to avoid clicks
+	peak := 0.0
+	for _, v := range out {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	fade := rate / 200
+	for i := range out {
+		out[i] = out[i] / peak * 0.9
+		if i < fade {
+			out[i] *= float64(i) / float64(fade)
+		}
+		if j := n - 1 - i; j < fade {
+			out[i] *= float64(j) / float64(fade)
+		}
+	}
+	return out
+}
+
+// writeWAV writes 16-bit mono PCM
+func writeWAV(path string, data []float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size := uint32(len(data) * 2)
+	le := binary.LittleEndian
+	header := []any{
+		[]byte("RIFF"), 36 + size, []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(1), uint32(rate), uint32(rate * 2), uint16(2), uint16(16),
+		[]byte("data"), size,
+	}
+	for _, v := range header {
+		if err := binary.Write(f, le, v); err != nil {
+			return err
+		}
+	}
+	for _, v := range data {
+		if err := binary.Write(f, le, int16(v*32767)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := os.MkdirAll("voice", 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for name, pron := range words {
+		if err := writeWAV(filepath.Join("voice", name+".wav"), synth(pron)); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Slot identifies which kind of click a sound is used for
+type Slot int
+
+const (
+	SlotAccent      Slot = iota // First beat of the bar
+	SlotNormal                  // Every other beat
+	SlotSubdivision             // Clicks between beats
+	SlotCountIn                 // Count-in before playback starts
+	numSlots
+)
+
+// AllSlots lists every slot in display order
+var AllSlots = []Slot{SlotAccent, SlotNormal, SlotSubdivision, SlotCountIn}
+
+// slotFiles maps each slot to the file name expected in a kit directory
+var slotFiles = [numSlots]string{
+	SlotAccent:      "accent.wav",
+	SlotNormal:      "normal.wav",
+	SlotSubdivision: "subdivision.wav",
+	SlotCountIn:     "countin.wav",
+}
+
+// String returns the human-readable slot name
+func (s Slot) String() string {
+	switch s {
+	case SlotAccent:
+		return "accent"
+	case SlotNormal:
+		return "normal"
+	case SlotSubdivision:
+		return "subdivision"
+	case SlotCountIn:
+		return "count-in"
+	default:
+		return fmt.Sprintf("slot(%d)", int(s))
+	}
+}
+
+// DefaultKitName is the name of the built-in synthesized kit
+const DefaultKitName = "Gnome Clicks"
+
+// Kit is a set of click sounds, one per slot
+type Kit struct {
+	Name   string
+	Dir    string // Empty for the built-in kit
+	Sounds [numSlots]*Sample
+}
+
+// Sound returns the sample for a slot
+func (k *Kit) Sound(slot Slot) *Sample {
+	if slot < 0 || slot >= numSlots {
+		return nil
+	}
+	return k.Sounds[slot]
+}
+
+// KitError describes a kit file that could not be loaded
+type KitError struct {
+	Kit  string
+	File string
+	Err  error
+}
+
+func (e *KitError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("kit %q: %v", e.Kit, e.Err)
+	}
+	return fmt.Sprintf("kit %q: %s: %v", e.Kit, e.File, e.Err)
+}
+
+func (e *KitError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultKit returns the built-in kit of synthesized clicks
+func DefaultKit() *Kit {
+	k := &Kit{Name: DefaultKitName}
+	k.Sounds[SlotAccent] = synthClick(1760, 0.07, 0.9)
+	k.Sounds[SlotNormal] = synthClick(1320, 0.05, 0.7)
+	k.Sounds[SlotSubdivision] = synthClick(990, 0.035, 0.45)
+	k.Sounds[SlotCountIn] = synthClick(2093, 0.05, 0.7)
+	return k
+}
+
+// synthClick renders a short decaying sine blip
+func synthClick(freq, seconds, gain float64) *Sample {
+	n := int(seconds * SampleRate)
+	data := make([]float32, n)
+	for i := range data {
+		t := float64(i) / SampleRate
+		env := math.Exp(-t / (seconds / 5))
+		data[i] = float32(gain * env * math.Sin(2*math.Pi*freq*t))
+	}
+	return &Sample{Rate: SampleRate, Data: data}
+}
+
+// KitsDir returns the directory user kits are loaded from
+func KitsDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "metrognome", "kits"), nil
+}
+
+// ListKits returns the kit directories found under root, sorted by name.
+// A missing root is not an error; it simply has no kits.
+func ListKits(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(root, e.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// LoadKit loads a kit from a directory of WAV files. The accent and normal
+// sounds are required; a missing subdivision or count-in sound falls back to
+// the built-in click for that slot.
+func LoadKit(dir string) (*Kit, error) {
+	name := filepath.Base(dir)
+	fallback := DefaultKit()
+	k := &Kit{Name: name, Dir: dir}
+
+	for slot := Slot(0); slot < numSlots; slot++ {
+		file := slotFiles[slot]
+		f, err := os.Open(filepath.Join(dir, file))
+		if errors.Is(err, os.ErrNotExist) && slot != SlotAccent && slot != SlotNormal {
+			k.Sounds[slot] = fallback.Sounds[slot]
+			continue
+		}
+		if err != nil {
+			return nil, &KitError{Kit: name, File: file, Err: unwrapPathError(err)}
+		}
+
+		s, err := DecodeWAV(f)
+		f.Close()
+		if err != nil {
+			return nil, &KitError{Kit: name, File: file, Err: err}
+		}
+		k.Sounds[slot] = s.Resample(SampleRate)
+	}
+	return k, nil
+}
+
+// unwrapPathError drops the path from an os error since KitError has it
+func unwrapPathError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
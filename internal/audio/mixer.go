@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// Layer is a rhythmic layer that can be placed in the stereo field
+type Layer int
+
+const (
+	LayerBeat        Layer = iota // The main pulse, including accents and count-in
+	LayerSubdivision              // Clicks between beats
+	numLayers
+)
+
+// AllLayers lists every layer in display order
+var AllLayers = []Layer{LayerBeat, LayerSubdivision}
+
+// String returns the human-readable layer name
+func (l Layer) String() string {
+	switch l {
+	case LayerBeat:
+		return "beat"
+	case LayerSubdivision:
+		return "subdivision"
+	default:
+		return fmt.Sprintf("layer(%d)", int(l))
+	}
+}
+
+// LayerOf returns the layer a slot is played on
+func LayerOf(slot Slot) Layer {
+	if slot == SlotSubdivision {
+		return LayerSubdivision
+	}
+	return LayerBeat
+}
+
+// Mixer ranges
+const (
+	MaxPitch = 12 // Semitones up or down
+	MaxPan   = 1  // Hard right; -MaxPan is hard left
+)
+
+// Mixer holds the loudness, tuning and placement of every sound
+type Mixer struct {
+	Master float64            // Overall volume, 0 to 1
+	Volume [numSlots]float64  // Per-slot volume, 0 to 1
+	Pitch  [numSlots]float64  // Per-slot pitch shift in semitones
+	Pan    [numLayers]float64 // Per-layer pan, -1 (left) to 1 (right)
+}
+
+// DefaultMixer returns a mixer that plays every sound unchanged
+func DefaultMixer() Mixer {
+	m := Mixer{Master: 1}
+	for i := range m.Volume {
+		m.Volume[i] = 1
+	}
+	return m
+}
+
+// Clamp keeps every control within its range
+func (m Mixer) Clamp() Mixer {
+	m.Master = clamp(m.Master, 0, 1)
+	for i := range m.Volume {
+		m.Volume[i] = clamp(m.Volume[i], 0, 1)
+		m.Pitch[i] = clamp(m.Pitch[i], -MaxPitch, MaxPitch)
+	}
+	for i := range m.Pan {
+		m.Pan[i] = clamp(m.Pan[i], -MaxPan, MaxPan)
+	}
+	return m
+}
+
+// Render applies the mixer settings for a slot to a sample, returning the
+// left and right channels
+func (m Mixer) Render(s *Sample, slot Slot) (left, right []float32) {
+	data := s.Data
+	if pitch := m.Pitch[slot]; pitch != 0 {
+		// Resampling shifts pitch and length together, which suits clicks fine
+		data = stretch(data, math.Pow(2, pitch/12))
+	}
+
+	// Balance-style panning: center is unity on both sides and moving
+	// towards one side fades out the other, so nothing ever clips
+	gain := m.Master * m.Volume[slot]
+	pan := m.Pan[LayerOf(slot)]
+	lg := float32(gain * math.Min(1, 1-pan))
+	rg := float32(gain * math.Min(1, 1+pan))
+
+	left = make([]float32, len(data))
+	right = make([]float32, len(data))
+	for i, v := range data {
+		left[i] = v * lg
+		right[i] = v * rg
+	}
+	return left, right
+}
+
+// clamp limits v to the range [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,293 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// SoundSet selects what the player uses for clicks
+type SoundSet int
+
+const (
+	SoundClicks SoundSet = iota // Sounds from the current kit
+	SoundVoice                  // Spoken counts
+)
+
+// String returns the human-readable sound set name
+func (s SoundSet) String() string {
+	if s == SoundVoice {
+		return "voice"
+	}
+	return "clicks"
+}
+
+// Player plays kit sounds through the system's audio player
+type Player struct {
+	mu         sync.Mutex
+	kit        *Kit
+	mixer      Mixer
+	set        SoundSet
+	voice      *Voice            // Loaded the first time voice mode is used
+	dir        string            // Temporary directory holding rendered clips
+	gen        int               // Bumped on every render so playing files aren't overwritten
+	files      [numSlots]string  // Rendered clip per slot
+	voiceFiles map[string]string // Rendered words, keyed by word and slot
+	command    func(path string) *exec.Cmd
+	bell       io.Writer // Where the terminal bell goes when there is no player
+}
+
+// NewPlayer creates a player for the given kit
+func NewPlayer(kit *Kit) *Player {
+	p := &Player{mixer: DefaultMixer(), command: findCommand(), bell: os.Stdout}
+	if err := p.SetKit(kit); err != nil {
+		// Without rendered files we can only ring the terminal bell
+		p.kit = kit
+		p.command = nil
+	}
+	return p
+}
+
+// Available reports whether a system audio player was found. Without one,
+// clicks fall back to the terminal bell.
+func (p *Player) Available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.command != nil
+}
+
+// SetBell changes where the terminal bell fallback is written; nil silences it
+func (p *Player) SetBell(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bell = w
+}
+
+// Kit returns the kit currently in use
+func (p *Player) Kit() *Kit {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.kit
+}
+
+// Mixer returns the current mixer settings
+func (p *Player) Mixer() Mixer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mixer
+}
+
+// SoundSet returns the sound set currently in use
+func (p *Player) SoundSet() SoundSet {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.set
+}
+
+// SetSoundSet switches between kit clicks and spoken counts
+func (p *Player) SetSoundSet(set SoundSet) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if set == SoundVoice && p.voice == nil {
+		v, err := LoadVoice()
+		if err != nil {
+			return err
+		}
+		p.voice = v
+	}
+	p.set = set
+	return nil
+}
+
+// SetKit switches to a new kit, rendering its sounds to temporary files
+func (p *Player) SetKit(kit *Kit) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.render(kit, p.mixer)
+}
+
+// SetMixer applies new mixer settings, re-rendering the kit's sounds
+func (p *Player) SetMixer(mixer Mixer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.render(p.kit, mixer.Clamp())
+}
+
+// render writes every slot of the kit, as shaped by the mixer, to temporary
+// files. The caller must hold p.mu.
+func (p *Player) render(kit *Kit, mixer Mixer) error {
+	if p.dir == "" {
+		dir, err := os.MkdirTemp("", "metrognome-")
+		if err != nil {
+			return err
+		}
+		p.dir = dir
+	}
+
+	p.gen++
+	var files [numSlots]string
+	for slot := Slot(0); slot < numSlots; slot++ {
+		s := kit.Sound(slot)
+		if s == nil {
+			continue
+		}
+		path := filepath.Join(p.dir, fmt.Sprintf("%s-%d.wav", slot, p.gen))
+		left, right := mixer.Render(s, slot)
+		if err := writeClip(path, s.Rate, left, right); err != nil {
+			return err
+		}
+		files[slot] = path
+	}
+
+	p.kit = kit
+	p.mixer = mixer
+	p.files = files
+	p.voiceFiles = make(map[string]string)
+	return nil
+}
+
+// renderWord returns the file for a spoken word shaped by the mixer settings
+// of a slot, rendering it on first use. The caller must hold p.mu.
+func (p *Player) renderWord(word string, slot Slot) (string, error) {
+	key := fmt.Sprintf("%s-%s", word, slot)
+	if path, ok := p.voiceFiles[key]; ok {
+		return path, nil
+	}
+
+	s := p.voice.Word(word)
+	if s == nil || p.dir == "" {
+		return "", fmt.Errorf("no sample for %q", word)
+	}
+	path := filepath.Join(p.dir, fmt.Sprintf("voice-%s-%d.wav", key, p.gen))
+	left, right := p.mixer.Render(s, slot)
+	if err := writeClip(path, s.Rate, left, right); err != nil {
+		return "", err
+	}
+	p.voiceFiles[key] = path
+	return path, nil
+}
+
+// writeClip writes a stereo clip to path as a WAV file
+func writeClip(path string, rate int, left, right []float32) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeWAV(f, rate, left, right); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SlotFor returns the slot a beat is played with
+func SlotFor(b metronome.Beat) Slot {
+	switch {
+	case b.Subdivision > 0:
+		return SlotSubdivision
+	case b.IsCountIn():
+		return SlotCountIn
+	case b.Accent:
+		return SlotAccent
+	default:
+		return SlotNormal
+	}
+}
+
+// PlayBeat plays a beat with the current sound set. In voice mode the beat
+// is counted out loud, keeping the accent and normal mixer settings apart.
+func (p *Player) PlayBeat(b metronome.Beat) {
+	slot := SlotFor(b)
+
+	p.mu.Lock()
+	path := ""
+	if p.set == SoundVoice {
+		if word := CountWord(b.Beat, b.Subdivision, b.Subdivisions); word != "" {
+			path, _ = p.renderWord(word, slot)
+		}
+	}
+	p.mu.Unlock()
+
+	if path == "" {
+		// Beats the voice can't say fall back to the kit
+		p.Play(slot)
+		return
+	}
+	p.run(path)
+}
+
+// Play plays the sound for a slot without blocking
+func (p *Player) Play(slot Slot) {
+	p.mu.Lock()
+	path := ""
+	if slot >= 0 && slot < numSlots {
+		path = p.files[slot]
+	}
+	p.mu.Unlock()
+	p.run(path)
+}
+
+// run starts the system player on a rendered clip
+func (p *Player) run(path string) {
+	p.mu.Lock()
+	command, bell := p.command, p.bell
+	p.mu.Unlock()
+
+	if command == nil || path == "" {
+		// Fallback to terminal bell
+		if bell != nil {
+			fmt.Fprint(bell, "\a")
+		}
+		return
+	}
+	go command(path).Run()
+}
+
+// Close removes the rendered clips
+func (p *Player) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(p.dir)
+	p.dir = ""
+	return err
+}
+
+// findCommand picks a command-line audio player for the current OS
+func findCommand() func(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin": // macOS
+		return func(path string) *exec.Cmd {
+			return exec.Command("afplay", path)
+		}
+	case "windows":
+		return func(path string) *exec.Cmd {
+			script := fmt.Sprintf("(New-Object Media.SoundPlayer '%s').PlaySync()", path)
+			return exec.Command("powershell", "-NoProfile", "-c", script)
+		}
+	default:
+		// Prefer PulseAudio/PipeWire, then plain ALSA
+		for _, name := range []string{"paplay", "pw-play", "aplay"} {
+			if _, err := exec.LookPath(name); err != nil {
+				continue
+			}
+			name := name
+			return func(path string) *exec.Cmd {
+				if name == "aplay" {
+					return exec.Command(name, "-q", path)
+				}
+				return exec.Command(name, path)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"io"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Render mixes a planned series of beats into a stereo WAV file of the given
+// length, using the player's kit, mixer and sound set. Beat times are
+// measured from start.
+func (p *Player) Render(w io.Writer, beats []metronome.Beat, start time.Time, length time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	frames := int(length.Seconds() * SampleRate)
+	left := make([]float32, frames)
+	right := make([]float32, frames)
+
+	for _, b := range beats {
+		s, slot := p.clip(b)
+		if s == nil {
+			continue
+		}
+		l, r := p.mixer.Render(s, slot)
+
+		offset := int(b.Time.Sub(start).Seconds() * SampleRate)
+		for i := range l {
+			if offset+i < 0 || offset+i >= frames {
+				continue
+			}
+			left[offset+i] += l[i]
+			right[offset+i] += r[i]
+		}
+	}
+
+	return EncodeWAV(w, SampleRate, left, right)
+}
+
+// clip returns the sample and slot a beat is played with: the spoken count
+// in voice mode, otherwise the kit's sound. The caller must hold p.mu.
+func (p *Player) clip(b metronome.Beat) (*Sample, Slot) {
+	slot := SlotFor(b)
+	if p.set == SoundVoice {
+		if s := p.voice.Word(CountWord(b.Beat, b.Subdivision, b.Subdivisions)); s != nil {
+			return s, slot
+		}
+	}
+	return p.kit.Sound(slot), slot
+}
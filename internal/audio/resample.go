@@ -0,0 +1,32 @@
+package audio
+
+// Resample converts the sample to the given rate using linear interpolation
+func (s *Sample) Resample(rate int) *Sample {
+	if s.Rate == rate || len(s.Data) == 0 {
+		return s
+	}
+	return &Sample{Rate: rate, Data: stretch(s.Data, float64(s.Rate)/float64(rate))}
+}
+
+// stretch reads through data at the given step, interpolating between frames.
+// A step above 1 shortens the clip (and raises its pitch), below 1 lengthens it.
+func stretch(data []float32, step float64) []float32 {
+	n := int(float64(len(data)) / step)
+	if n < 1 {
+		n = 1
+	}
+
+	out := make([]float32, n)
+	last := len(data) - 1
+	for i := range out {
+		pos := float64(i) * step
+		idx := int(pos)
+		if idx >= last {
+			out[i] = data[last]
+			continue
+		}
+		frac := float32(pos - float64(idx))
+		out[i] = data[idx] + (data[idx+1]-data[idx])*frac
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metrics"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Scheduler plays the metronome's beats on time, independently of whatever
+// is consuming them for display. Beats arrive a look-ahead window early and
+// are queued on timers, so a stall elsewhere never delays a click.
+type Scheduler struct {
+	player *Player
+	metro  *metronome.Metronome
+
+	mu      sync.Mutex
+	enabled bool
+	offset  time.Duration // Output latency to compensate for
+	pending map[*time.Timer]struct{}
+
+	unsubscribe func()
+	done        chan struct{}
+}
+
+// NewScheduler starts playing the metronome's beats through the player
+func NewScheduler(player *Player, metro *metronome.Metronome) *Scheduler {
+	beats, unsubscribe := metro.Subscribe()
+	s := &Scheduler{
+		player:      player,
+		metro:       metro,
+		enabled:     true,
+		pending:     make(map[*time.Timer]struct{}),
+		unsubscribe: unsubscribe,
+		done:        make(chan struct{}),
+	}
+	go s.loop(beats)
+	return s
+}
+
+// SetEnabled turns sound on or off
+func (s *Scheduler) SetEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = enabled
+}
+
+// Enabled reports whether sound is on
+func (s *Scheduler) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled
+}
+
+// SetOffset makes clicks play early by the device's output latency, so they
+// are heard when they are due. Offsets beyond the metronome's look-ahead
+// window are only partly compensated.
+func (s *Scheduler) SetOffset(offset time.Duration) {
+	if offset < 0 {
+		offset = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = offset
+}
+
+// loop queues each beat as it is announced
+func (s *Scheduler) loop(beats <-chan metronome.Beat) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case b := <-beats:
+			s.queue(b)
+		}
+	}
+}
+
+// queue arms a timer to play a beat when it is due
+func (s *Scheduler) queue(b metronome.Beat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return
+	}
+
+	due := b.Time.Add(-s.offset)
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(due), func() {
+		s.mu.Lock()
+		delete(s.pending, t)
+		enabled := s.enabled
+		s.mu.Unlock()
+
+		// Skip beats queued before a stop, restart or mute
+		if enabled && s.metro.IsScheduled(b) {
+			metrics.AudioLatency.ObserveDuration(time.Since(due))
+			s.player.PlayBeat(b)
+		}
+	})
+	s.pending[t] = struct{}{}
+}
+
+// Close stops scheduling and cancels any queued clicks
+func (s *Scheduler) Close() {
+	s.unsubscribe()
+	close(s.done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t := range s.pending {
+		t.Stop()
+	}
+	s.pending = nil
+}
@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"embed"
+	"fmt"
+	"strconv"
+)
+
+//go:generate go run gen_voice.go
+
+//go:embed voice/*.wav
+var voiceFiles embed.FS
+
+// MaxSpokenCount is the highest beat number the voice can say
+const MaxSpokenCount = 16
+
+// Voice holds the spoken count samples
+type Voice struct {
+	words map[string]*Sample
+}
+
+// LoadVoice decodes the bundled count samples
+func LoadVoice() (*Voice, error) {
+	entries, err := voiceFiles.ReadDir("voice")
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Voice{words: make(map[string]*Sample, len(entries))}
+	for _, e := range entries {
+		f, err := voiceFiles.Open("voice/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		s, err := DecodeWAV(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("voice sample %s: %w", e.Name(), err)
+		}
+		word := e.Name()[:len(e.Name())-len(".wav")]
+		v.words[word] = s.Resample(SampleRate)
+	}
+	return v, nil
+}
+
+// Word returns the sample for a word, or nil if the voice can't say it
+func (v *Voice) Word(word string) *Sample {
+	return v.words[word]
+}
+
+// CountWord returns what to say for a click: the beat number on the beat
+// itself, and "e", "and", "a" for the subdivisions in between
+func CountWord(beat, subdivision, subdivisions int) string {
+	if subdivision == 0 {
+		if beat > MaxSpokenCount {
+			return ""
+		}
+		return strconv.Itoa(beat)
+	}
+
+	switch subdivisions {
+	case 2:
+		return "and"
+	case 3:
+		// Triplets are counted "one-and-a"
+		return [...]string{"", "and", "a"}[subdivision]
+	default:
+		// Sixteenths are counted "one-e-and-a"
+		return [...]string{"", "e", "and", "a"}[subdivision%4]
+	}
+}
@@ -0,0 +1,244 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SampleRate is the rate every sample is resampled to after loading
+const SampleRate = 44100
+
+// maxClipSeconds caps how long a click sample may be
+const maxClipSeconds = 5
+
+// Limits on what a header may claim, so a corrupt file can't make us
+// allocate more than a click could need
+const (
+	maxFormatSize = 64       // The extensible fmt chunk is 40 bytes
+	maxChannels   = 8        // 7.1 surround
+	maxDataSize   = 32 << 20 // Five seconds of 8 channels of 32-bit audio at 192 kHz
+)
+
+// WAV format tags we know how to decode
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xFFFE
+)
+
+// ErrNotWAV is returned when the data does not start with a RIFF/WAVE header
+var ErrNotWAV = errors.New("not a RIFF/WAVE file")
+
+// Sample is a mono clip of PCM audio normalized to [-1, 1]
+type Sample struct {
+	Rate int
+	Data []float32
+}
+
+// Duration returns the length of the sample in seconds
+func (s *Sample) Duration() float64 {
+	if s.Rate <= 0 {
+		return 0
+	}
+	return float64(len(s.Data)) / float64(s.Rate)
+}
+
+// wavFormat holds the fields of the "fmt " chunk we care about
+type wavFormat struct {
+	tag           uint16
+	channels      int
+	rate          int
+	blockAlign    int
+	bitsPerSample int
+}
+
+// DecodeWAV reads a WAV file and downmixes it to a mono Sample
+func DecodeWAV(r io.Reader) (*Sample, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, ErrNotWAV
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	var format *wavFormat
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if format == nil {
+				return nil, errors.New("missing fmt chunk")
+			}
+			return nil, errors.New("missing data chunk")
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			f, err := readFormat(r, size)
+			if err != nil {
+				return nil, err
+			}
+			format = f
+
+		case "data":
+			if format == nil {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			limit := int64(maxClipSeconds*format.rate) * int64(format.blockAlign)
+			if size > limit || size > maxDataSize {
+				return nil, fmt.Errorf("clip is longer than %d seconds", maxClipSeconds)
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("truncated data chunk: %w", err)
+			}
+			return decodeFrames(format, data)
+
+		default:
+			// Skip chunks we don't use (LIST, fact, cue, ...)
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("truncated %q chunk", id)
+			}
+		}
+	}
+}
+
+// readFormat parses the "fmt " chunk
+func readFormat(r io.Reader, size int64) (*wavFormat, error) {
+	if size < 16 {
+		return nil, errors.New("fmt chunk too short")
+	}
+	if size > maxFormatSize {
+		return nil, fmt.Errorf("fmt chunk too long (%d bytes)", size)
+	}
+	raw := make([]byte, size+size%2)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, errors.New("truncated fmt chunk")
+	}
+
+	f := &wavFormat{
+		tag:           binary.LittleEndian.Uint16(raw[0:2]),
+		channels:      int(binary.LittleEndian.Uint16(raw[2:4])),
+		rate:          int(binary.LittleEndian.Uint32(raw[4:8])),
+		blockAlign:    int(binary.LittleEndian.Uint16(raw[12:14])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(raw[14:16])),
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE keeps the real format in the sub-format GUID
+	if f.tag == formatExtensible {
+		if size < 26 {
+			return nil, errors.New("extensible fmt chunk too short")
+		}
+		f.tag = binary.LittleEndian.Uint16(raw[24:26])
+	}
+
+	switch {
+	case f.tag != formatPCM && f.tag != formatFloat:
+		return nil, fmt.Errorf("unsupported encoding (format tag %d), only PCM and float are supported", f.tag)
+	case f.channels < 1:
+		return nil, errors.New("file has no channels")
+	case f.channels > maxChannels:
+		return nil, fmt.Errorf("too many channels (%d), at most %d are supported", f.channels, maxChannels)
+	case f.rate < 8000 || f.rate > 192000:
+		return nil, fmt.Errorf("unsupported sample rate %d Hz", f.rate)
+	case f.tag == formatFloat && f.bitsPerSample != 32:
+		return nil, fmt.Errorf("unsupported float bit depth %d", f.bitsPerSample)
+	case f.bitsPerSample != 8 && f.bitsPerSample != 16 && f.bitsPerSample != 24 && f.bitsPerSample != 32:
+		return nil, fmt.Errorf("unsupported bit depth %d", f.bitsPerSample)
+	case f.blockAlign != f.channels*f.bitsPerSample/8:
+		return nil, errors.New("block alignment does not match channels and bit depth")
+	}
+	return f, nil
+}
+
+// decodeFrames converts interleaved frames into a mono Sample
+func decodeFrames(f *wavFormat, data []byte) (*Sample, error) {
+	frames := len(data) / f.blockAlign
+	if frames == 0 {
+		return nil, errors.New("data chunk is empty")
+	}
+
+	width := f.bitsPerSample / 8
+	out := make([]float32, frames)
+	for i := 0; i < frames; i++ {
+		var sum float32
+		for c := 0; c < f.channels; c++ {
+			off := i*f.blockAlign + c*width
+			sum += decodeValue(f, data[off:off+width])
+		}
+		out[i] = sum / float32(f.channels)
+	}
+	return &Sample{Rate: f.rate, Data: out}, nil
+}
+
+// decodeValue converts a single little-endian sample to a float
+func decodeValue(f *wavFormat, b []byte) float32 {
+	if f.tag == formatFloat {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	switch f.bitsPerSample {
+	case 8:
+		// 8-bit WAV is unsigned
+		return (float32(b[0]) - 128) / 128
+	case 16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case 24:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+		if v&0x800000 != 0 {
+			v |= ^0xFFFFFF
+		}
+		return float32(v) / 8388608
+	default:
+		return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+	}
+}
+
+// EncodeWAV writes 16-bit stereo PCM built from the left and right channels
+func EncodeWAV(w io.Writer, rate int, left, right []float32) error {
+	frames := len(left)
+	if len(right) < frames {
+		frames = len(right)
+	}
+	dataSize := frames * 4
+
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(formatPCM))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate*4))
+	binary.Write(&buf, binary.LittleEndian, uint16(4))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	for i := 0; i < frames; i++ {
+		binary.Write(&buf, binary.LittleEndian, toInt16(left[i]))
+		binary.Write(&buf, binary.LittleEndian, toInt16(right[i]))
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// toInt16 clips a float sample and converts it to 16-bit PCM
+func toInt16(v float32) int16 {
+	if v > 1 {
+		v = 1
+	} else if v < -1 {
+		v = -1
+	}
+	return int16(v * 32767)
+}
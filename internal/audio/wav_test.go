@@ -0,0 +1,176 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// riff wraps chunks in a RIFF/WAVE header
+func riff(chunks ...[]byte) []byte {
+	body := bytes.Join(chunks, nil)
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+len(body)))
+	buf.WriteString("WAVE")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// chunk builds a chunk that claims the given size, whatever its body holds
+func chunk(id string, size uint32, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(id)
+	binary.Write(&buf, binary.LittleEndian, size)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// format builds a 16-byte fmt chunk with a matching block alignment
+func format(tag, channels, rate, bits int) []byte {
+	var body bytes.Buffer
+	for _, v := range []any{
+		uint16(tag), uint16(channels), uint32(rate),
+		uint32(rate * channels * bits / 8), uint16(channels * bits / 8), uint16(bits),
+	} {
+		binary.Write(&body, binary.LittleEndian, v)
+	}
+	return chunk("fmt ", 16, body.Bytes())
+}
+
+// data builds a data chunk from little-endian values
+func data(values ...any) []byte {
+	var body bytes.Buffer
+	for _, v := range values {
+		binary.Write(&body, binary.LittleEndian, v)
+	}
+	return chunk("data", uint32(body.Len()), body.Bytes())
+}
+
+func TestDecodeWAV(t *testing.T) {
+	extensible := func(tag int) []byte {
+		f := format(formatExtensible, 1, 44100, 16)
+		f = append(f[8:], 22, 0, 16, 0, 4, 0, 0, 0)
+		return chunk("fmt ", 40, append(binary.LittleEndian.AppendUint16(f, uint16(tag)), make([]byte, 14)...))
+	}
+	tests := []struct {
+		name string
+		file []byte
+		rate int
+		want []float32
+	}{
+		{"8-bit", riff(format(formatPCM, 1, 8000, 8), data([]uint8{128, 192, 0})), 8000, []float32{0, 0.5, -1}},
+		{"16-bit stereo downmixed", riff(format(formatPCM, 2, 44100, 16), data([]int16{16384, 0, -16384, -16384})), 44100, []float32{0.25, -0.5}},
+		{"24-bit", riff(format(formatPCM, 1, 48000, 24), data([]uint8{0, 0, 0x40, 0, 0, 0xC0})), 48000, []float32{0.5, -0.5}},
+		{"32-bit", riff(format(formatPCM, 1, 96000, 32), data([]int32{math.MinInt32})), 96000, []float32{-1}},
+		{"float", riff(format(formatFloat, 1, 44100, 32), data([]float32{0.75, -0.125})), 44100, []float32{0.75, -0.125}},
+		{"extensible", riff(extensible(formatPCM), data([]int16{-8192})), 44100, []float32{-0.25}},
+		{
+			// Odd-sized chunks are padded to an even length
+			"chunks skipped", riff(chunk("LIST", 3, []byte("abc\x00")), format(formatPCM, 1, 8000, 8), data([]uint8{255})),
+			8000, []float32{127.0 / 128},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := DecodeWAV(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Rate != tt.rate || len(s.Data) != len(tt.want) {
+				t.Fatalf("decoded %d samples at %d Hz, want %d at %d Hz", len(s.Data), s.Rate, len(tt.want), tt.rate)
+			}
+			for i := range tt.want {
+				if math.Abs(float64(s.Data[i]-tt.want[i])) > 1e-6 {
+					t.Errorf("sample %d = %v, want %v", i, s.Data[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeWAVRejectsCorruptHeaders(t *testing.T) {
+	mono := format(formatPCM, 1, 44100, 16)
+	tests := []struct {
+		name string
+		file []byte
+		want string
+	}{
+		{"empty", nil, ErrNotWAV.Error()},
+		{"not RIFF", append([]byte("RIFX\x00\x00\x00\x00WAVE"), mono...), ErrNotWAV.Error()},
+		{"no fmt", riff(), "missing fmt chunk"},
+		{"no data", riff(mono), "missing data chunk"},
+		{"data first", riff(data(int16(0)), mono), "data chunk before fmt chunk"},
+		{"fmt too short", riff(chunk("fmt ", 14, make([]byte, 14))), "fmt chunk too short"},
+		// A claimed size in the gigabytes must not be allocated
+		{"fmt too long", riff(chunk("fmt ", math.MaxUint32, make([]byte, 16))), "fmt chunk too long (4294967295 bytes)"},
+		{"fmt truncated", riff(chunk("fmt ", 16, make([]byte, 8))), "truncated fmt chunk"},
+		{"extensible too short", riff(chunk("fmt ", 18, append(format(formatExtensible, 1, 44100, 16)[8:], 0, 0))), "extensible fmt chunk too short"},
+		{"compressed", riff(format(2, 1, 44100, 16)), "unsupported encoding (format tag 2)"},
+		{"no channels", riff(format(formatPCM, 0, 44100, 16)), "file has no channels"},
+		{"too many channels", riff(format(formatPCM, 9, 44100, 16)), "too many channels (9), at most 8 are supported"},
+		{"low rate", riff(format(formatPCM, 1, 4000, 16)), "unsupported sample rate 4000 Hz"},
+		{"high rate", riff(format(formatPCM, 1, 384000, 16)), "unsupported sample rate 384000 Hz"},
+		{"odd depth", riff(format(formatPCM, 1, 44100, 12)), "unsupported bit depth 12"},
+		{"half float", riff(format(formatFloat, 1, 44100, 16)), "unsupported float bit depth 16"},
+		{"block alignment", riff(chunk("fmt ", 16, append(mono[8:20:20], 3, 0, 16, 0))), "block alignment does not match"},
+		{"data too long", riff(mono, chunk("data", math.MaxUint32, make([]byte, 4))), "clip is longer than 5 seconds"},
+		{"data over five seconds", riff(mono, chunk("data", 5*44100*2+2, nil)), "clip is longer than 5 seconds"},
+		{"data truncated", riff(mono, chunk("data", 100, make([]byte, 4))), "truncated data chunk"},
+		{"data empty", riff(mono, data()), "data chunk is empty"},
+		{"other chunk truncated", riff(chunk("LIST", 100, make([]byte, 4))), `truncated "LIST" chunk`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeWAV(bytes.NewReader(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeWAVDecodes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeWAV(&buf, 22050, []float32{0.5, 2, -1}, []float32{0, 0, -1, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := DecodeWAV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Downmixed, with the left channel clipped and the right cut to its length
+	want := []float32{0.25, 0.5, -1}
+	if s.Rate != 22050 || len(s.Data) != len(want) {
+		t.Fatalf("decoded %d samples at %d Hz", len(s.Data), s.Rate)
+	}
+	for i := range want {
+		if math.Abs(float64(s.Data[i]-want[i])) > 1e-4 {
+			t.Errorf("sample %d = %v, want %v", i, s.Data[i], want[i])
+		}
+	}
+}
+
+func TestLoadKitNamesTheBadFile(t *testing.T) {
+	dir := t.TempDir()
+	good := riff(format(formatPCM, 1, 44100, 16), data([]int16{1000, -1000}))
+	for file, content := range map[string][]byte{
+		"accent.wav": good,
+		"normal.wav": riff(format(formatPCM, 9, 44100, 16)),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := LoadKit(dir)
+	var kitErr *KitError
+	if !errors.As(err, &kitErr) || kitErr.File != "normal.wav" || !strings.Contains(err.Error(), "too many channels") {
+		t.Errorf("got %v, want the channel count in normal.wav blamed", err)
+	}
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// fileName is the name of the config file inside the config directory
+const fileName = "config.json"
+
+// Config holds the settings that survive a restart
+type Config struct {
+	// BPM is the tempo the metronome starts at
+	BPM int `json:"bpm"`
+
+	// TimeSignature is the meter the metronome starts in, such as "4/4"
+	TimeSignature string `json:"time_signature"`
+
+	// Subdivision is how many clicks each beat is split into
+	Subdivision int `json:"subdivision"`
+
+	// CountIn is how many bars are counted in before playback starts
+	CountIn int `json:"count_in"`
+
+	// Sound turns clicks on at startup
+	Sound bool `json:"sound"`
+
+	// Voice counts out loud instead of clicking
+	Voice bool `json:"voice"`
+
+	// Kit is the name of a kit in the kits directory, empty for the
+	// built-in clicks
+	Kit string `json:"kit"`
+
+	// Theme is the name of the color theme
+	Theme string `json:"theme"`
+
+	// Keys rebinds actions to other keys, e.g. {"start_stop": ["space", "enter"]}
+	Keys map[string][]string `json:"keys,omitempty"`
+
+	// SaveOnQuit stores the tempo, meter and sound settings in use when the
+	// TUI quits, so the next session starts where this one ended
+	SaveOnQuit bool `json:"save_on_quit"`
+
+	// LatencyOffsetMs is how late the audio device plays a click, as measured
+	// by the calibration wizard
+	LatencyOffsetMs int `json:"latency_offset_ms"`
+
+	// LookAheadMs is how far ahead of time clicks are queued for playback
+	LookAheadMs int `json:"lookahead_ms"`
+
+	// Hooks run commands or write to FIFOs as playback goes along
+	Hooks Hooks `json:"hooks"`
+}
+
+// Hook is a command to run, or a FIFO to write a line to, when something
+// happens during playback. Exactly one of Run and FIFO is set.
+type Hook struct {
+	Run   string `json:"run,omitempty"`   // Shell command
+	FIFO  string `json:"fifo,omitempty"`  // Path of a FIFO to write a line to
+	Every int    `json:"every,omitempty"` // For on_downbeat: bars between runs, 0 or 1 for every bar
+	Bars  []int  `json:"bars,omitempty"`  // For on_bar: bars to run on, all of them when empty
+}
+
+// Hooks are the hooks for each kind of event
+type Hooks struct {
+	OnStart    []Hook `json:"on_start,omitempty"`    // Playback starts
+	OnStop     []Hook `json:"on_stop,omitempty"`     // Playback stops
+	OnBar      []Hook `json:"on_bar,omitempty"`      // A bar begins, after any count-in
+	OnSection  []Hook `json:"on_section,omitempty"`  // A song section begins
+	OnDownbeat []Hook `json:"on_downbeat,omitempty"` // Every few bars, from the first
+}
+
+// Validate checks that every hook says what to do, and only uses the
+// options of its event
+func (h Hooks) Validate() error {
+	events := []struct {
+		name  string
+		hooks []Hook
+	}{
+		{"on_start", h.OnStart},
+		{"on_stop", h.OnStop},
+		{"on_bar", h.OnBar},
+		{"on_section", h.OnSection},
+		{"on_downbeat", h.OnDownbeat},
+	}
+	for _, e := range events {
+		for i, hook := range e.hooks {
+			if err := hook.validate(e.name); err != nil {
+				return fmt.Errorf("hooks: %s[%d]: %w", e.name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks a hook for an event
+func (h Hook) validate(event string) error {
+	switch {
+	case h.Run == "" && h.FIFO == "":
+		return errors.New(`set "run" or "fifo"`)
+	case h.Run != "" && h.FIFO != "":
+		return errors.New(`set "run" or "fifo", not both`)
+	case h.Every < 0:
+		return fmt.Errorf("every must be positive, got %d", h.Every)
+	case h.Every > 0 && event != "on_downbeat":
+		return errors.New("every only applies to on_downbeat")
+	case len(h.Bars) > 0 && event != "on_bar":
+		return errors.New("bars only applies to on_bar")
+	}
+	for _, bar := range h.Bars {
+		if bar < 1 {
+			return fmt.Errorf("bars start at 1, got %d", bar)
+		}
+	}
+	return nil
+}
+
+// Default returns the settings used when there is no config file
+func Default() Config {
+	return Config{
+		BPM:           120,
+		TimeSignature: "4/4",
+		Subdivision:   1,
+		Sound:         true,
+		Theme:         "garden",
+		LookAheadMs:   100,
+	}
+}
+
+// Validate checks that every setting is in range
+func (c Config) Validate() error {
+	if err := metronome.ValidateBPM(c.BPM); err != nil {
+		return fmt.Errorf("bpm: %w", err)
+	}
+	if _, err := metronome.ParseTimeSignature(c.TimeSignature); err != nil {
+		return fmt.Errorf("time_signature: %w", err)
+	}
+	if err := metronome.ValidateSubdivision(c.Subdivision); err != nil {
+		return fmt.Errorf("subdivision: %w", err)
+	}
+	if err := metronome.ValidateCountIn(c.CountIn); err != nil {
+		return fmt.Errorf("count_in: %w", err)
+	}
+	if c.LookAheadMs < 1 {
+		return fmt.Errorf("lookahead_ms: must be at least 1, got %d", c.LookAheadMs)
+	}
+	if err := c.Hooks.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Meter returns the parsed time signature
+func (c Config) Meter() metronome.TimeSignature {
+	ts, err := metronome.ParseTimeSignature(c.TimeSignature)
+	if err != nil {
+		return metronome.CommonTimeSignatures[0]
+	}
+	return ts
+}
+
+// LatencyOffset returns the calibrated output latency
+func (c Config) LatencyOffset() time.Duration {
+	return time.Duration(c.LatencyOffsetMs) * time.Millisecond
+}
+
+// LookAhead returns the audio scheduling look-ahead window
+func (c Config) LookAhead() time.Duration {
+	return time.Duration(c.LookAheadMs) * time.Millisecond
+}
+
+// Dir returns the metrognome config directory, honoring $XDG_CONFIG_HOME
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "metrognome"), nil
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Load reads the config file, returning the defaults if it doesn't exist
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), err
+	}
+
+	c, err := parse(data)
+	if err != nil {
+		return Default(), fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// parse decodes a config file over the defaults, so settings left out keep
+// their default values. Unknown settings are rejected to catch typos.
+func parse(data []byte) (Config, error) {
+	c := Default()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		var syntax *json.SyntaxError
+		var typ *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntax):
+			line, col := position(data, syntax.Offset)
+			return c, fmt.Errorf("line %d, column %d: %w", line, col, err)
+		case errors.As(err, &typ):
+			line, col := position(data, typ.Offset)
+			return c, fmt.Errorf("line %d, column %d: %s: expected %s, got %s", line, col, typ.Field, typ.Type, typ.Value)
+		case errors.Is(err, io.EOF):
+			return c, errors.New("file is empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			// The decoder has no typed error for this one
+			return c, fmt.Errorf("unknown setting %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		}
+		return c, err
+	}
+	if err := c.Validate(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// Save writes the config file, replacing it atomically
+func Save(c Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
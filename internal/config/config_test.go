@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	c, err := parse([]byte(`{"bpm": 96, "time_signature": "7/8", "keys": {"start_stop": ["enter"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.BPM = 96
+	want.TimeSignature = "7/8"
+	want.Keys = map[string][]string{"start_stop": {"enter"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want the defaults with the settings given:\n%+v", c, want)
+	}
+	if m := c.Meter(); m.Beats != 7 || m.BeatValue != 8 {
+		t.Errorf("meter %s, want 7/8", m)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "file is empty"},
+		{"syntax", "{\n  \"bpm\": 120\n  \"sound\": true\n}", "line 3, column "},
+		{"wrong type", "{\n  \"bpm\": \"fast\"\n}", "line 2, column 16: bpm: expected int, got string"},
+		{"unknown setting", `{"bmp": 120}`, `unknown setting "bmp"`},
+		{"tempo out of range", `{"bpm": 500}`, "bpm: "},
+		{"meter", `{"time_signature": "4/3"}`, "time_signature: "},
+		{"subdivision", `{"subdivision": 5}`, "subdivision: "},
+		{"count-in", `{"count_in": -1}`, "count_in: "},
+		{"look-ahead", `{"lookahead_ms": 0}`, "lookahead_ms: must be at least 1, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse([]byte(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error starting %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	// No file yet
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Errorf("without a file, loaded %+v, want the defaults", c)
+	}
+
+	c.BPM = 72
+	c.Voice = true
+	c.LatencyOffsetMs = 35
+	if err := Save(c); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("loaded %+v, want %+v", got, c)
+	}
+
+	// A broken file names itself and falls back to the defaults
+	path, _ := Path()
+	if err := os.WriteFile(path, []byte(`{"bpm": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Load()
+	if err == nil || !strings.HasPrefix(err.Error(), path+": bpm: ") {
+		t.Errorf("loading a broken file gave %v", err)
+	}
+	if !reflect.DeepEqual(got, Default()) {
+		t.Errorf("loading a broken file gave %+v, want the defaults", got)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp")); len(leftovers) > 0 {
+		t.Errorf("left behind %v", leftovers)
+	}
+}
+
+func TestHooksValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks Hooks
+		want  string
+	}{
+		{"fine", Hooks{OnBar: []Hook{{Run: "true", Bars: []int{1, 9}}}, OnDownbeat: []Hook{{FIFO: "/tmp/beats", Every: 4}}}, ""},
+		{"nothing to do", Hooks{OnStart: []Hook{{}}}, `hooks: on_start[0]: set "run" or "fifo"`},
+		{"both", Hooks{OnStop: []Hook{{Run: "true", FIFO: "/tmp/beats"}}}, `hooks: on_stop[0]: set "run" or "fifo", not both`},
+		{"every elsewhere", Hooks{OnBar: []Hook{{Run: "true", Every: 2}}}, "hooks: on_bar[0]: every only applies to on_downbeat"},
+		{"negative every", Hooks{OnDownbeat: []Hook{{Run: "true", Every: -1}}}, "hooks: on_downbeat[0]: every must be positive, got -1"},
+		{"bars elsewhere", Hooks{OnSection: []Hook{{Run: "true"}, {Run: "true", Bars: []int{2}}}}, "hooks: on_section[1]: bars only applies to on_bar"},
+		{"bar 0", Hooks{OnBar: []Hook{{Run: "true", Bars: []int{0}}}}, "hooks: on_bar[0]: bars start at 1, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hooks.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("rejected: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
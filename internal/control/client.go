@@ -0,0 +1,47 @@
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// timeout bounds how long a client waits to connect and for a reply
+const timeout = 5 * time.Second
+
+// ErrNotRunning is returned by Send when no metronome is listening
+var ErrNotRunning = errors.New("no metrognome is running")
+
+// Send sends one command line to the metronome listening at path and
+// returns its reply without the "ok" prefix. A reply of "error: ..." comes
+// back as an error.
+func Send(path, line string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return "", fmt.Errorf("%w (nothing listening on %s)", ErrNotRunning, path)
+		}
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("reading the reply: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\r\n")
+
+	if msg, ok := strings.CutPrefix(reply, "error: "); ok {
+		return "", errors.New(msg)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(reply, "ok"), " "), nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package control
+
+import "net"
+
+// listen creates the socket at path. File modes don't guard it here, so it
+// is left as created.
+func listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
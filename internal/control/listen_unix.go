@@ -0,0 +1,48 @@
+//go:build unix
+
+package control
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listen creates the socket in a private directory beside path, where no
+// one else can reach it, and moves it into place once only the user may
+// connect
+func listen(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".metrognome-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, 0o600); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		l.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	return &socketListener{Listener: l, path: path}, nil
+}
+
+// socketListener removes the socket from where it was moved to on Close
+type socketListener struct {
+	net.Listener
+	path string
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
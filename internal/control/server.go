@@ -0,0 +1,222 @@
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/presets"
+)
+
+// Commands understood, one per line:
+//
+//	status               reports the state
+//	start, stop, toggle  transport
+//	bpm 132              sets the tempo; bpm +5 and bpm -5 nudge it
+//	sig 7/8              sets the time signature
+//	preset NAME          applies a built-in or saved preset
+//	help                 lists the commands
+//
+// Every line gets a one-line reply: "ok" followed by the state (or the
+// command list for help), or "error: " followed by what went wrong.
+const help = "commands: status, start, stop, toggle, bpm N|+N|-N, sig N/N, preset NAME, help"
+
+// errExternal is returned when asked to start while an external clock
+// decides when to play
+var errExternal = errors.New("the metronome is following an external clock")
+
+// SocketPath returns where the control socket lives: in $XDG_RUNTIME_DIR
+// when it is set, since only the user can reach it, or the temp directory
+func SocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "metrognome.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("metrognome-%d.sock", os.Getuid()))
+}
+
+// Listen opens the control socket at path. A socket left behind by a
+// metronome that didn't shut down cleanly is replaced, but one still in use
+// is an error.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another metrognome is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return listen(path)
+}
+
+// Server drives a metronome from commands sent over a socket
+type Server struct {
+	metro *metronome.Metronome
+
+	// OnChange, if set, is called after a command changes the metronome
+	// with a short description of what happened, such as "bpm 132"
+	OnChange func(change string)
+}
+
+// NewServer creates a control server for a metronome
+func NewServer(metro *metronome.Metronome) *Server {
+	return &Server{metro: metro}
+}
+
+// Serve answers connections on l until it is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers each line sent on a connection until the client hangs up
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintln(conn, s.Handle(line)); err != nil {
+			return
+		}
+	}
+}
+
+// Handle carries out a single command line, returning the reply
+func (s *Server) Handle(line string) string {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return "error: no command given"
+	}
+	if words[0] == "help" {
+		return "ok " + help
+	}
+	change, err := s.run(words)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if change != "" && s.OnChange != nil {
+		s.OnChange(change)
+	}
+	return "ok " + s.Status()
+}
+
+// Status describes the state as space-separated key=value pairs
+func (s *Server) Status() string {
+	state := "stopped"
+	if s.metro.IsPlaying() {
+		state = "playing"
+	}
+	status := fmt.Sprintf("state=%s bpm=%d sig=%s subdivision=%d", state, s.metro.BPM(), s.metro.TimeSignature(), s.metro.Subdivision())
+	if song := s.metro.Song(); song != nil {
+		status += " song=" + strconv.Quote(song.Name)
+	}
+	return status
+}
+
+// run carries out a command, returning a description of the change
+func (s *Server) run(words []string) (string, error) {
+	name, args := words[0], words[1:]
+	switch name {
+	case "status":
+		return "", noArgs(name, args)
+
+	case "start":
+		if err := noArgs(name, args); err != nil {
+			return "", err
+		}
+		if s.metro.External() {
+			return "", errExternal
+		}
+		s.metro.Start()
+		return "start", nil
+
+	case "stop":
+		if err := noArgs(name, args); err != nil {
+			return "", err
+		}
+		s.metro.Stop()
+		return "stop", nil
+
+	case "toggle":
+		if err := noArgs(name, args); err != nil {
+			return "", err
+		}
+		if s.metro.IsPlaying() {
+			return s.run([]string{"stop"})
+		}
+		return s.run([]string{"start"})
+
+	case "bpm":
+		if len(args) != 1 {
+			return "", errors.New("usage: bpm N, bpm +N or bpm -N")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", fmt.Errorf("the tempo must be a whole number, got %q", args[0])
+		}
+		bpm := n
+		if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+			bpm = s.metro.BPM() + n
+		}
+		if err := s.metro.SetBPM(bpm); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("bpm %d", bpm), nil
+
+	case "sig":
+		if len(args) != 1 {
+			return "", errors.New("usage: sig N/N")
+		}
+		ts, err := metronome.ParseTimeSignature(args[0])
+		if err != nil {
+			return "", err
+		}
+		if err := s.metro.SetTimeSignature(ts); err != nil {
+			return "", err
+		}
+		return "meter " + ts.String(), nil
+
+	case "preset":
+		if len(args) == 0 {
+			return "", errors.New("usage: preset NAME")
+		}
+		p, ok, err := presets.Find(strings.Join(args, " "))
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("no preset called %q", strings.Join(args, " "))
+		}
+		if err := s.metro.Apply(p); err != nil {
+			return "", err
+		}
+		return "preset " + p.Name, nil
+	}
+	return "", fmt.Errorf("unknown command %q (%s)", name, help)
+}
+
+// noArgs rejects arguments to a command that takes none
+func noArgs(name string, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%s takes no arguments", name)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package control
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrognome.sock")
+	metro := metronome.New(90, metronome.CommonTimeSignatures[0])
+	defer metro.Stop()
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+			t.Errorf("socket mode %s, want a socket only the user can reach", info.Mode())
+		}
+		if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".metrognome-*")); len(leftovers) > 0 {
+			t.Errorf("left behind %v", leftovers)
+		}
+	}
+
+	srv := NewServer(metro)
+	var mu sync.Mutex
+	var changes []string
+	srv.OnChange = func(change string) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, change)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(l) }()
+
+	reply, err := Send(path, "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "state=stopped bpm=90 sig=4/4 subdivision=1"; reply != want {
+		t.Errorf("status = %q, want %q", reply, want)
+	}
+	reply, err = Send(path, "bpm 120")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "bpm=120") || metro.BPM() != 120 {
+		t.Errorf("after bpm 120, replied %q at %d BPM", reply, metro.BPM())
+	}
+	if _, err := Send(path, "bpm quick"); err == nil || !strings.Contains(err.Error(), `the tempo must be a whole number, got "quick"`) {
+		t.Errorf("bpm quick gave %v", err)
+	}
+	mu.Lock()
+	if len(changes) != 1 || changes[0] != "bpm 120" {
+		t.Errorf("changes %q, want only bpm 120", changes)
+	}
+	mu.Unlock()
+
+	// A second metronome can't take the socket while this one has it
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Errorf("second Listen gave %v", err)
+	}
+
+	l.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v after the socket closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve didn't return after the socket closed")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket still there after Close: %v", err)
+	}
+	if _, err := Send(path, "status"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Send with nothing listening gave %v, want ErrNotRunning", err)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrognome.sock")
+	// As if a metronome died without cleaning up
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("stale socket wasn't replaced: %v", err)
+	}
+	l.Close()
+}
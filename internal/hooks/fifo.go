@@ -0,0 +1,101 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fifoBuffer is how many lines wait for a slow reader before more are dropped
+const fifoBuffer = 64
+
+// fifoTimeout is how long a line waits for room in the FIFO before the
+// reader is given up on
+const fifoTimeout = time.Second
+
+// fifo writes event lines to a FIFO from a goroutine of its own. The FIFO is
+// opened without waiting for a reader: while nobody reads, lines are simply
+// dropped, so a reader that comes along later only sees what happens from
+// then on.
+type fifo struct {
+	path  string
+	lines chan string
+	quit  chan struct{} // Closed to stop writing
+	done  chan struct{} // Closed once writing has stopped
+}
+
+// fifo returns the writer for a path, starting it on first use
+func (r *Runner) fifo(path string) *fifo {
+	if f, ok := r.fifos[path]; ok {
+		return f
+	}
+	f := &fifo{path: path, lines: make(chan string, fifoBuffer), quit: make(chan struct{}), done: make(chan struct{})}
+	r.fifos[path] = f
+	go f.write(r.reportError)
+	return f
+}
+
+// send queues a line, dropping it if the reader has fallen too far behind
+func (f *fifo) send(line string) {
+	select {
+	case f.lines <- line:
+	default:
+	}
+}
+
+// write writes queued lines until told to quit. Errors are reported once
+// until writing works again.
+func (f *fifo) write(onError func(error)) {
+	defer close(f.done)
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	failing := false
+	for {
+		var line string
+		select {
+		case <-f.quit:
+			return
+		case line = <-f.lines:
+		}
+
+		var err error
+		if file == nil {
+			file, err = os.OpenFile(f.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+			if errors.Is(err, syscall.ENXIO) {
+				// Nobody is reading
+				continue
+			}
+		}
+		if err == nil {
+			file.SetWriteDeadline(time.Now().Add(fifoTimeout))
+			if _, err = fmt.Fprintln(file, line); err != nil {
+				// The reader went away; open again for the next one
+				file.Close()
+				file = nil
+				if errors.Is(err, syscall.EPIPE) {
+					continue
+				}
+			}
+		}
+		if err != nil && !failing {
+			onError(fmt.Errorf("hook fifo %s: %w", f.path, err))
+		}
+		failing = err != nil
+	}
+}
+
+// closeFIFOs stops every FIFO writer. Hooks still firing may queue lines
+// after, which are never written.
+func (r *Runner) closeFIFOs() {
+	for _, f := range r.fifos {
+		close(f.quit)
+		<-f.done
+	}
+}
@@ -0,0 +1,95 @@
+//go:build unix
+
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// mkfifo makes a FIFO nobody reads yet
+func mkfifo(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFIFOWithoutReaderNeverBlocks(t *testing.T) {
+	path := mkfifo(t)
+	r := New(metronome.New(120, metronome.CommonTimeSignatures[0]), config.Hooks{})
+	errs := make(chan error, 1)
+	r.OnError = func(err error) { errs <- err }
+
+	start := time.Now()
+	f := r.fifo(path)
+	for i := 0; i < 10*fifoBuffer; i++ {
+		f.send("event=bar")
+	}
+	if took := time.Since(start); took > 100*time.Millisecond {
+		t.Errorf("queueing lines took %s", took)
+	}
+
+	// Lines nobody reads are dropped quietly
+	select {
+	case err := <-errs:
+		t.Errorf("reported %v with no reader", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	start = time.Now()
+	r.closeFIFOs()
+	if took := time.Since(start); took > 100*time.Millisecond {
+		t.Errorf("closing took %s", took)
+	}
+}
+
+func TestFIFOGivesUpOnStalledReader(t *testing.T) {
+	path := mkfifo(t)
+	// A reader that never reads
+	reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	r := New(metronome.New(120, metronome.CommonTimeSignatures[0]), config.Hooks{})
+	errs := make(chan error, 4)
+	r.OnError = func(err error) { errs <- err }
+	defer r.closeFIFOs()
+
+	// Enough to fill the pipe, and queueing still never waits
+	f := r.fifo(path)
+	long := strings.Repeat("x", 1024)
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		f.send(long)
+		time.Sleep(time.Millisecond)
+	}
+	if took := time.Since(start); took > 600*time.Millisecond {
+		t.Errorf("queueing lines took %s", took)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "hook fifo "+path) {
+			t.Errorf("error %q doesn't name the FIFO", err)
+		}
+	case <-time.After(3 * fifoTimeout):
+		t.Fatal("a reader stalled past the timeout wasn't reported")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("reported again: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
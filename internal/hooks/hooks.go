@@ -0,0 +1,280 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Events, passed to commands as $METROGNOME_EVENT
+const (
+	EventStart    = "start"
+	EventStop     = "stop"
+	EventBar      = "bar"
+	EventSection  = "section"
+	EventDownbeat = "downbeat"
+)
+
+// Context describes where playback was when an event happened
+type Context struct {
+	Event         string
+	Bar           int
+	Beat          int
+	BPM           int
+	TimeSignature string
+	Section       string // Name of the song section, empty without a song
+	SectionBar    int    // Bar within the section, 0 without a song
+	Time          time.Time
+}
+
+// Env returns the context as environment variables for a command
+func (c Context) Env() []string {
+	env := []string{
+		"METROGNOME_EVENT=" + c.Event,
+		"METROGNOME_BAR=" + strconv.Itoa(c.Bar),
+		"METROGNOME_BEAT=" + strconv.Itoa(c.Beat),
+		"METROGNOME_BPM=" + strconv.Itoa(c.BPM),
+		"METROGNOME_TIME_SIGNATURE=" + c.TimeSignature,
+		"METROGNOME_TIME=" + c.Time.Format(time.RFC3339Nano),
+	}
+	if c.Section != "" {
+		env = append(env,
+			"METROGNOME_SECTION="+c.Section,
+			"METROGNOME_SECTION_BAR="+strconv.Itoa(c.SectionBar))
+	}
+	return env
+}
+
+// Line returns the context as one line of space-separated key=value pairs,
+// as written to FIFOs
+func (c Context) Line() string {
+	line := fmt.Sprintf("event=%s bar=%d beat=%d bpm=%d time_signature=%s time=%s",
+		c.Event, c.Bar, c.Beat, c.BPM, c.TimeSignature, c.Time.Format(time.RFC3339Nano))
+	if c.Section != "" {
+		line += fmt.Sprintf(" section=%s section_bar=%d", strconv.Quote(c.Section), c.SectionBar)
+	}
+	return line
+}
+
+// Runner fires the configured hooks as a metronome plays. Hooks are fired
+// from timers of their own when their beat is due to sound, so commands
+// that are slow to start or FIFOs nobody reads never hold up a click.
+type Runner struct {
+	metro *metronome.Metronome
+	hooks config.Hooks
+	fifos map[string]*fifo
+
+	pending []pending // Hooks waiting for their beat to sound
+
+	// OnError, if set, is called when a command can't be run or fails, or
+	// a FIFO can't be written to
+	OnError func(err error)
+}
+
+// pending is a timer that fires hooks when their beat sounds
+type pending struct {
+	timer *time.Timer
+	at    time.Time
+}
+
+// New creates a runner for a metronome's hooks
+func New(metro *metronome.Metronome, hooks config.Hooks) *Runner {
+	return &Runner{metro: metro, hooks: hooks, fifos: map[string]*fifo{}}
+}
+
+// Empty reports whether there are no hooks to run
+func (r *Runner) Empty() bool {
+	h := r.hooks
+	return len(h.OnStart)+len(h.OnStop)+len(h.OnBar)+len(h.OnSection)+len(h.OnDownbeat) == 0
+}
+
+// Run fires hooks for the beats that arrive until stop is closed
+func (r *Runner) Run(beats <-chan metronome.Beat, stop <-chan struct{}) {
+	defer r.closeFIFOs()
+	defer r.cancel()
+
+	var run <-chan struct{} // The run the last beat belongs to, nil when stopped
+	var last Context
+	stopped := func() {
+		r.cancel()
+		run = nil
+		last.Event = EventStop
+		last.Time = time.Now()
+		r.fire(r.hooks.OnStop, last, nil)
+	}
+	for {
+		select {
+		case <-stop:
+			// Quitting stops playback too, or the run may have ended
+			// without it being noticed yet
+			if run != nil {
+				stopped()
+			}
+			return
+
+		case <-run:
+			// The run ended; it may have carried straight on after a change
+			if r.metro.IsPlaying() {
+				r.cancel()
+				run = nil
+				continue
+			}
+			stopped()
+
+		case b := <-beats:
+			if b.Subdivision > 0 || !r.metro.IsScheduled(b) {
+				continue
+			}
+			if current := r.metro.Stopped(); current != run {
+				if run != nil {
+					r.cancel()
+				}
+				started := run == nil && !r.metro.Restarted()
+				run = current
+				if started {
+					r.fire(r.hooks.OnStart, r.context(EventStart, b), nil)
+				}
+			}
+			last = r.context(EventStop, b)
+			if b.Beat != 1 || b.IsCountIn() {
+				continue
+			}
+
+			if b.SectionBar == 1 && last.Section != "" {
+				r.fire(r.hooks.OnSection, r.context(EventSection, b), nil)
+			}
+			r.fire(r.hooks.OnBar, r.context(EventBar, b), func(h config.Hook) bool {
+				return len(h.Bars) == 0 || contains(h.Bars, b.Bar)
+			})
+			r.fire(r.hooks.OnDownbeat, r.context(EventDownbeat, b), func(h config.Hook) bool {
+				return (b.Bar-1)%max(h.Every, 1) == 0
+			})
+		}
+	}
+}
+
+// context describes a beat for an event
+func (r *Runner) context(event string, b metronome.Beat) Context {
+	c := Context{
+		Event:         event,
+		Bar:           b.Bar,
+		Beat:          b.Beat,
+		BPM:           b.BPM,
+		TimeSignature: fmt.Sprintf("%d/%d", b.Beats, b.BeatValue),
+		Time:          b.Time,
+	}
+	if song := r.metro.Song(); song != nil && b.Section < len(song.Sections) {
+		c.Section = song.Sections[b.Section].Name
+		c.SectionBar = b.SectionBar
+	}
+	return c
+}
+
+// fire runs the hooks that match when the context's time comes
+func (r *Runner) fire(hooks []config.Hook, c Context, match func(config.Hook) bool) {
+	var due []config.Hook
+	for _, h := range hooks {
+		if match == nil || match(h) {
+			due = append(due, h)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	// FIFOs are looked up now, since only Run touches the map
+	fifos := make([]*fifo, len(due))
+	for i, h := range due {
+		if h.FIFO != "" {
+			fifos[i] = r.fifo(h.FIFO)
+		}
+	}
+
+	fireAll := func() {
+		for i, h := range due {
+			if h.Run != "" {
+				r.runCommand(h.Run, c)
+			} else {
+				fifos[i].send(c.Line())
+			}
+		}
+	}
+	// Starting commands and queueing lines never waits, so hooks that are
+	// already due fire straight away
+	wait := time.Until(c.Time)
+	if wait <= 0 {
+		fireAll()
+		return
+	}
+
+	// Forget the timers that have fired
+	now := time.Now()
+	kept := r.pending[:0]
+	for _, p := range r.pending {
+		if p.at.After(now) {
+			kept = append(kept, p)
+		}
+	}
+	r.pending = append(kept, pending{timer: time.AfterFunc(wait, fireAll), at: c.Time})
+}
+
+// cancel drops the hooks still waiting for beats that won't sound now
+func (r *Runner) cancel() {
+	for _, p := range r.pending {
+		p.timer.Stop()
+	}
+	r.pending = r.pending[:0]
+}
+
+// runCommand starts a hook's command through the shell, reporting if it
+// fails without waiting for it
+func (r *Runner) runCommand(command string, c Context) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.Command(shell, flag, command)
+	cmd.Env = append(os.Environ(), c.Env()...)
+	if err := cmd.Start(); err != nil {
+		r.reportError(fmt.Errorf("on_%s hook: %w", c.Event, err))
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			r.reportError(fmt.Errorf("on_%s hook %q: %w", c.Event, short(command), err))
+		}
+	}()
+}
+
+// reportError passes an error on, if anyone is listening
+func (r *Runner) reportError(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// short trims a command for an error message
+func short(command string) string {
+	const limit = 40
+	command = strings.Join(strings.Fields(command), " ")
+	if len(command) > limit {
+		return command[:limit] + "…"
+	}
+	return command
+}
+
+// contains reports whether a bar is in a list
+func contains(bars []int, bar int) bool {
+	for _, b := range bars {
+		if b == bar {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+//go:build unix
+
+package hooks
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// line is a line read from a FIFO and when it arrived
+type line struct {
+	fields map[string]string
+	at     time.Time
+}
+
+// readFIFO makes a FIFO and reads lines from it until the test ends
+func readFIFO(t *testing.T) (string, <-chan line) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Opened for writing too, so reads wait for lines instead of ending
+	// while no writer has the FIFO open
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	lines := make(chan line, 64)
+	go func() {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := map[string]string{}
+			for _, kv := range strings.Fields(scanner.Text()) {
+				k, v, _ := strings.Cut(kv, "=")
+				fields[k] = v
+			}
+			lines <- line{fields: fields, at: time.Now()}
+		}
+	}()
+	return path, lines
+}
+
+// collect returns the lines that arrive within d
+func collect(lines <-chan line, d time.Duration) []line {
+	var got []line
+	timeout := time.After(d)
+	for {
+		select {
+		case l := <-lines:
+			got = append(got, l)
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+// events lists the event and bar of each line, sorted since hooks due at
+// the same time fire in no particular order
+func events(lines []line) []string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, l.fields["event"]+" "+l.fields["bar"])
+	}
+	sort.Strings(out)
+	return out
+}
+
+// clock drives a metronome from the test, placing each beat at a chosen
+// time and passing it to Run as it would arrive from a subscription
+type clock struct {
+	t     *testing.T
+	metro *metronome.Metronome
+	sub   <-chan metronome.Beat
+	beats chan metronome.Beat
+}
+
+func newClock(t *testing.T) *clock {
+	metro := metronome.New(120, metronome.CommonTimeSignatures[0])
+	metro.SetExternal(true)
+	sub, unsubscribe := metro.Subscribe()
+	t.Cleanup(unsubscribe)
+	return &clock{t: t, metro: metro, sub: sub, beats: make(chan metronome.Beat, 64)}
+}
+
+// beat announces beat index, counted from 0, to sound at the given time
+func (c *clock) beat(index int, at time.Time) metronome.Beat {
+	c.t.Helper()
+	c.metro.SyncBeat(index, at, 120)
+	select {
+	case b := <-c.sub:
+		c.beats <- b
+		return b
+	default:
+		c.t.Fatalf("beat %d wasn't announced", index)
+		return metronome.Beat{}
+	}
+}
+
+// run runs hooks until the returned function is called
+func run(t *testing.T, r *Runner, beats <-chan metronome.Beat) func() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		r.Run(beats, stop)
+		close(done)
+	}()
+	var once sync.Once
+	stopRun := func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+		})
+	}
+	t.Cleanup(stopRun)
+	return stopRun
+}
+
+func TestRunFiresHooksWhenTheirBeatSounds(t *testing.T) {
+	path, lines := readFIFO(t)
+	c := newClock(t)
+	r := New(c.metro, config.Hooks{
+		OnStart:    []config.Hook{{FIFO: path}},
+		OnStop:     []config.Hook{{FIFO: path}},
+		OnBar:      []config.Hook{{FIFO: path, Bars: []int{2}}},
+		OnDownbeat: []config.Hook{{FIFO: path, Every: 2}},
+	})
+	stopRun := run(t, r, c.beats)
+
+	c.metro.SyncStart()
+	t0 := time.Now().Add(50 * time.Millisecond)
+	const beatLen = 20 * time.Millisecond
+	for i := 0; i < 16; i++ {
+		c.beat(i, t0.Add(time.Duration(i)*beatLen))
+	}
+	got := collect(lines, 16*beatLen+150*time.Millisecond)
+
+	// Downbeats of bars 1 and 3, the bar hook on bar 2 only
+	want := []string{"bar 2", "downbeat 1", "downbeat 3", "start 1"}
+	if !reflect.DeepEqual(events(got), want) {
+		t.Fatalf("fired %q, want %q", events(got), want)
+	}
+	for _, l := range got {
+		due, err := time.Parse(time.RFC3339Nano, l.fields["time"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if early := due.Sub(l.at); early > 0 {
+			t.Errorf("%s hook fired %s before its beat", l.fields["event"], early)
+		}
+		if late := l.at.Sub(due); late > 30*time.Millisecond {
+			t.Errorf("%s hook fired %s after its beat", l.fields["event"], late)
+		}
+	}
+
+	// Stopping fires at once, with where playback got to
+	c.metro.SyncStop()
+	got = collect(lines, 100*time.Millisecond)
+	if len(got) != 1 || got[0].fields["event"] != "stop" || got[0].fields["bar"] != "4" || got[0].fields["beat"] != "4" {
+		t.Errorf("on stop, fired %v, want one stop at 4.4", got)
+	}
+
+	// Quitting while stopped fires nothing more
+	stopRun()
+	if got := collect(lines, 50*time.Millisecond); len(got) != 0 {
+		t.Errorf("fired %v after quitting", got)
+	}
+}
+
+func TestRunCancelsHooksWhenTheRunEnds(t *testing.T) {
+	path, lines := readFIFO(t)
+	c := newClock(t)
+	r := New(c.metro, config.Hooks{
+		OnStart:    []config.Hook{{FIFO: path}},
+		OnStop:     []config.Hook{{FIFO: path}},
+		OnDownbeat: []config.Hook{{FIFO: path}},
+	})
+	run(t, r, c.beats)
+
+	c.metro.SyncStart()
+	now := time.Now()
+	c.beat(0, now)
+	if got := collect(lines, 50*time.Millisecond); !reflect.DeepEqual(events(got), []string{"downbeat 1", "start 1"}) {
+		t.Fatalf("first beat fired %q", events(got))
+	}
+
+	// The next downbeat is still waiting to sound when playback stops
+	stale := c.beat(4, now.Add(150*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	c.metro.SyncStop()
+	if got := collect(lines, 50*time.Millisecond); !reflect.DeepEqual(events(got), []string{"stop 2"}) {
+		t.Fatalf("stopping fired %q, want only the stop", events(got))
+	}
+
+	// Nor does a beat from the ended run fire anything once playing again
+	c.metro.SyncStart()
+	c.beats <- stale
+
+	if got := collect(lines, 250*time.Millisecond); len(got) != 0 {
+		t.Errorf("after stopping, fired %q", events(got))
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := newClock(t)
+	r := New(c.metro, config.Hooks{
+		OnStart: []config.Hook{
+			{Run: `echo "$METROGNOME_EVENT $METROGNOME_BAR $METROGNOME_BEAT $METROGNOME_BPM $METROGNOME_TIME_SIGNATURE" > ` + out},
+			{Run: "exit 3"},
+		},
+	})
+	errs := make(chan error, 4)
+	r.OnError = func(err error) { errs <- err }
+	run(t, r, c.beats)
+
+	c.metro.SyncStart()
+	c.beat(0, time.Now())
+
+	select {
+	case err := <-errs:
+		if want := `on_start hook "exit 3": exit status 3`; err.Error() != want {
+			t.Errorf("error %q, want %q", err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the failing command wasn't reported")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, _ := os.ReadFile(out)
+		if string(data) == "start 1 1 120 4/4\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("command wrote %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestContext(t *testing.T) {
+	at := time.Date(2026, 10, 18, 20, 0, 0, 500, time.UTC)
+	c := Context{Event: EventSection, Bar: 9, Beat: 1, BPM: 96, TimeSignature: "6/8", Section: "big chorus", SectionBar: 1, Time: at}
+
+	if want := `event=section bar=9 beat=1 bpm=96 time_signature=6/8 time=2026-10-18T20:00:00.0000005Z section="big chorus" section_bar=1`; c.Line() != want {
+		t.Errorf("line %q, want %q", c.Line(), want)
+	}
+	want := []string{
+		"METROGNOME_EVENT=section",
+		"METROGNOME_BAR=9",
+		"METROGNOME_BEAT=1",
+		"METROGNOME_BPM=96",
+		"METROGNOME_TIME_SIGNATURE=6/8",
+		"METROGNOME_TIME=2026-10-18T20:00:00.0000005Z",
+		"METROGNOME_SECTION=big chorus",
+		"METROGNOME_SECTION_BAR=1",
+	}
+	if !reflect.DeepEqual(c.Env(), want) {
+		t.Errorf("env %q, want %q", c.Env(), want)
+	}
+
+	// Without a song there's no section
+	c.Section, c.SectionBar = "", 0
+	if strings.Contains(c.Line(), " section") || len(c.Env()) != 6 {
+		t.Errorf("section given without a song: %q, %q", c.Line(), c.Env())
+	}
+}
@@ -0,0 +1,226 @@
+package lansync
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Timing of the follower
+const (
+	pingInterval = time.Second     // How often the leader's clock is checked
+	quickPings   = 4               // Pings sent in quick succession on first hearing a leader
+	leaderGone   = 3 * time.Second // Silence after which the leader is taken to have left
+)
+
+// Follower plays along with a leader: it adopts the leader's tempo, meter
+// and bar phase, and places every beat on its own clock using the offset
+// worked out from pings
+type Follower struct {
+	metro *metronome.Metronome
+	conn  *net.UDPConn
+
+	// OnChange, if set, is called with a short description of changes of
+	// leader, such as "following 192.168.1.20:50123"
+	OnChange func(change string)
+}
+
+// NewFollower listens for a leader on addr, joining the group if it is a
+// multicast address, and puts the metronome under external control
+func NewFollower(metro *metronome.Metronome, addr string) (*Follower, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var conn *net.UDPConn
+	if udpAddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, udpAddr)
+	} else {
+		conn, err = net.ListenUDP("udp", udpAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	metro.SetExternal(true)
+	return &Follower{metro: metro, conn: conn}, nil
+}
+
+// received is a packet and when and where it arrived from
+type received struct {
+	packet
+	from *net.UDPAddr
+	at   time.Time
+}
+
+// Run follows the leader until stop is closed
+func (f *Follower) Run(stop <-chan struct{}) {
+	defer f.metro.SyncStop()
+
+	packets := make(chan received, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go f.read(packets, done)
+	defer f.conn.Close()
+
+	s := followState{f: f, next: -1}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	ping := time.NewTicker(pingInterval / quickPings)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case p := <-packets:
+			switch p.Type {
+			case typeState:
+				s.state(p)
+			case typePong:
+				s.offsets.add(unixTime(p.Sent), unixTime(p.Received), unixTime(p.Replied), p.at)
+				if len(s.offsets.samples) == quickPings {
+					ping.Reset(pingInterval)
+				}
+			}
+
+		case <-ping.C:
+			if s.leader != nil {
+				s.pingID++
+				send(f.conn, s.leader, packet{Type: typePing, ID: s.pingID, Sent: time.Now().UnixNano()})
+			}
+			if s.playing && time.Since(s.heard) > leaderGone {
+				s.playing = false
+				f.metro.SyncStop()
+				f.changed("leader went quiet")
+			}
+
+		case <-timer.C:
+		}
+
+		// Announce the beats that are close, then sleep until the next one is
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait, ok := s.announce(time.Now()); ok {
+			timer.Reset(wait)
+		}
+	}
+}
+
+// read passes on the packets that arrive until the connection is closed or
+// done is
+func (f *Follower) read(packets chan<- received, done <-chan struct{}) {
+	buf := make([]byte, maxPacket)
+	for {
+		n, from, err := f.conn.ReadFromUDP(buf)
+		at := time.Now()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		if p, ok := decode(buf[:n]); ok {
+			select {
+			case packets <- received{packet: p, from: from, at: at}:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// changed reports a change of leader, if anyone is listening
+func (f *Follower) changed(change string) {
+	if f.OnChange != nil {
+		f.OnChange(change)
+	}
+}
+
+// followState is what the follower knows of the leader
+type followState struct {
+	f       *Follower
+	leader  *net.UDPAddr
+	heard   time.Time // When the leader was last heard from
+	pingID  uint32
+	offsets offsetEstimate
+
+	playing bool
+	epoch   uint64
+	bpm     int
+	base    int           // Beat index the timing is measured from
+	baseAt  time.Time     // When that beat sounds on our clock
+	beatLen time.Duration // Time between beats
+	next    int           // Next beat index to announce
+}
+
+// state takes in the leader's latest state
+func (s *followState) state(p received) {
+	if s.leader == nil || s.leader.String() != p.from.String() {
+		s.leader = p.from
+		s.offsets = offsetEstimate{}
+		s.f.changed("following " + p.from.String())
+	}
+	s.heard = p.at
+
+	ts, err := metronome.ParseTimeSignature(p.TimeSignature)
+	if err == nil && ts.String() != s.f.metro.TimeSignature().String() {
+		// Changing the meter restarts the run, so play on from a fresh one
+		s.f.metro.SetTimeSignature(ts)
+		s.epoch = 0
+	}
+
+	if !p.Playing {
+		if s.playing {
+			s.playing = false
+			s.f.metro.SyncStop()
+		}
+		return
+	}
+	offset, ok := s.offsets.best()
+	if !ok || p.Bar < 1 || p.BPM < 1 || err != nil {
+		// Not ready to place beats yet
+		return
+	}
+
+	index := (p.Bar-1)*ts.Beats + p.Beat - 1
+	s.bpm = p.BPM
+	s.base = index
+	s.baseAt = unixTime(p.Time).Add(-offset.offset)
+	s.beatLen = time.Minute / time.Duration(p.BPM)
+
+	if !s.playing || p.Epoch != s.epoch || !s.f.metro.IsPlaying() {
+		// The leader started or restarted: begin a fresh run at this beat
+		s.f.metro.SyncStop()
+		s.f.metro.SyncStart()
+		s.playing = true
+		s.epoch = p.Epoch
+		s.next = index
+	}
+}
+
+// announce sends the metronome every beat due within the look-ahead window,
+// returning how long to wait before the next one is
+func (s *followState) announce(now time.Time) (time.Duration, bool) {
+	if !s.playing || s.beatLen <= 0 {
+		return 0, false
+	}
+	lookAhead := s.f.metro.LookAhead()
+	for {
+		at := s.baseAt.Add(time.Duration(s.next-s.base) * s.beatLen)
+		if ahead := at.Sub(now); ahead > lookAhead {
+			return ahead - lookAhead, true
+		}
+		// A beat well in the past is skipped rather than clicked late
+		if now.Sub(at) < s.beatLen/4 {
+			s.f.metro.SyncBeat(s.next, at, float64(s.bpm))
+		}
+		s.next++
+	}
+}
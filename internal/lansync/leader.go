@@ -0,0 +1,117 @@
+package lansync
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// heartbeat is how often the leader repeats its state between beats, so
+// followers notice stops and newcomers catch up
+const heartbeat = 500 * time.Millisecond
+
+// Leader sends a metronome's tempo, meter and beats to followers, and
+// answers their pings so they can line up their clocks with its own
+type Leader struct {
+	metro *metronome.Metronome
+	conn  *net.UDPConn
+	dest  *net.UDPAddr
+
+	// OnError, if set, is called when sending fails. It is called once
+	// until sending works again.
+	OnError func(err error)
+}
+
+// NewLeader creates a leader that sends to dest, a multicast group or a
+// single follower
+func NewLeader(metro *metronome.Metronome, dest string) (*Leader, error) {
+	addr, err := net.ResolveUDPAddr("udp", dest)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Leader{metro: metro, conn: conn, dest: addr}, nil
+}
+
+// Run sends state and answers pings until stop is closed
+func (l *Leader) Run(beats <-chan metronome.Beat, stop <-chan struct{}) {
+	defer l.conn.Close()
+	go l.answerPings()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	var epoch uint64
+	var run <-chan struct{} // The run the last beat belongs to
+	var last packet         // The latest state sent
+	failing := false
+	for {
+		select {
+		case <-stop:
+			return
+
+		case b := <-beats:
+			if b.Subdivision > 0 || b.IsCountIn() || !l.metro.IsScheduled(b) {
+				continue
+			}
+			if current := l.metro.Stopped(); current != run {
+				run = current
+				epoch++
+			}
+			last = packet{
+				Epoch:         epoch,
+				Playing:       true,
+				BPM:           b.BPM,
+				TimeSignature: fmt.Sprintf("%d/%d", b.Beats, b.BeatValue),
+				Bar:           b.Bar,
+				Beat:          b.Beat,
+				Time:          b.Time.UnixNano(),
+			}
+
+		case <-ticker.C:
+			if !l.metro.IsPlaying() || l.metro.Stopped() != run {
+				// Stopped, or restarted and not yet on a beat
+				last = packet{Epoch: epoch, Playing: l.metro.IsPlaying(), BPM: l.metro.BPM(), TimeSignature: l.metro.TimeSignature().String()}
+			}
+		}
+
+		last.Type = typeState
+		err := send(l.conn, l.dest, last)
+		if err != nil && !failing && l.OnError != nil {
+			l.OnError(fmt.Errorf("sending sync: %w", err))
+		}
+		failing = err != nil
+	}
+}
+
+// answerPings replies to each follower's ping with the leader's clock
+func (l *Leader) answerPings() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, from, err := l.conn.ReadFromUDP(buf)
+		received := time.Now()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		p, ok := decode(buf[:n])
+		if !ok || p.Type != typePing {
+			continue
+		}
+		send(l.conn, from, packet{
+			Type:     typePong,
+			ID:       p.ID,
+			Sent:     p.Sent,
+			Received: received.UnixNano(),
+			Replied:  time.Now().UnixNano(),
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package lansync
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultGroup is the multicast group leaders send to unless told otherwise
+const DefaultGroup = "239.77.77.77:7878"
+
+// version is the packet format spoken; packets of other versions are ignored
+const version = 1
+
+// Packet types
+const (
+	typeState = "state" // Leader to followers: tempo, meter and the latest beat
+	typePing  = "ping"  // Follower to leader: asks for the leader's clock
+	typePong  = "pong"  // Leader to follower: answers a ping
+)
+
+// packet is a message between a leader and its followers. Times are Unix
+// nanoseconds on the sender's clock.
+type packet struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+
+	// State
+	Epoch         uint64 `json:"epoch,omitempty"` // Changes whenever the leader restarts
+	Playing       bool   `json:"playing,omitempty"`
+	BPM           int    `json:"bpm,omitempty"`
+	TimeSignature string `json:"time_signature,omitempty"`
+	Bar           int    `json:"bar,omitempty"` // 0 when no beat has been played since the last restart
+	Beat          int    `json:"beat,omitempty"`
+	Time          int64  `json:"time,omitempty"` // When the beat sounds
+
+	// Ping and pong
+	ID       uint32 `json:"id,omitempty"`
+	Sent     int64  `json:"sent,omitempty"`     // When the follower sent the ping
+	Received int64  `json:"received,omitempty"` // When the leader received it
+	Replied  int64  `json:"replied,omitempty"`  // When the leader answered
+}
+
+// maxPacket is the largest packet read
+const maxPacket = 2048
+
+// send writes a packet to addr, or to the connected peer if addr is nil
+func send(conn *net.UDPConn, addr *net.UDPAddr, p packet) error {
+	p.Version = version
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if addr == nil {
+		_, err = conn.Write(data)
+	} else {
+		_, err = conn.WriteToUDP(data, addr)
+	}
+	return err
+}
+
+// decode reads a packet, reporting false for anything that isn't one
+func decode(data []byte) (packet, bool) {
+	var p packet
+	if err := json.Unmarshal(data, &p); err != nil || p.Version != version {
+		return packet{}, false
+	}
+	return p, true
+}
+
+// sample is one ping exchange with the leader
+type sample struct {
+	offset time.Duration // Leader's clock minus ours
+	delay  time.Duration // Round trip time, not counting the leader's turnaround
+}
+
+// maxSamples is how many recent ping exchanges the offset is chosen from
+const maxSamples = 8
+
+// offsetEstimate works out the leader's clock offset the way NTP does: each
+// ping exchange gives an offset that is off by at most half its round trip,
+// so the offset of the quickest recent exchange is trusted
+type offsetEstimate struct {
+	samples []sample
+}
+
+// add records a ping exchange: sent and replied are our clock, received and
+// answered the leader's
+func (e *offsetEstimate) add(sent, received, answered, replied time.Time) {
+	s := sample{
+		offset: (received.Sub(sent) + answered.Sub(replied)) / 2,
+		delay:  replied.Sub(sent) - answered.Sub(received),
+	}
+	e.samples = append(e.samples, s)
+	if len(e.samples) > maxSamples {
+		e.samples = e.samples[len(e.samples)-maxSamples:]
+	}
+}
+
+// best returns the offset of the quickest exchange, or false before any
+func (e *offsetEstimate) best() (sample, bool) {
+	if len(e.samples) == 0 {
+		return sample{}, false
+	}
+	sorted := append([]sample(nil), e.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].delay < sorted[j].delay })
+	return sorted[0], true
+}
+
+// unixTime converts Unix nanoseconds to a time
+func unixTime(ns int64) time.Time {
+	return time.Unix(0, ns)
+}
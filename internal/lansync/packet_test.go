@@ -0,0 +1,59 @@
+package lansync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOffsetEstimate(t *testing.T) {
+	t0 := time.Now()
+	leader := t0.Add(5 * time.Second) // The leader's clock runs 5s ahead
+
+	var e offsetEstimate
+	if _, ok := e.best(); ok {
+		t.Fatal("an offset before any exchange")
+	}
+
+	// 10ms each way with 1ms at the leader: the offset comes out exact
+	e.add(t0, leader.Add(10*time.Millisecond), leader.Add(11*time.Millisecond), t0.Add(21*time.Millisecond))
+	got, _ := e.best()
+	if got.offset != 5*time.Second || got.delay != 20*time.Millisecond {
+		t.Errorf("symmetric exchange gave offset %s and delay %s, want 5s and 20ms", got.offset, got.delay)
+	}
+
+	// 30ms out and 2ms back: off by half the difference, but slower, so
+	// the first exchange is still trusted
+	e.add(t0, leader.Add(30*time.Millisecond), leader.Add(30*time.Millisecond), t0.Add(32*time.Millisecond))
+	if s := e.samples[1]; s.offset != 5*time.Second+14*time.Millisecond || s.delay != 32*time.Millisecond {
+		t.Errorf("lopsided exchange gave offset %s and delay %s, want 5.014s and 32ms", s.offset, s.delay)
+	}
+	if got, _ := e.best(); got.offset != 5*time.Second {
+		t.Errorf("best offset %s, want the quickest exchange's 5s", got.offset)
+	}
+
+	// A quicker exchange wins, then ages out once enough others follow
+	e.add(t0, leader.Add(2*time.Millisecond), leader.Add(2*time.Millisecond), t0.Add(5*time.Millisecond))
+	if got, _ := e.best(); got.delay != 5*time.Millisecond {
+		t.Errorf("best delay %s, want the newest exchange's 5ms", got.delay)
+	}
+	for i := 0; i < maxSamples; i++ {
+		e.add(t0, leader.Add(20*time.Millisecond), leader.Add(20*time.Millisecond), t0.Add(40*time.Millisecond))
+	}
+	if len(e.samples) != maxSamples {
+		t.Errorf("kept %d samples, want %d", len(e.samples), maxSamples)
+	}
+	if got, _ := e.best(); got.delay != 40*time.Millisecond {
+		t.Errorf("best delay %s after the quick exchange aged out, want 40ms", got.delay)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if p, ok := decode([]byte(`{"v":1,"type":"state","bpm":120,"time_signature":"3/4"}`)); !ok || p.Type != typeState || p.BPM != 120 || p.TimeSignature != "3/4" {
+		t.Errorf("decoded %+v, %v", p, ok)
+	}
+	for _, data := range []string{``, `nope`, `{"v":2,"type":"state"}`, `{"type":"state"}`} {
+		if p, ok := decode([]byte(data)); ok {
+			t.Errorf("decoded %q as %+v, want it ignored", data, p)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package lansync
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// position is where a beat falls in the song
+type position struct{ bar, beat int }
+
+func TestLeaderFollowerLoopback(t *testing.T) {
+	const bpm = 240
+	leaderMetro := metronome.New(bpm, metronome.CommonTimeSignatures[1]) // 3/4
+	defer leaderMetro.Stop()
+	followerMetro := metronome.New(100, metronome.CommonTimeSignatures[0])
+
+	f, err := NewFollower(followerMetro, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLeader(leaderMetro, f.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !followerMetro.External() {
+		t.Error("the follower's metronome isn't under external control")
+	}
+
+	sent, unsubscribeSent := leaderMetro.Subscribe()
+	defer unsubscribeSent()
+	led, unsubscribeLed := leaderMetro.Subscribe()
+	defer unsubscribeLed()
+	followed, unsubscribeFollowed := followerMetro.Subscribe()
+	defer unsubscribeFollowed()
+
+	stop := make(chan struct{})
+	leaderDone := make(chan struct{})
+	followerDone := make(chan struct{})
+	go func() {
+		l.Run(sent, stop)
+		close(leaderDone)
+	}()
+	go func() {
+		f.Run(stop)
+		close(followerDone)
+	}()
+	leaderMetro.Start()
+
+	// The follower needs a few pings before it places beats
+	leaderTimes := map[position]time.Time{}
+	var followerBeats []metronome.Beat
+	deadline := time.After(3 * time.Second)
+	var settle <-chan time.Time
+collect:
+	for {
+		select {
+		case b := <-led:
+			if b.Subdivision == 0 {
+				leaderTimes[position{b.Bar, b.Beat}] = b.Time
+			}
+		case b := <-followed:
+			followerBeats = append(followerBeats, b)
+			if len(followerBeats) == 4 {
+				// Let the leader catch up to the beats already announced
+				settle = time.After(2 * metronome.DefaultLookAhead)
+			}
+		case <-settle:
+			break collect
+		case <-deadline:
+			t.Fatalf("the follower announced %d beats within 3s, want 4", len(followerBeats))
+		}
+	}
+
+	for _, b := range followerBeats {
+		if b.BPM != bpm || b.Beats != 3 || b.BeatValue != 4 {
+			t.Errorf("follower played %d/%d at %d BPM, want the leader's 3/4 at %d", b.Beats, b.BeatValue, b.BPM, bpm)
+		}
+		want, ok := leaderTimes[position{b.Bar, b.Beat}]
+		if !ok {
+			t.Errorf("follower played %d.%d, which the leader didn't", b.Bar, b.Beat)
+			continue
+		}
+		// Same machine, so the offset is close to nothing
+		if d := b.Time.Sub(want); d < -5*time.Millisecond || d > 5*time.Millisecond {
+			t.Errorf("follower's %d.%d is %s off the leader's", b.Bar, b.Beat, d)
+		}
+	}
+
+	// The heartbeat tells the follower the leader stopped
+	leaderMetro.Stop()
+	stopBy := time.Now().Add(2 * heartbeat)
+	for followerMetro.IsPlaying() {
+		if time.Now().After(stopBy) {
+			t.Fatal("the follower still plays after the leader stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stop)
+	for _, done := range []chan struct{}{leaderDone, followerDone} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run didn't return after stop closed")
+		}
+	}
+}
+
+func TestFollowerReadExitsWhenDone(t *testing.T) {
+	f, err := NewFollower(metronome.New(120, metronome.CommonTimeSignatures[0]), "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.conn.Close()
+
+	// Nobody takes the packet, as after Run returned
+	packets := make(chan received)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		f.read(packets, done)
+		close(exited)
+	}()
+
+	conn, err := net.DialUDP("udp", nil, f.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := send(conn, nil, packet{Type: typeState}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("read is stuck handing over a packet nobody will take")
+	}
+}
@@ -0,0 +1,70 @@
+package link
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// Measurement of a session's ghost time
+const (
+	measurePoints  = 100                   // Estimates gathered before settling on one
+	measureTimeout = 50 * time.Millisecond // Wait for a pong before pinging again
+	measureTries   = 5                     // Unanswered pings before giving up
+	maxPingBody    = 32                    // Largest ping body echoed back in a pong
+)
+
+// measurement works out how a session's ghost time relates to our host
+// time by pinging one of its peers back and forth. Each exchange gives an
+// estimate that is off by at most half a round trip; the median of many is
+// taken, which shrugs off the odd exchange delayed by the network.
+type measurement struct {
+	session nodeID
+	to      *net.UDPAddr
+	data    []float64 // Estimates of ghost time minus host time in microseconds
+	tries   int       // Pings sent without an answer
+	due     time.Time // When to ping again if no pong comes
+}
+
+// ping builds the next ping, sent at host time now. prevGhost is the ghost
+// time from the last pong, 0 for the first ping.
+func (m *measurement) ping(now int64, prevGhost int64, at time.Time) []byte {
+	body := appendInt64s(nil, keyHostTime, now)
+	if prevGhost != 0 {
+		body = appendInt64s(body, keyPrevGhostTime, prevGhost)
+	}
+	m.due = at.Add(measureTimeout)
+	return measureMessage(msgPing, body)
+}
+
+// pong takes in a pong that arrived at host time received, returning the
+// ghost time it carried
+func (m *measurement) pong(body payload, received int64) int64 {
+	m.tries = 0
+	ghost := body.time(keyGhostTime)
+	prevGhost := body.time(keyPrevGhostTime)
+	sent := body.time(keyHostTime)
+	if ghost != 0 && sent != 0 {
+		m.data = append(m.data, float64(ghost)-float64(sent+received)/2)
+		if prevGhost != 0 {
+			m.data = append(m.data, float64(ghost+prevGhost)/2-float64(sent))
+		}
+	}
+	return ghost
+}
+
+// done reports whether enough estimates have been gathered
+func (m *measurement) done() bool {
+	return len(m.data) > measurePoints
+}
+
+// intercept returns the median estimate of ghost time minus host time
+func (m *measurement) intercept() int64 {
+	sorted := append([]float64(nil), m.data...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return int64(sorted[n/2])
+	}
+	return int64((sorted[n/2-1] + sorted[n/2]) / 2)
+}
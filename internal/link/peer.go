@@ -0,0 +1,574 @@
+package link
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Timing of the peer
+const (
+	broadcastInterval = 250 * time.Millisecond // How often our state is announced
+	pollInterval      = 10 * time.Millisecond  // How often the metronome and timers are checked
+	remeasureInterval = 30 * time.Second       // How often the ghost time of a joined session is measured again
+	retryInterval     = 5 * time.Second        // Wait before measuring a session again after failing
+	sessionEpsilon    = 500_000                // Microseconds within which two sessions count as equally old
+)
+
+// Peer takes part in a Link session: it finds the other peers on the
+// network, joins the oldest session among them, and shares its tempo. Each
+// session keeps a ghost clock, which every member measures its own clock
+// against, and a timeline mapping ghost time to beats. Bars start wherever
+// the timeline's beat count is a multiple of the bar length, so every peer's
+// downbeats line up whatever its meter.
+type Peer struct {
+	metro    *metronome.Metronome
+	id       nodeID
+	group    *net.UDPAddr
+	mcast    *net.UDPConn // Hears announcements to the group
+	conn     *net.UDPConn // Sends announcements and hears responses
+	pinger   *net.UDPConn // Answers and sends measurement pings
+	endpoint *net.UDPAddr // Where other peers reach the pinger
+	epoch    time.Time    // Origin of the host clock
+
+	mu        sync.Mutex
+	session   nodeID
+	intercept int64 // Session ghost time minus host time in microseconds
+
+	// OnChange, if set, is called with a short description of changes to
+	// the session, such as "2 Link peers"
+	OnChange func(change string)
+
+	// OnError, if set, is called when announcing fails. It is called once
+	// until announcing works again.
+	OnError func(err error)
+}
+
+// NewPeer joins the Link group and starts a session of its own, placing the
+// metronome's beats on the session's timeline from then on
+func NewPeer(metro *metronome.Metronome) (*Peer, error) {
+	group, err := net.ResolveUDPAddr("udp4", Group)
+	if err != nil {
+		return nil, err
+	}
+	p := &Peer{metro: metro, group: group, epoch: time.Now()}
+	if _, err := rand.Read(p.id[:]); err != nil {
+		return nil, err
+	}
+	p.session = p.id
+
+	if p.mcast, err = net.ListenMulticastUDP("udp4", nil, group); err != nil {
+		return nil, err
+	}
+	if p.conn, err = net.ListenUDP("udp4", &net.UDPAddr{}); err != nil {
+		p.mcast.Close()
+		return nil, err
+	}
+	if p.pinger, err = net.ListenUDP("udp4", &net.UDPAddr{}); err != nil {
+		p.mcast.Close()
+		p.conn.Close()
+		return nil, err
+	}
+	p.endpoint = &net.UDPAddr{IP: localIP(group), Port: p.pinger.LocalAddr().(*net.UDPAddr).Port}
+
+	metro.SetLinked(true)
+	return p, nil
+}
+
+// localIP returns the address other peers reach this machine on: the one
+// traffic to the group leaves from
+func localIP(group *net.UDPAddr) net.IP {
+	if c, err := net.DialUDP("udp4", nil, group); err == nil {
+		defer c.Close()
+		if ip := c.LocalAddr().(*net.UDPAddr).IP; !ip.IsUnspecified() {
+			return ip
+		}
+	}
+	return net.IPv4(127, 0, 0, 1)
+}
+
+// host returns a time on the host clock in microseconds
+func (p *Peer) host(t time.Time) int64 {
+	return t.Sub(p.epoch).Microseconds()
+}
+
+// hostTime converts the host clock back to a time
+func (p *Peer) hostTime(micros int64) time.Time {
+	return p.epoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+// xform returns the session and how its ghost time relates to host time
+func (p *Peer) xform() (nodeID, int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session, p.intercept
+}
+
+// setXForm moves to a session with the given ghost time
+func (p *Peer) setXForm(session nodeID, intercept int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.session, p.intercept = session, intercept
+}
+
+// received is a message and when and where it arrived from
+type received struct {
+	data    []byte
+	from    *net.UDPAddr
+	at      time.Time
+	measure bool // Arrived on the pinger rather than from discovery
+}
+
+// Run takes part in the session until stop is closed, then says goodbye
+func (p *Peer) Run(stop <-chan struct{}) {
+	defer p.metro.SetLinked(false)
+	defer p.pinger.Close()
+	defer p.conn.Close()
+	defer p.mcast.Close()
+
+	packets := make(chan received, 64)
+	go p.read(p.mcast, false, packets, stop)
+	go p.read(p.conn, false, packets, stop)
+	go p.read(p.pinger, true, packets, stop)
+
+	s := newSessionState(p)
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	s.broadcast(time.Now())
+
+	for {
+		select {
+		case <-stop:
+			p.conn.WriteToUDP(discoveryMessage(msgByeBye, p.id, nil), p.group)
+			return
+		case r := <-packets:
+			s.handle(r)
+		case now := <-tick.C:
+			s.poll(now)
+		}
+	}
+}
+
+// read passes on the messages that arrive on a connection until it is
+// closed. Pings are answered straight away so the time in the pong is as
+// close as can be to when the ping arrived.
+func (p *Peer) read(conn *net.UDPConn, measure bool, packets chan<- received, stop <-chan struct{}) {
+	buf := make([]byte, maxMessage)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		at := time.Now()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		if measure && p.answer(buf[:n], from) {
+			continue
+		}
+		select {
+		case packets <- received{data: append([]byte(nil), buf[:n]...), from: from, at: at, measure: measure}:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// answer replies to a ping with the session's ghost time, echoing the
+// ping's body back, and reports whether the message was one
+func (p *Peer) answer(data []byte, from *net.UDPAddr) bool {
+	kind, raw, _, ok := parseMeasure(data)
+	if !ok || kind != msgPing {
+		return false
+	}
+	if len(raw) > maxPingBody {
+		return true
+	}
+	session, intercept := p.xform()
+	body := appendEntry(nil, keySession, session[:])
+	body = appendInt64s(body, keyGhostTime, p.host(time.Now())+intercept)
+	p.pinger.WriteToUDP(measureMessage(msgPong, append(body, raw...)), from)
+	return true
+}
+
+// changed reports a change to the session, if anyone is listening
+func (p *Peer) changed(change string) {
+	if p.OnChange != nil {
+		p.OnChange(change)
+	}
+}
+
+// remotePeer is another peer as last heard from
+type remotePeer struct {
+	state   peerState
+	expires time.Time
+}
+
+// otherSession is a session found on the network that we aren't part of
+type otherSession struct {
+	timeline timeline
+	settled  bool      // Measured and found younger than ours
+	retry    time.Time // When a failed measurement may be tried again
+}
+
+// sessionState is what the peer knows of the network, kept by Run
+type sessionState struct {
+	p        *Peer
+	session  nodeID
+	timeline timeline
+	peers    map[nodeID]*remotePeer
+	others   map[nodeID]*otherSession
+	members  int // Other peers in our session when last reported
+
+	measuring    *measurement
+	lastMeasured time.Time // When our session's ghost time was last measured
+	lastSent     time.Time // When our state was last announced
+	failing      bool      // Whether announcing is failing
+
+	bpm     int             // Tempo the metronome was last seen at
+	run     <-chan struct{} // Run of the metronome beats are being announced for, nil when stopped
+	quantum int             // Beats in a bar
+	base    int64           // Session beat that the run's beat 0 falls on
+	next    int             // Next beat of the run to announce
+}
+
+// newSessionState starts a session of our own at the metronome's tempo
+func newSessionState(p *Peer) *sessionState {
+	s := &sessionState{
+		p:       p,
+		session: p.id,
+		peers:   map[nodeID]*remotePeer{},
+		others:  map[nodeID]*otherSession{},
+		bpm:     p.metro.BPM(),
+	}
+	s.timeline = newTimeline(float64(s.bpm), 0, s.ghost(time.Now()))
+	return s
+}
+
+// ghost returns the session's ghost time at a time
+func (s *sessionState) ghost(t time.Time) int64 {
+	_, intercept := s.p.xform()
+	return s.p.host(t) + intercept
+}
+
+// timeAt returns when a session beat, in microbeats, falls
+func (s *sessionState) timeAt(beats int64) time.Time {
+	_, intercept := s.p.xform()
+	return s.p.hostTime(s.timeline.fromBeats(beats) - intercept)
+}
+
+// beatAt returns the first whole session beat at or after a time
+func (s *sessionState) beatAt(t time.Time) int64 {
+	return -floorDiv(-s.timeline.toBeats(s.ghost(t)), 1e6)
+}
+
+// state returns what we announce about ourselves
+func (s *sessionState) state() peerState {
+	return peerState{session: s.session, timeline: s.timeline, endpoint: s.p.endpoint}
+}
+
+// broadcast announces our state to the group
+func (s *sessionState) broadcast(now time.Time) {
+	s.lastSent = now
+	_, err := s.p.conn.WriteToUDP(discoveryMessage(msgAlive, s.p.id, appendState(nil, s.state())), s.p.group)
+	if err != nil && !s.failing && s.p.OnError != nil {
+		s.p.OnError(fmt.Errorf("announcing to Link peers: %w", err))
+	}
+	s.failing = err != nil
+}
+
+// handle acts on a message from another peer
+func (s *sessionState) handle(r received) {
+	if r.measure {
+		s.pong(r)
+		return
+	}
+	kind, id, body, ok := parseDiscovery(r.data)
+	if !ok || id == s.p.id {
+		return
+	}
+	switch kind {
+	case msgAlive:
+		s.p.conn.WriteToUDP(discoveryMessage(msgResponse, s.p.id, appendState(nil, s.state())), r.from)
+		fallthrough
+	case msgResponse:
+		if state, ok := body.state(); ok {
+			s.saw(id, state, r.at)
+		}
+	case msgByeBye:
+		delete(s.peers, id)
+		s.report()
+	}
+}
+
+// saw takes in another peer's state
+func (s *sessionState) saw(id nodeID, state peerState, at time.Time) {
+	s.peers[id] = &remotePeer{state: state, expires: at.Add(ttl * time.Second)}
+	if state.session == s.session {
+		// The timeline set furthest along the beats wins
+		if state.timeline.beatOrigin > s.timeline.beatOrigin {
+			s.adopt(state.timeline, at)
+		}
+	} else if o, ok := s.others[state.session]; !ok {
+		s.others[state.session] = &otherSession{timeline: state.timeline}
+	} else if state.timeline.beatOrigin > o.timeline.beatOrigin {
+		o.timeline = state.timeline
+	}
+	s.report()
+	s.measureNext(at)
+}
+
+// report tells of changes to how many peers share our session
+func (s *sessionState) report() {
+	members := 0
+	for _, peer := range s.peers {
+		if peer.state.session == s.session {
+			members++
+		}
+	}
+	if members == s.members {
+		return
+	}
+	s.members = members
+	switch members {
+	case 0:
+		s.p.changed("no Link peers")
+	case 1:
+		s.p.changed("1 Link peer")
+	default:
+		s.p.changed(fmt.Sprintf("%d Link peers", members))
+	}
+}
+
+// adopt takes on a timeline from the session, bringing the metronome's
+// tempo along
+func (s *sessionState) adopt(tl timeline, now time.Time) {
+	s.timeline = tl
+	bpm := max(metronome.MinBPM, min(int(math.Round(tl.bpm())), metronome.MaxBPM))
+	if bpm != s.bpm {
+		s.bpm = bpm
+		s.p.metro.SetBPM(bpm)
+		s.p.changed(fmt.Sprintf("Link tempo %d", bpm))
+	}
+	s.realign(now)
+}
+
+// commit puts a tempo set here on the session's timeline, carrying on from
+// the beat reached now
+func (s *sessionState) commit(bpm int, now time.Time) {
+	g := s.ghost(now)
+	beat := max(s.timeline.toBeats(g), s.timeline.beatOrigin+1)
+	s.timeline = newTimeline(float64(bpm), beat, g)
+	s.bpm = bpm
+	s.realign(now)
+	s.broadcast(now)
+}
+
+// measureNext starts measuring a session if none is being measured: a
+// newly found one first, else our own if it is due again
+func (s *sessionState) measureNext(now time.Time) {
+	if s.measuring != nil {
+		return
+	}
+	for id, o := range s.others {
+		if !o.settled && !now.Before(o.retry) && s.measure(id, now) {
+			return
+		}
+	}
+	if s.session != s.p.id && now.Sub(s.lastMeasured) > remeasureInterval {
+		if !s.measure(s.session, now) {
+			s.lastMeasured = now
+		}
+	}
+}
+
+// measure starts measuring a session through one of its peers, reporting
+// false if none can be reached
+func (s *sessionState) measure(session nodeID, now time.Time) bool {
+	for _, peer := range s.peers {
+		if peer.state.session == session && peer.state.endpoint != nil {
+			s.measuring = &measurement{session: session, to: peer.state.endpoint}
+			s.ping(0, now)
+			return true
+		}
+	}
+	return false
+}
+
+// ping sends the measurement's next ping
+func (s *sessionState) ping(prevGhost int64, now time.Time) {
+	m := s.measuring
+	m.tries++
+	s.p.pinger.WriteToUDP(m.ping(s.p.host(now), prevGhost, now), m.to)
+}
+
+// pong takes in an answer to a measurement ping
+func (s *sessionState) pong(r received) {
+	m := s.measuring
+	kind, _, body, ok := parseMeasure(r.data)
+	if m == nil || !ok || kind != msgPong {
+		return
+	}
+	if session, _ := body.session(); session != m.session {
+		// The peer has moved to another session since
+		s.measured(false, r.at)
+		return
+	}
+	ghost := m.pong(body, s.p.host(r.at))
+	if m.done() {
+		s.measured(true, r.at)
+		return
+	}
+	s.ping(ghost, time.Now())
+}
+
+// measured acts on the end of a measurement, joining the session measured
+// if it is older than ours, or as old and with the lower ID
+func (s *sessionState) measured(ok bool, now time.Time) {
+	m := s.measuring
+	s.measuring = nil
+	o := s.others[m.session]
+
+	switch {
+	case m.session == s.session:
+		s.lastMeasured = now
+		if ok {
+			s.p.setXForm(s.session, m.intercept())
+			s.realign(now)
+		}
+	case o == nil:
+	case !ok:
+		o.retry = now.Add(retryInterval)
+	default:
+		_, intercept := s.p.xform()
+		diff := m.intercept() - intercept
+		if diff > sessionEpsilon || (diff > -sessionEpsilon && diff < sessionEpsilon && m.session.less(s.session)) {
+			delete(s.others, m.session)
+			s.session = m.session
+			s.lastMeasured = now
+			s.p.setXForm(m.session, m.intercept())
+			s.p.changed("joined a Link session")
+			s.adopt(o.timeline, now)
+			s.report()
+			s.broadcast(now)
+		} else {
+			o.settled = true
+		}
+	}
+	s.measureNext(now)
+}
+
+// poll keeps up with the metronome, drops peers that have gone quiet, and
+// announces beats and state that are due
+func (s *sessionState) poll(now time.Time) {
+	for id, peer := range s.peers {
+		if now.After(peer.expires) {
+			delete(s.peers, id)
+		}
+	}
+	for id := range s.others {
+		if !s.hasPeers(id) {
+			delete(s.others, id)
+		}
+	}
+	s.report()
+
+	if bpm := s.p.metro.BPM(); bpm != s.bpm {
+		s.commit(bpm, now)
+	}
+
+	if m := s.measuring; m != nil && now.After(m.due) {
+		if m.tries >= measureTries {
+			s.measured(false, now)
+		} else {
+			s.ping(0, now)
+		}
+	}
+	s.measureNext(now)
+
+	if now.Sub(s.lastSent) >= broadcastInterval {
+		s.broadcast(now)
+	}
+	s.announce(now)
+}
+
+// hasPeers reports whether any peer we know of is in a session
+func (s *sessionState) hasPeers(session nodeID) bool {
+	for _, peer := range s.peers {
+		if peer.state.session == session {
+			return true
+		}
+	}
+	return false
+}
+
+// realign picks up from the beat the timeline has reached after it changed
+func (s *sessionState) realign(now time.Time) {
+	if s.run == nil {
+		return
+	}
+	beat := s.beatAt(now)
+	index := int(beat - s.base)
+	if index < 0 || index > s.next+s.quantum {
+		// The beats jumped, as on joining another session: count bars
+		// afresh from the bar we're in
+		s.base = floorDiv(beat, int64(s.quantum)) * int64(s.quantum)
+		index = int(beat - s.base)
+	}
+	// A beat just announced may still be waiting to sound; don't repeat it
+	if index < s.next-1 || index > s.next {
+		s.next = index
+	}
+}
+
+// announce sends the metronome every beat due within the look-ahead window.
+// A fresh start waits for the next bar line of the session; a restart after
+// a change carries on in the same place in the bar.
+func (s *sessionState) announce(now time.Time) {
+	metro := s.p.metro
+	if !metro.IsPlaying() {
+		s.run = nil
+		return
+	}
+	lookAhead := metro.LookAhead()
+	if run := metro.Stopped(); run != s.run {
+		quantum := int64(metro.TimeSignature().Beats)
+		switch beat := s.beatAt(now); {
+		case s.run == nil:
+			s.base = -floorDiv(-s.beatAt(now.Add(lookAhead)), quantum) * quantum
+		case quantum != int64(s.quantum) || beat < s.base:
+			s.base = floorDiv(beat, quantum) * quantum
+		}
+		s.run = run
+		s.quantum = int(quantum)
+		s.next = max(0, int(s.beatAt(now)-s.base))
+	}
+
+	beatLen := time.Duration(s.timeline.tempo) * time.Microsecond
+	for {
+		at := s.timeAt((s.base + int64(s.next)) * 1e6)
+		if at.Sub(now) > lookAhead {
+			return
+		}
+		// A beat well in the past is skipped rather than clicked late
+		if now.Sub(at) < beatLen/4 {
+			metro.SyncBeat(s.next, at, s.timeline.bpm())
+		}
+		s.next++
+	}
+}
+
+// floorDiv divides rounding down, even for negative numbers
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
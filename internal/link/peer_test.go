@@ -0,0 +1,107 @@
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// runPeer starts a peer on the Link group, skipping the test where there is
+// no multicast, and returns a function that stops it
+func runPeer(t *testing.T, metro *metronome.Metronome) (*Peer, func()) {
+	t.Helper()
+	p, err := NewPeer(metro)
+	if err != nil {
+		t.Skipf("no multicast here: %v", err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		p.Run(stop)
+		close(done)
+	}()
+	return p, func() {
+		close(stop)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Run didn't return after stop closed")
+		}
+	}
+}
+
+func TestTwoPeersLoopback(t *testing.T) {
+	metroA := metronome.New(120, metronome.CommonTimeSignatures[0])
+	metroB := metronome.New(90, metronome.CommonTimeSignatures[0])
+	a, stopA := runPeer(t, metroA)
+	defer stopA()
+	b, stopB := runPeer(t, metroB)
+	defer stopB()
+	if !metroA.Linked() || !metroB.Linked() {
+		t.Fatal("peers didn't link their metronomes")
+	}
+
+	// One measures the other's session and joins it, taking on its tempo
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sessionA, _ := a.xform()
+		sessionB, _ := b.xform()
+		if sessionA == sessionB && metroA.BPM() == metroB.BPM() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("peers still apart after 5s: sessions %s and %s at %d and %d BPM", sessionA, sessionB, metroA.BPM(), metroB.BPM())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if bpm := metroA.BPM(); bpm != 120 && bpm != 90 {
+		t.Errorf("session tempo %d, want one of the peers' own", bpm)
+	}
+	beatLen := time.Minute / time.Duration(metroA.BPM())
+
+	beatsA, unsubscribeA := metroA.Subscribe()
+	defer unsubscribeA()
+	beatsB, unsubscribeB := metroB.Subscribe()
+	defer unsubscribeB()
+	defer metroA.Stop()
+	defer metroB.Stop()
+
+	// Starting at different times, B waits for the session's next bar
+	metroA.Start()
+	time.Sleep(beatLen + beatLen/3)
+	metroB.Start()
+	time.Sleep(6 * beatLen)
+
+	var gotA, gotB []metronome.Beat
+	for collecting := true; collecting; {
+		select {
+		case beat := <-beatsA:
+			gotA = append(gotA, beat)
+		case beat := <-beatsB:
+			gotB = append(gotB, beat)
+		default:
+			collecting = false
+		}
+	}
+	if len(gotB) < 2 {
+		t.Fatalf("B played %d beats, want at least 2", len(gotB))
+	}
+	if gotB[0].Beat != 1 {
+		t.Errorf("B started on beat %d, want the downbeat", gotB[0].Beat)
+	}
+	for _, beatB := range gotB {
+		matched := false
+		for _, beatA := range gotA {
+			if d := beatB.Time.Sub(beatA.Time); d > -3*time.Millisecond && d < 3*time.Millisecond {
+				matched = true
+				if beatA.Beat != beatB.Beat {
+					t.Errorf("B's beat %d sounds with A's beat %d", beatB.Beat, beatA.Beat)
+				}
+			}
+		}
+		if !matched {
+			t.Errorf("B's %d.%d doesn't sound with any of A's beats", beatB.Bar, beatB.Beat)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package link
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net"
+)
+
+// Group is the multicast group Link peers announce themselves on
+const Group = "224.76.78.75:20808"
+
+// Message headers, each ending in the protocol version
+var (
+	discoveryHeader = []byte("_asdp_v\x01")
+	measureHeader   = []byte("_link_v\x01")
+)
+
+// Discovery message types
+const (
+	msgAlive    = 1 // Sent to the group: here is my state
+	msgResponse = 2 // Sent to a peer that announced itself: here is mine
+	msgByeBye   = 3 // Sent to the group on leaving
+)
+
+// Measurement message types
+const (
+	msgPing = 1
+	msgPong = 2
+)
+
+// ttl is how many seconds a peer's announcement holds for
+const ttl = 5
+
+// maxMessage is the largest message sent or read
+const maxMessage = 512
+
+// Payload entry keys, four characters packed into a big-endian integer
+const (
+	keyTimeline      = 't'<<24 | 'm'<<16 | 'l'<<8 | 'n'
+	keySession       = 's'<<24 | 'e'<<16 | 's'<<8 | 's'
+	keyEndpoint      = 'm'<<24 | 'e'<<16 | 'p'<<8 | '4'
+	keyGhostTime     = '_'<<24 | '_'<<16 | 'g'<<8 | 't'
+	keyPrevGhostTime = '_'<<24 | 'p'<<16 | 'g'<<8 | 't'
+	keyHostTime      = '_'<<24 | '_'<<16 | 'h'<<8 | 't'
+)
+
+// nodeID identifies a peer, and a session by the peer that founded it
+type nodeID [8]byte
+
+func (id nodeID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// less orders sessions for when two are equally old
+func (id nodeID) less(other nodeID) bool {
+	return bytes.Compare(id[:], other[:]) < 0
+}
+
+// timeline maps the session's ghost time onto beats. Beat 0 is the origin
+// of every peer's bar grid.
+type timeline struct {
+	tempo      int64 // Microseconds per beat
+	beatOrigin int64 // Microbeats at timeOrigin
+	timeOrigin int64 // Ghost time in microseconds
+}
+
+// newTimeline returns a timeline at bpm that reaches beat at ghost time
+func newTimeline(bpm float64, beat, ghost int64) timeline {
+	return timeline{tempo: int64(math.Round(60e6 / bpm)), beatOrigin: beat, timeOrigin: ghost}
+}
+
+// bpm returns the tempo in beats per minute
+func (t timeline) bpm() float64 {
+	return 60e6 / float64(t.tempo)
+}
+
+// toBeats returns the microbeat reached at a ghost time
+func (t timeline) toBeats(ghost int64) int64 {
+	return t.beatOrigin + int64(math.Round(float64(ghost-t.timeOrigin)*1e6/float64(t.tempo)))
+}
+
+// fromBeats returns the ghost time a microbeat is reached at
+func (t timeline) fromBeats(beats int64) int64 {
+	return t.timeOrigin + int64(math.Round(float64(beats-t.beatOrigin)*float64(t.tempo)/1e6))
+}
+
+// peerState is what a peer announces about itself
+type peerState struct {
+	session  nodeID
+	timeline timeline
+	endpoint *net.UDPAddr // Where it answers measurement pings, if known
+}
+
+// payload holds the entries of a message by key
+type payload map[uint32][]byte
+
+// parsePayload splits a message body into its entries, reporting false if
+// it is malformed
+func parsePayload(data []byte) (payload, bool) {
+	p := payload{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, false
+		}
+		key := binary.BigEndian.Uint32(data)
+		size := binary.BigEndian.Uint32(data[4:])
+		data = data[8:]
+		if uint32(len(data)) < size {
+			return nil, false
+		}
+		p[key] = data[:size]
+		data = data[size:]
+	}
+	return p, true
+}
+
+// int64s reads the entry under key as a run of n integers
+func (p payload) int64s(key uint32, n int) ([]int64, bool) {
+	value, ok := p[key]
+	if !ok || len(value) != 8*n {
+		return nil, false
+	}
+	out := make([]int64, n)
+	for i := range out {
+		out[i] = int64(binary.BigEndian.Uint64(value[8*i:]))
+	}
+	return out, true
+}
+
+// time reads a ghost or host time entry, or 0 if there is none
+func (p payload) time(key uint32) int64 {
+	v, ok := p.int64s(key, 1)
+	if !ok {
+		return 0
+	}
+	return v[0]
+}
+
+// session reads the session membership entry
+func (p payload) session() (nodeID, bool) {
+	var id nodeID
+	value, ok := p[keySession]
+	if !ok || len(value) != len(id) {
+		return id, false
+	}
+	copy(id[:], value)
+	return id, true
+}
+
+// state reads a peer's announced state, reporting false if the timeline or
+// session is missing
+func (p payload) state() (peerState, bool) {
+	var s peerState
+	tl, ok := p.int64s(keyTimeline, 3)
+	if !ok || tl[0] <= 0 {
+		return s, false
+	}
+	s.timeline = timeline{tempo: tl[0], beatOrigin: tl[1], timeOrigin: tl[2]}
+	if s.session, ok = p.session(); !ok {
+		return s, false
+	}
+	if ep := p[keyEndpoint]; len(ep) == 6 {
+		s.endpoint = &net.UDPAddr{IP: net.IP(append([]byte(nil), ep[:4]...)), Port: int(binary.BigEndian.Uint16(ep[4:]))}
+	}
+	return s, true
+}
+
+// appendEntry adds an entry to a message body
+func appendEntry(buf []byte, key uint32, value []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, key)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(value)))
+	return append(buf, value...)
+}
+
+// appendInt64s adds an entry holding a run of integers
+func appendInt64s(buf []byte, key uint32, values ...int64) []byte {
+	value := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		value = binary.BigEndian.AppendUint64(value, uint64(v))
+	}
+	return appendEntry(buf, key, value)
+}
+
+// appendState adds a peer's state to a message body
+func appendState(buf []byte, s peerState) []byte {
+	buf = appendInt64s(buf, keyTimeline, s.timeline.tempo, s.timeline.beatOrigin, s.timeline.timeOrigin)
+	buf = appendEntry(buf, keySession, s.session[:])
+	if s.endpoint == nil {
+		return buf
+	}
+	if ip := s.endpoint.IP.To4(); ip != nil {
+		ep := binary.BigEndian.AppendUint16(append([]byte(nil), ip...), uint16(s.endpoint.Port))
+		buf = appendEntry(buf, keyEndpoint, ep)
+	}
+	return buf
+}
+
+// discoveryMessage builds a discovery message from a peer
+func discoveryMessage(kind byte, id nodeID, body []byte) []byte {
+	buf := append([]byte(nil), discoveryHeader...)
+	buf = append(buf, kind, ttl, 0, 0) // Session group 0
+	buf = append(buf, id[:]...)
+	return append(buf, body...)
+}
+
+// parseDiscovery reads a discovery message, reporting false for anything
+// that isn't one
+func parseDiscovery(data []byte) (kind byte, id nodeID, body payload, ok bool) {
+	const size = 8 + 4 + 8
+	if len(data) < size || !bytes.Equal(data[:8], discoveryHeader) || data[10] != 0 || data[11] != 0 {
+		return 0, id, nil, false
+	}
+	copy(id[:], data[12:size])
+	body, ok = parsePayload(data[size:])
+	return data[8], id, body, ok
+}
+
+// measureMessage builds a ping or pong
+func measureMessage(kind byte, body []byte) []byte {
+	buf := append([]byte(nil), measureHeader...)
+	buf = append(buf, kind)
+	return append(buf, body...)
+}
+
+// parseMeasure reads a ping or pong, returning its raw body along with the
+// parsed one since pongs echo the ping's body back
+func parseMeasure(data []byte) (kind byte, raw []byte, body payload, ok bool) {
+	if len(data) < 9 || !bytes.Equal(data[:8], measureHeader) {
+		return 0, nil, nil, false
+	}
+	body, ok = parsePayload(data[9:])
+	return data[8], data[9:], body, ok
+}
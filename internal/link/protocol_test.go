@@ -0,0 +1,191 @@
+package link
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	body := appendEntry(nil, keySession, []byte("12345678"))
+	body = appendInt64s(body, keyGhostTime, -42)
+	body = appendInt64s(body, keyTimeline, 500000, 3_000_000, 1<<40)
+	body = appendEntry(body, 'x'<<24|'t'<<16|'r'<<8|'a', nil) // Unknown and empty
+
+	p, ok := parsePayload(body)
+	if !ok {
+		t.Fatal("payload didn't parse")
+	}
+	if len(p) != 4 {
+		t.Errorf("parsed %d entries, want 4", len(p))
+	}
+	if got := p.time(keyGhostTime); got != -42 {
+		t.Errorf("ghost time %d, want -42", got)
+	}
+	if got, ok := p.int64s(keyTimeline, 3); !ok || !reflect.DeepEqual(got, []int64{500000, 3_000_000, 1 << 40}) {
+		t.Errorf("timeline %v, %v", got, ok)
+	}
+	if _, ok := p.int64s(keyTimeline, 2); ok {
+		t.Error("a three integer entry read as two")
+	}
+	if got := p.time(keyHostTime); got != 0 {
+		t.Errorf("missing host time read as %d, want 0", got)
+	}
+	if id, ok := p.session(); !ok || !bytes.Equal(id[:], []byte("12345678")) {
+		t.Errorf("session %s, %v", id, ok)
+	}
+
+	empty, ok := parsePayload(nil)
+	if !ok || len(empty) != 0 {
+		t.Errorf("empty body gave %v, %v", empty, ok)
+	}
+}
+
+func TestPayloadMalformed(t *testing.T) {
+	whole := appendInt64s(nil, keyGhostTime, 7)
+	tests := map[string][]byte{
+		"short header":     whole[:5],
+		"short value":      whole[:len(whole)-1],
+		"size past end":    {0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff},
+		"trailing garbage": append(append([]byte(nil), whole...), 1, 2, 3),
+	}
+	for name, data := range tests {
+		if p, ok := parsePayload(data); ok {
+			t.Errorf("%s: parsed %v, want it rejected", name, p)
+		}
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	tests := []peerState{
+		{
+			session:  nodeID{1, 2, 3, 4, 5, 6, 7, 8},
+			timeline: timeline{tempo: 500000, beatOrigin: 16_000_000, timeOrigin: 123456789},
+			endpoint: &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20).To4(), Port: 20809},
+		},
+		{
+			session:  nodeID{8, 7, 6, 5, 4, 3, 2, 1},
+			timeline: timeline{tempo: 666667, beatOrigin: -2_500_000, timeOrigin: -5},
+		},
+	}
+	for _, want := range tests {
+		p, ok := parsePayload(appendState(nil, want))
+		if !ok {
+			t.Fatal("state didn't parse")
+		}
+		got, ok := p.state()
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	// Without a timeline or session, or with a nonsense tempo, it's no state
+	session := appendEntry(nil, keySession, make([]byte, 8))
+	for name, body := range map[string][]byte{
+		"no timeline": session,
+		"no session":  appendInt64s(nil, keyTimeline, 500000, 0, 0),
+		"zero tempo":  appendInt64s(session, keyTimeline, 0, 0, 0),
+		"short id":    appendInt64s(appendEntry(nil, keySession, []byte{1}), keyTimeline, 500000, 0, 0),
+	} {
+		p, _ := parsePayload(body)
+		if s, ok := p.state(); ok {
+			t.Errorf("%s: read state %+v", name, s)
+		}
+	}
+}
+
+func TestDiscoveryMessage(t *testing.T) {
+	id := nodeID{'g', 'n', 'o', 'm', 'e', 's', '!', '!'}
+	body := appendInt64s(nil, keyGhostTime, 99)
+	msg := discoveryMessage(msgAlive, id, body)
+
+	kind, gotID, p, ok := parseDiscovery(msg)
+	if !ok || kind != msgAlive || gotID != id || p.time(keyGhostTime) != 99 {
+		t.Errorf("got kind %d from %s with %v, %v", kind, gotID, p, ok)
+	}
+	if msg[9] != ttl {
+		t.Errorf("ttl %d, want %d", msg[9], ttl)
+	}
+
+	for name, data := range map[string][]byte{
+		"short":        msg[:10],
+		"wrong header": append([]byte("_asdp_v\x02"), msg[8:]...),
+		"other group":  append(append(append([]byte(nil), msg[:10]...), 0, 1), msg[12:]...),
+		"bad body":     append(append([]byte(nil), msg...), 1),
+		"measure ping": measureMessage(msgPing, body),
+	} {
+		if _, _, _, ok := parseDiscovery(data); ok {
+			t.Errorf("%s: parsed as discovery", name)
+		}
+	}
+}
+
+func TestMeasureMessage(t *testing.T) {
+	body := appendInt64s(nil, keyHostTime, 1234)
+	kind, raw, p, ok := parseMeasure(measureMessage(msgPong, body))
+	if !ok || kind != msgPong || !bytes.Equal(raw, body) || p.time(keyHostTime) != 1234 {
+		t.Errorf("got kind %d, raw %x, %v, %v", kind, raw, p, ok)
+	}
+	if _, _, _, ok := parseMeasure([]byte("_link_v\x01")); ok {
+		t.Error("parsed a ping with no kind")
+	}
+	if _, _, _, ok := parseMeasure(discoveryMessage(msgAlive, nodeID{}, nil)); ok {
+		t.Error("parsed a discovery message as a ping")
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	// 120 BPM, reaching beat 4 at a ghost time of 1ms
+	tl := newTimeline(120, 4_000_000, 1000)
+	if tl.tempo != 500000 || tl.bpm() != 120 {
+		t.Fatalf("tempo %dµs per beat, %g BPM", tl.tempo, tl.bpm())
+	}
+	tests := []struct {
+		ghost int64
+		beats int64
+	}{
+		{1000, 4_000_000},
+		{501_000, 5_000_000},
+		{251_000, 4_500_000},
+		{-1_999_000, 0},
+		{-2_999_000, -2_000_000}, // Before the session's beat 0
+	}
+	for _, tt := range tests {
+		if got := tl.toBeats(tt.ghost); got != tt.beats {
+			t.Errorf("toBeats(%d) = %d, want %d", tt.ghost, got, tt.beats)
+		}
+		if got := tl.fromBeats(tt.beats); got != tt.ghost {
+			t.Errorf("fromBeats(%d) = %d, want %d", tt.beats, got, tt.ghost)
+		}
+	}
+
+	// A tempo that doesn't divide evenly still comes back within a microsecond
+	odd := newTimeline(91, -3_000_000, 77)
+	for _, ghost := range []int64{-10_000_000, -1, 0, 77, 1_234_567, 60_000_000} {
+		if back := odd.fromBeats(odd.toBeats(ghost)); back-ghost > 1 || ghost-back > 1 {
+			t.Errorf("ghost time %d came back as %d", ghost, back)
+		}
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	tests := []struct{ a, b, want int64 }{
+		{7, 2, 3},
+		{8, 2, 4},
+		{0, 3, 0},
+		{-1, 3, -1},
+		{-7, 2, -4},
+		{-8, 2, -4},
+		{7, -2, -4},
+		{-7, -2, 3},
+		{-1, 1e6, -1},
+		{-1_000_000, 1e6, -1},
+		{-1_000_001, 1e6, -2},
+	}
+	for _, tt := range tests {
+		if got := floorDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("floorDiv(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timing buckets in seconds, from a tenth of a millisecond to a tenth of a
+// second, which spans everything from a healthy click to an audible stumble
+var timingBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1}
+
+// What the engine records while it plays
+var (
+	SchedulerJitter = NewHistogram("metrognome_scheduler_jitter_seconds",
+		"How late beats were handed to subscribers compared to when they were scheduled", timingBuckets)
+	DroppedBeats = NewCounter("metrognome_dropped_beats_total",
+		"Beats skipped because a subscriber was still busy with earlier ones")
+	AudioLatency = NewHistogram("metrognome_audio_queue_latency_seconds",
+		"How late queued clicks reached the player compared to when they were due", timingBuckets)
+	FrameRender = NewHistogram("metrognome_ui_frame_render_seconds",
+		"Time taken to render a frame of the terminal UI", timingBuckets)
+)
+
+// all lists the metrics in the order they are written
+var all = []metric{SchedulerJitter, DroppedBeats, AudioLatency, FrameRender}
+
+// metric is anything that can be written out in the text format
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Counter is a count that only goes up
+type Counter struct {
+	name, help string
+	value      atomic.Uint64
+}
+
+// NewCounter creates a counter
+func NewCounter(name, help string) *Counter {
+	return &Counter{name: name, help: help}
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Value returns the count so far
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.Value())
+	return err
+}
+
+// Histogram counts observations into buckets by their upper bounds
+type Histogram struct {
+	name, help string
+	bounds     []float64
+
+	mu     sync.Mutex
+	counts []uint64 // Per bucket, with one more for anything past the last bound
+	sum    float64
+}
+
+// NewHistogram creates a histogram with buckets up to each of the bounds,
+// which must be in increasing order
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return &Histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// ObserveDuration records a duration in seconds
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// Snapshot is a histogram's state at one moment
+type Snapshot struct {
+	Bounds []float64 // Upper bound of each bucket but the last, which is unbounded
+	Counts []uint64  // Observations in each bucket, not cumulative
+	Count  uint64
+	Sum    float64
+}
+
+// Snapshot returns a copy of what has been recorded so far
+func (h *Histogram) Snapshot() Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := Snapshot{Bounds: h.bounds, Counts: append([]uint64(nil), h.counts...), Sum: h.sum}
+	for _, n := range h.counts {
+		s.Count += n
+	}
+	return s
+}
+
+// Quantile returns the upper bound of the bucket holding the q-th quantile,
+// or +Inf if it is past the last bound. It is 0 when nothing was recorded.
+func (s Snapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(s.Count)))
+	var seen uint64
+	for i, n := range s.Counts {
+		seen += n
+		if seen >= rank && i < len(s.Bounds) {
+			return s.Bounds[i]
+		}
+	}
+	return math.Inf(1)
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	s := h.Snapshot()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	var cumulative uint64
+	for i, n := range s.Counts {
+		cumulative += n
+		le := "+Inf"
+		if i < len(s.Bounds) {
+			le = strconv.FormatFloat(s.Bounds[i], 'g', -1, 64)
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, le, cumulative); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, strconv.FormatFloat(s.Sum, 'g', -1, 64), h.name, s.Count)
+	return err
+}
+
+// ContentType is the media type of the text format written by WriteText
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes every metric in the Prometheus text exposition format
+func WriteText(w io.Writer) error {
+	for _, m := range all {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
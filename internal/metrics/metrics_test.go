@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_seconds", "A test", []float64{0.001, 0.01, 0.1})
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		h.ObserveDuration(d)
+	}
+
+	s := h.Snapshot()
+	// A value on a bound falls in that bound's bucket
+	if want := []uint64{2, 1, 1, 1}; !reflect.DeepEqual(s.Counts, want) {
+		t.Errorf("counts %v, want %v", s.Counts, want)
+	}
+	if s.Count != 5 || math.Abs(s.Sum-1.053) > 1e-9 {
+		t.Errorf("count %d and sum %g, want 5 and 1.053", s.Count, s.Sum)
+	}
+
+	tests := []struct{ q, want float64 }{
+		{0.2, 0.001},
+		{0.5, 0.01},
+		{0.8, 0.1},
+		{1, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		if got := s.Quantile(tt.q); got != tt.want {
+			t.Errorf("quantile %g = %g, want %g", tt.q, got, tt.want)
+		}
+	}
+	if got := (Snapshot{}).Quantile(0.5); got != 0 {
+		t.Errorf("quantile of nothing = %g, want 0", got)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	h := NewHistogram("test_seconds", "A test", []float64{0.001, 0.01})
+	h.Observe(0.0005)
+	h.Observe(0.5)
+	c := NewCounter("test_total", "Things counted")
+	c.Inc()
+	c.Inc()
+
+	var buf bytes.Buffer
+	if err := h.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_seconds A test
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.001"} 1
+test_seconds_bucket{le="0.01"} 1
+test_seconds_bucket{le="+Inf"} 2
+test_seconds_sum 0.5005
+test_seconds_count 2
+# HELP test_total Things counted
+# TYPE test_total counter
+test_total 2
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"metrognome_scheduler_jitter_seconds", "metrognome_dropped_beats_total", "metrognome_audio_queue_latency_seconds", "metrognome_ui_frame_render_seconds"} {
+		if !strings.Contains(buf.String(), "# TYPE "+name+" ") {
+			t.Errorf("%s missing from the text format", name)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package metronome
 
 import (
+	"fmt"
+	"sync"
 	"time"
+
+	"github.com/drj613/metrognome/internal/metrics"
 )
 
 // TimeSignature represents a musical time signature
@@ -14,20 +18,112 @@ type TimeSignature struct {
 
 // Preset represents a metronome preset configuration
 type Preset struct {
-	Name          string
-	BPM           int
-	TimeSignature TimeSignature
-	Description   string
+	Name          string        `json:"name"`
+	BPM           int           `json:"bpm"`
+	TimeSignature TimeSignature `json:"time_signature"`
+	Description   string        `json:"description,omitempty"`
+	Subdivision   int           `json:"subdivision,omitempty"` // Clicks per beat, 0 or 1 for none
+	Accents       []int         `json:"accents,omitempty"`     // Accented beats, nil for just the downbeat
+	Swing         int           `json:"swing,omitempty"`       // Swing percentage, 0 for straight
+}
+
+// String returns the time signature as written, such as "7/8"
+func (ts TimeSignature) String() string {
+	return fmt.Sprintf("%d/%d", ts.Beats, ts.BeatValue)
 }
 
+// MarshalText stores a time signature as written, such as "7/8"
+func (ts TimeSignature) MarshalText() ([]byte, error) {
+	return []byte(ts.String()), nil
+}
+
+// UnmarshalText parses a time signature written like "7/8", restoring the
+// gnome name and saying of common ones
+func (ts *TimeSignature) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeSignature(string(text))
+	if err != nil {
+		return err
+	}
+	*ts = parsed
+	return nil
+}
+
+// Beat describes a single click produced by the metronome
+type Beat struct {
+	Bar          int       // Bar number, starting at 1
+	Beat         int       // Beat within the bar, starting at 1
+	Subdivision  int       // Position within the beat, 0 on the beat itself
+	Subdivisions int       // Clicks per beat the beat was played with
+	Beats        int       // Beats in the bar
+	BeatValue    int       // Note value of a beat, 4 for quarter notes
+	Accent       bool      // Whether the beat is accented
+	BPM          int       // Tempo the beat was played at
+	Time         time.Time // When the beat is due to sound
+	Section      int       // Index of the song section, 0 when no song is loaded
+	SectionBar   int       // Bar within the song section, starting at 1
+	run          uint64    // Playback run the beat was scheduled in
+}
+
+// IsDownbeat reports whether this is the first beat of a bar
+func (b Beat) IsDownbeat() bool {
+	return b.Beat == 1 && b.Subdivision == 0
+}
+
+// IsCountIn reports whether the beat is part of the count-in, whose bars are
+// numbered up to 0 before the first real bar
+func (b Beat) IsCountIn() bool {
+	return b.Bar < 1
+}
+
+// Supported tempo range
+const (
+	MinBPM = 20
+	MaxBPM = 300
+)
+
+// MaxSubdivision is the most clicks a beat can be split into
+const MaxSubdivision = 4
+
+// MaxBeats is the most beats a bar can have
+const MaxBeats = 16
+
+// MaxCountIn is the most bars that can be counted in
+const MaxCountIn = 4
+
+// Supported swing range, as the percentage of a pair of clicks taken by the
+// first one
+const (
+	MinSwing = 50
+	MaxSwing = 75
+)
+
+// DefaultLookAhead is how far ahead of time beats are announced to subscribers
+const DefaultLookAhead = 100 * time.Millisecond
+
+// subscriberBuffer is how many beats a slow subscriber can fall behind
+const subscriberBuffer = 16
+
 // Metronome represents the core metronome logic
 type Metronome struct {
-	BPM           int
-	TimeSignature TimeSignature
-	IsPlaying     bool
-	CurrentBeat   int
-	ticker        *time.Ticker
-	beatChan      chan int
+	mu            sync.Mutex
+	bpm           int
+	timeSignature TimeSignature
+	subdivision   int   // Clicks per beat, 1 for none
+	countIn       int   // Bars counted in when playback starts
+	accents       []int // Accented beats, nil for just the downbeat
+	swing         int   // Swing percentage, 0 for straight
+	song          *Song // Song whose sections set the meter and tempo, if any
+	barLimit      int   // Bars played before stopping, 0 for no limit
+	playing       bool
+	currentBeat   int
+	lookAhead     time.Duration
+	run           uint64        // Incremented every time playback starts
+	finished      uint64        // Last run that stopped at the bar limit
+	restarted     bool          // Whether the current run carried on from the last after a change
+	external      bool          // Whether beats come from an external clock instead of the scheduler
+	linked        bool          // Whether beats come from a shared timeline, started and stopped here
+	stop          chan struct{} // Closed to stop the scheduling goroutine
+	subscribers   map[chan Beat]struct{}
 }
 
 // CommonTimeSignatures provides preset time signatures with gnome themes
@@ -113,94 +209,684 @@ var CommonPresets = []Preset{
 // New creates a new Metronome instance
 func New(bpm int, timeSignature TimeSignature) *Metronome {
 	return &Metronome{
-		BPM:           bpm,
-		TimeSignature: timeSignature,
-		IsPlaying:     false,
-		CurrentBeat:   1,
-		beatChan:      make(chan int, 1),
+		bpm:           bpm,
+		timeSignature: timeSignature,
+		subdivision:   1,
+		currentBeat:   1,
+		lookAhead:     DefaultLookAhead,
+		subscribers:   make(map[chan Beat]struct{}),
 	}
 }
 
+// BPM returns the tempo
+func (m *Metronome) BPM() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bpm
+}
+
+// TimeSignature returns the time signature
+func (m *Metronome) TimeSignature() TimeSignature {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.timeSignature
+}
+
+// Subdivision returns how many clicks each beat is split into
+func (m *Metronome) Subdivision() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.subdivision
+}
+
+// IsPlaying reports whether the metronome is running
+func (m *Metronome) IsPlaying() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.playing
+}
+
+// CurrentBeat returns the most recently scheduled beat of the bar
+func (m *Metronome) CurrentBeat() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.currentBeat
+}
+
+// IsScheduled reports whether a beat belongs to the current run of the
+// metronome, so consumers can drop beats queued before a stop or restart
+func (m *Metronome) IsScheduled(b Beat) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.playing && b.run == m.run
+}
+
 // Start begins the metronome
 func (m *Metronome) Start() {
-	if m.IsPlaying {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.external {
+		// The external clock says when to play
 		return
 	}
+	if !m.playing {
+		m.restarted = false
+	}
+	m.start(m.countIn)
+}
 
-	m.IsPlaying = true
-	m.CurrentBeat = 1
+// start launches the scheduling goroutine, counting in the given number of
+// bars first. The caller must hold m.mu.
+func (m *Metronome) start(countIn int) {
+	if m.playing {
+		return
+	}
+
+	m.playing = true
+	m.currentBeat = 1
+	m.run++
+	m.stop = make(chan struct{})
+
+	if m.external || m.linked {
+		return
+	}
+	// The first beat lands one look-ahead window from now so subscribers
+	// get the same warning for it as for every other beat
+	go m.schedule(m.stop, newTimeline(m.segments(), countIn, time.Now().Add(m.lookAhead), m.run))
+}
 
-	// Calculate interval between beats
-	interval := time.Duration(60000/m.BPM) * time.Millisecond
-	m.ticker = time.NewTicker(interval)
+// schedule announces each beat to subscribers a look-ahead window before it
+// is due. Beat times are computed from the start of the run rather than
+// from when timers fire, so timer jitter never accumulates into drift.
+func (m *Metronome) schedule(stop <-chan struct{}, t *timeline) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
-	go func() {
-		for range m.ticker.C {
-			if m.IsPlaying {
-				select {
-				case m.beatChan <- m.CurrentBeat:
-				default:
-					// Channel is full, skip this beat
-				}
+	for {
+		m.mu.Lock()
+		lookAhead := m.lookAhead
+		limit := m.barLimit
+		m.mu.Unlock()
+
+		// At the end of the song, or the first downbeat past the bar limit,
+		// let the final bar ring out and stop instead of announcing a beat
+		var b Beat
+		last := t.done()
+		due := t.beatAt
+		if !last {
+			b = t.next()
+			last = limit > 0 && b.Bar > limit && b.IsDownbeat()
+			due = b.Time
+			if !last {
+				due = due.Add(-lookAhead)
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(due))
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
 
-				m.CurrentBeat++
-				if m.CurrentBeat > m.TimeSignature.Beats {
-					m.CurrentBeat = 1
-				}
+		m.mu.Lock()
+		if last {
+			if m.playing && m.run == t.run {
+				m.halt()
+				m.finished = t.run
 			}
+			m.mu.Unlock()
+			return
+		}
+		m.currentBeat = b.Beat
+		m.publish(b, due)
+		m.mu.Unlock()
+	}
+}
+
+// pattern is a snapshot of the settings that shape the clicks of one run
+type pattern struct {
+	bpm         int
+	beats       int
+	beatValue   int
+	subdivision int
+	accents     []int
+	swing       int
+	interval    time.Duration // Time between straight clicks
+}
+
+// pattern captures the current settings. The caller must hold m.mu.
+func (m *Metronome) pattern() pattern {
+	return pattern{
+		bpm:         m.bpm,
+		beats:       m.timeSignature.Beats,
+		beatValue:   m.timeSignature.BeatValue,
+		subdivision: m.subdivision,
+		accents:     m.accents,
+		swing:       m.swing,
+		interval:    time.Minute / time.Duration(m.bpm*m.subdivision),
+	}
+}
+
+// segments lays out a run: the sections of the song if one is loaded, or
+// else a single endless stretch with the current settings. The caller must
+// hold m.mu.
+func (m *Metronome) segments() []segment {
+	if m.song == nil {
+		return []segment{{pattern: m.pattern()}}
+	}
+
+	segments := make([]segment, len(m.song.Sections))
+	for i, sec := range m.song.Sections {
+		pat := m.pattern()
+		pat.bpm = sec.BPM
+		pat.beats = sec.TimeSignature.Beats
+		pat.beatValue = sec.TimeSignature.BeatValue
+		if sec.Subdivision != 0 {
+			pat.subdivision = sec.Subdivision
+		}
+		if sec.Accents != nil {
+			pat.accents = sec.Accents
+		}
+		if sec.Swing != 0 {
+			pat.swing = sec.Swing
+		}
+		pat.interval = time.Minute / time.Duration(sec.BPM*pat.subdivision)
+		segments[i] = segment{pattern: pat, bars: sec.Bars, endBPM: sec.EndBPM}
+	}
+	return segments
+}
+
+// accented reports whether a beat of the bar is accented
+func (p pattern) accented(beat int) bool {
+	if p.accents == nil {
+		return beat == 1
+	}
+	for _, a := range p.accents {
+		if a == beat {
+			return true
+		}
+	}
+	return false
+}
+
+// position is a place in the bar/beat/subdivision grid
+type position struct {
+	bar, beat, sub int
+}
+
+// firstPosition is the downbeat of the first bar
+var firstPosition = position{bar: 1, beat: 1}
+
+// next returns the position of the following click
+func (p position) next(beats, subdivisions int) position {
+	p.sub++
+	if p.sub < subdivisions {
+		return p
+	}
+	p.sub = 0
+	p.beat++
+	if p.beat > beats {
+		p.beat = 1
+		p.bar++
+	}
+	return p
+}
+
+// Plan returns every click of the given number of bars with the current
+// settings, as if playback had started at start, without a count-in, and
+// when the last bar ends. A song ends the plan early if it is shorter. It is
+// used to render click tracks offline.
+func (m *Metronome) Plan(start time.Time, bars int) ([]Beat, time.Time) {
+	m.mu.Lock()
+	t := newTimeline(m.segments(), 0, start, 0)
+	m.mu.Unlock()
+
+	var beats []Beat
+	for !t.done() {
+		b := t.next()
+		if b.Bar > bars {
+			return beats, b.Time
 		}
-	}()
+		beats = append(beats, b)
+	}
+	return beats, t.beatAt
+}
+
+// BarDuration returns how long one bar lasts with the current settings
+func (m *Metronome) BarDuration() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pat := m.pattern()
+	return pat.interval * time.Duration(pat.beats*pat.subdivision)
+}
+
+// publish sends a beat to every subscriber without blocking, recording how
+// late it is for when it was due to be handed out. The caller must hold m.mu.
+func (m *Metronome) publish(b Beat, due time.Time) {
+	metrics.SchedulerJitter.ObserveDuration(max(0, time.Since(due)))
+	for ch := range m.subscribers {
+		select {
+		case ch <- b:
+		default:
+			// Subscriber is full, skip this beat
+			metrics.DroppedBeats.Inc()
+		}
+	}
+}
+
+// Stopped returns a channel that is closed when the current run of playback
+// ends, whether it was stopped, restarted or reached its end. It is already
+// closed when the metronome isn't playing.
+func (m *Metronome) Stopped() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.playing {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return m.stop
 }
 
 // Stop halts the metronome
 func (m *Metronome) Stop() {
-	if !m.IsPlaying {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.halt()
+}
+
+// halt stops the scheduling goroutine. The caller must hold m.mu.
+func (m *Metronome) halt() {
+	if !m.playing {
 		return
 	}
 
-	m.IsPlaying = false
-	if m.ticker != nil {
-		m.ticker.Stop()
+	m.playing = false
+	close(m.stop)
+	m.currentBeat = 1
+}
+
+// restart applies a change to the settings, restarting playback around it
+// so the new settings take effect from a fresh downbeat
+func (m *Metronome) restart(change func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wasPlaying := m.playing
+	if wasPlaying {
+		m.halt()
+	}
+
+	change()
+
+	// Carry straight on without counting in again
+	if wasPlaying {
+		m.restarted = true
+		m.start(0)
 	}
-	m.CurrentBeat = 1
 }
 
 // SetBPM changes the tempo
-func (m *Metronome) SetBPM(bpm int) {
-	if bpm < 20 || bpm > 300 {
+func (m *Metronome) SetBPM(bpm int) error {
+	if err := ValidateBPM(bpm); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.bpm = bpm
+		m.song = nil
+	})
+	return nil
+}
+
+// SetTimeSignature changes the time signature
+func (m *Metronome) SetTimeSignature(ts TimeSignature) error {
+	if err := ValidateTimeSignature(ts); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.timeSignature = ts
+		m.song = nil
+		m.currentBeat = 1
+		// Accents past the end of the new bar can't be played
+		if ValidateAccents(m.accents, ts) != nil {
+			m.accents = nil
+		}
+	})
+	return nil
+}
+
+// SetSubdivision changes how many clicks each beat is split into
+func (m *Metronome) SetSubdivision(n int) error {
+	if err := ValidateSubdivision(n); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.subdivision = n
+	})
+	return nil
+}
+
+// Accents returns the accented beats of the bar, nil for just the downbeat
+func (m *Metronome) Accents() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int(nil), m.accents...)
+}
+
+// SetAccents sets which beats of the bar are accented. nil accents just the
+// downbeat and an empty slice accents nothing.
+func (m *Metronome) SetAccents(accents []int) error {
+	if err := ValidateAccents(accents, m.TimeSignature()); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.accents = cloneAccents(accents)
+	})
+	return nil
+}
+
+// Swing returns the swing percentage, 0 for straight
+func (m *Metronome) Swing() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.swing
+}
+
+// SetSwing sets how late the second of each pair of eighths or sixteenths
+// falls, as a percentage of the pair: 50 is straight and 67 a triplet feel.
+// 0 also plays straight.
+func (m *Metronome) SetSwing(percent int) error {
+	if err := ValidateSwing(percent); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.swing = percent
+	})
+	return nil
+}
+
+// Preset returns the current settings as an unnamed preset
+func (m *Metronome) Preset() Preset {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Preset{
+		BPM:           m.bpm,
+		TimeSignature: m.timeSignature,
+		Subdivision:   m.subdivision,
+		Accents:       cloneAccents(m.accents),
+		Swing:         m.swing,
+	}
+}
+
+// Apply switches to a preset's settings all at once, so playback restarts
+// a single time with all of them in effect
+func (m *Metronome) Apply(p Preset) error {
+	if err := ValidatePreset(p); err != nil {
+		return err
+	}
+
+	m.restart(func() {
+		m.bpm = p.BPM
+		m.timeSignature = p.TimeSignature
+		m.subdivision = p.subdivision()
+		m.accents = cloneAccents(p.Accents)
+		m.swing = p.Swing
+		m.song = nil
+		m.currentBeat = 1
+	})
+	return nil
+}
+
+// subdivision returns the preset's clicks per beat, treating 0 as none
+func (p Preset) subdivision() int {
+	if p.Subdivision == 0 {
+		return 1
+	}
+	return p.Subdivision
+}
+
+// cloneAccents copies accents, keeping nil and empty apart
+func cloneAccents(accents []int) []int {
+	if accents == nil {
+		return nil
+	}
+	return append([]int{}, accents...)
+}
+
+// Song returns the song being followed, or nil when the tempo and meter are
+// set directly
+func (m *Metronome) Song() *Song {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.song == nil {
+		return nil
+	}
+	s := *m.song
+	s.Sections = append([]Section(nil), m.song.Sections...)
+	return &s
+}
+
+// SetSong makes playback follow a song's sections, switching meter and tempo
+// at their bar lines and stopping at the end. nil goes back to the tempo and
+// meter set directly, as does setting either of them or applying a preset.
+func (m *Metronome) SetSong(s *Song) error {
+	if s != nil {
+		if err := ValidateSong(*s); err != nil {
+			return err
+		}
+		song := *s
+		song.Sections = append([]Section(nil), s.Sections...)
+		s = &song
+	}
+
+	m.restart(func() {
+		m.song = s
+		m.currentBeat = 1
+	})
+	return nil
+}
+
+// BarLimit returns how many bars are played before stopping, 0 for no limit
+func (m *Metronome) BarLimit() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.barLimit
+}
+
+// SetBarLimit makes playback stop by itself once the given number of bars,
+// not counting the count-in, have been played. 0 plays forever. The limit
+// applies to the current run as well as later ones.
+func (m *Metronome) SetBarLimit(bars int) error {
+	if bars < 0 {
+		return fmt.Errorf("bar limit can't be negative, got %d", bars)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.barLimit = bars
+	return nil
+}
+
+// Finished reports whether playback stopped by itself at the bar limit, and
+// hasn't been started again since
+func (m *Metronome) Finished() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.playing && m.finished == m.run && m.run > 0
+}
+
+// Restarted reports whether the current run carried straight on from the one
+// before it, restarted by a change of settings rather than by Start
+func (m *Metronome) Restarted() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.playing && m.restarted
+}
+
+// CountIn returns how many bars are counted in when playback starts
+func (m *Metronome) CountIn() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.countIn
+}
+
+// SetCountIn sets how many bars are counted in when playback starts. The
+// count-in bars are numbered up to 0, so the first real bar is still 1.
+func (m *Metronome) SetCountIn(bars int) error {
+	if err := ValidateCountIn(bars); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countIn = bars
+	return nil
+}
+
+// SetLookAhead changes how far in advance beats are announced. Longer
+// windows ride out bigger stalls at the cost of reacting later to changes.
+func (m *Metronome) SetLookAhead(d time.Duration) {
+	if d < 0 {
 		return
 	}
 
-	wasPlaying := m.IsPlaying
-	if wasPlaying {
-		m.Stop()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookAhead = d
+}
+
+// LookAhead returns how far ahead of time beats are announced
+func (m *Metronome) LookAhead() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookAhead
+}
+
+// Subscribe returns a channel that receives every beat a look-ahead window
+// before it is due, with Beat.Time set to when it should sound. Call the
+// returned function to unsubscribe.
+func (m *Metronome) Subscribe() (<-chan Beat, func()) {
+	ch := make(chan Beat, subscriberBuffer)
+
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subscribers, ch)
+			m.mu.Unlock()
+		})
 	}
+}
 
-	m.BPM = bpm
+// ParseTimeSignature parses a time signature such as "7/8". Common time
+// signatures come back with their gnome names and sayings.
+func ParseTimeSignature(s string) (TimeSignature, error) {
+	var beats, value int
+	if n, err := fmt.Sscanf(s, "%d/%d", &beats, &value); err != nil || n != 2 || fmt.Sprintf("%d/%d", beats, value) != s {
+		return TimeSignature{}, fmt.Errorf("invalid time signature %q, expected something like 4/4 or 7/8", s)
+	}
 
-	if wasPlaying {
-		m.Start()
+	for _, ts := range CommonTimeSignatures {
+		if ts.Beats == beats && ts.BeatValue == value {
+			return ts, nil
+		}
 	}
+
+	ts := TimeSignature{
+		Beats:       beats,
+		BeatValue:   value,
+		Name:        s,
+		GnomeSaying: "An unusual dance, even for a gnome!",
+	}
+	return ts, ValidateTimeSignature(ts)
 }
 
-// SetTimeSignature changes the time signature
-func (m *Metronome) SetTimeSignature(ts TimeSignature) {
-	wasPlaying := m.IsPlaying
-	if wasPlaying {
-		m.Stop()
+// ValidateBPM checks that a tempo is within the supported range
+func ValidateBPM(bpm int) error {
+	if bpm < MinBPM || bpm > MaxBPM {
+		return fmt.Errorf("BPM must be between %d and %d, got %d", MinBPM, MaxBPM, bpm)
+	}
+	return nil
+}
+
+// ValidateSubdivision checks that a beat can be split into n clicks
+func ValidateSubdivision(n int) error {
+	if n < 1 || n > MaxSubdivision {
+		return fmt.Errorf("subdivision must be between 1 and %d, got %d", MaxSubdivision, n)
 	}
+	return nil
+}
 
-	m.TimeSignature = ts
-	m.CurrentBeat = 1
+// ValidateAccents checks that every accent falls within a bar
+func ValidateAccents(accents []int, ts TimeSignature) error {
+	for _, a := range accents {
+		if a < 1 || a > ts.Beats {
+			return fmt.Errorf("accent on beat %d is outside a bar of %s", a, ts)
+		}
+	}
+	return nil
+}
 
-	if wasPlaying {
-		m.Start()
+// ValidateSwing checks a swing percentage
+func ValidateSwing(percent int) error {
+	if percent != 0 && (percent < MinSwing || percent > MaxSwing) {
+		return fmt.Errorf("swing must be 0 or between %d and %d%%, got %d", MinSwing, MaxSwing, percent)
 	}
+	return nil
 }
 
-// BeatChannel returns the channel that emits beat numbers
-func (m *Metronome) BeatChannel() <-chan int {
-	return m.beatChan
+// ValidatePreset checks that every setting of a preset can be played
+func ValidatePreset(p Preset) error {
+	if err := ValidateBPM(p.BPM); err != nil {
+		return err
+	}
+	if err := ValidateTimeSignature(p.TimeSignature); err != nil {
+		return err
+	}
+	if err := ValidateSubdivision(p.subdivision()); err != nil {
+		return err
+	}
+	if err := ValidateAccents(p.Accents, p.TimeSignature); err != nil {
+		return err
+	}
+	return ValidateSwing(p.Swing)
+}
+
+// ValidateCountIn checks that a number of bars can be counted in
+func ValidateCountIn(bars int) error {
+	if bars < 0 || bars > MaxCountIn {
+		return fmt.Errorf("count-in must be between 0 and %d bars, got %d", MaxCountIn, bars)
+	}
+	return nil
+}
+
+// ValidateTimeSignature checks that a time signature can be played
+func ValidateTimeSignature(ts TimeSignature) error {
+	if ts.Beats < 1 || ts.Beats > MaxBeats {
+		return fmt.Errorf("time signature must have between 1 and %d beats, got %d", MaxBeats, ts.Beats)
+	}
+	switch ts.BeatValue {
+	case 1, 2, 4, 8, 16, 32:
+		return nil
+	default:
+		return fmt.Errorf("beat value must be 1, 2, 4, 8, 16 or 32, got %d", ts.BeatValue)
+	}
 }
 
 // GetBPMDescription returns a gnome-themed description of the current tempo
@@ -0,0 +1,92 @@
+package metronome
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metrics"
+)
+
+// lateJitter returns how many scheduler jitter observations since before
+// were more than min late, to the nearest bucket
+func lateJitter(before metrics.Snapshot, min time.Duration) uint64 {
+	after := metrics.SchedulerJitter.Snapshot()
+	var n uint64
+	for i := 1; i < len(after.Counts); i++ {
+		if after.Bounds[i-1] >= min.Seconds() {
+			n += after.Counts[i] - before.Counts[i]
+		}
+	}
+	return n
+}
+
+func TestLateTimerRecordsJitter(t *testing.T) {
+	// Sixteenths at 300 BPM, so a click comes due every 50ms
+	m := New(300, CommonTimeSignatures[0])
+	m.SetSubdivision(4)
+	defer m.Stop()
+	m.Start()
+	time.Sleep(10 * time.Millisecond)
+
+	// Holding the lock keeps the scheduler from handing out the clicks that
+	// come due meanwhile, as a stalled timer would. The stall is shorter
+	// than the look-ahead, so they still go out before they sound.
+	before := metrics.SchedulerJitter.Snapshot()
+	m.mu.Lock()
+	time.Sleep(80 * time.Millisecond)
+	m.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+
+	if n := lateJitter(before, 25*time.Millisecond); n == 0 {
+		t.Errorf("no click recorded as over 25ms late after an 80ms stall: %+v", metrics.SchedulerJitter.Snapshot())
+	}
+}
+
+func TestBarLimit(t *testing.T) {
+	m := New(300, TimeSignature{Beats: 2, BeatValue: 4})
+	if err := m.SetBarLimit(-1); err == nil {
+		t.Error("a negative bar limit was accepted")
+	}
+	m.SetBarLimit(2)
+	m.SetCountIn(1)
+	beats, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	defer m.Stop()
+
+	start := time.Now()
+	m.Start()
+	stopped := m.Stopped()
+	var got []string
+	var last Beat
+play:
+	for {
+		select {
+		case b := <-beats:
+			got = append(got, fmt.Sprintf("%d.%d", b.Bar, b.Beat))
+			last = b
+		case <-stopped:
+			break play
+		case <-time.After(2 * time.Second):
+			t.Fatal("playback didn't stop at the bar limit")
+		}
+	}
+
+	// The count-in doesn't count towards the limit
+	if want := []string{"0.1", "0.2", "1.1", "1.2", "2.1", "2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("played %q, want %q", got, want)
+	}
+	// The last beat rings out before playback stops
+	if ends := last.Time.Add(200 * time.Millisecond); time.Now().Before(ends) {
+		t.Errorf("stopped %s in, before the last beat ended at %s", time.Since(start), ends.Sub(start))
+	}
+	if !m.Finished() {
+		t.Error("not finished after reaching the bar limit")
+	}
+
+	m.Start()
+	if m.Finished() {
+		t.Error("still finished after starting again")
+	}
+}
@@ -0,0 +1,191 @@
+package metronome
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Section is a stretch of a song played in one meter, at a steady tempo or
+// ramping from one tempo to another
+type Section struct {
+	Name          string        `json:"name"`
+	Bars          int           `json:"bars"`
+	BPM           int           `json:"bpm"`
+	TimeSignature TimeSignature `json:"time_signature"`
+	EndBPM        int           `json:"end_bpm,omitempty"`     // Tempo reached on the last beat, 0 for a steady tempo
+	Subdivision   int           `json:"subdivision,omitempty"` // Clicks per beat, 0 to keep the metronome's
+	Accents       []int         `json:"accents,omitempty"`     // Accented beats, nil to keep the metronome's
+	Swing         int           `json:"swing,omitempty"`       // Swing percentage, 0 to keep the metronome's
+}
+
+// Song is a sequence of sections played one after another
+type Song struct {
+	Name     string    `json:"name"`
+	Sections []Section `json:"sections"`
+}
+
+// MaxSectionBars is the longest a song section can be
+const MaxSectionBars = 999
+
+// Bars returns the length of the song
+func (s Song) Bars() int {
+	bars := 0
+	for _, sec := range s.Sections {
+		bars += sec.Bars
+	}
+	return bars
+}
+
+// Ramp describes a section's tempo change, such as "ritardando to 70 BPM",
+// or returns "" for a steady tempo
+func (s Section) Ramp() string {
+	switch {
+	case s.EndBPM == 0 || s.EndBPM == s.BPM:
+		return ""
+	case s.EndBPM < s.BPM:
+		return fmt.Sprintf("ritardando to %d BPM", s.EndBPM)
+	default:
+		return fmt.Sprintf("accelerando to %d BPM", s.EndBPM)
+	}
+}
+
+// ValidateSection checks that a section can be played
+func ValidateSection(s Section) error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("a section needs a name")
+	}
+	if s.Bars < 1 || s.Bars > MaxSectionBars {
+		return fmt.Errorf("a section must be between 1 and %d bars, got %d", MaxSectionBars, s.Bars)
+	}
+	if err := ValidateBPM(s.BPM); err != nil {
+		return err
+	}
+	if s.EndBPM != 0 {
+		if err := ValidateBPM(s.EndBPM); err != nil {
+			return fmt.Errorf("end tempo: %w", err)
+		}
+	}
+	if err := ValidateTimeSignature(s.TimeSignature); err != nil {
+		return err
+	}
+	if s.Subdivision != 0 {
+		if err := ValidateSubdivision(s.Subdivision); err != nil {
+			return err
+		}
+	}
+	if err := ValidateAccents(s.Accents, s.TimeSignature); err != nil {
+		return err
+	}
+	return ValidateSwing(s.Swing)
+}
+
+// ValidateSong checks that every section of a song can be played
+func ValidateSong(s Song) error {
+	if len(s.Sections) == 0 {
+		return errors.New("a song needs at least one section")
+	}
+	for i, sec := range s.Sections {
+		if err := ValidateSection(sec); err != nil {
+			return fmt.Errorf("section %d (%q): %w", i+1, sec.Name, err)
+		}
+	}
+	return nil
+}
+
+// segment is one stretch of a run with a fixed meter: a song section, or
+// the whole run when no song is loaded
+type segment struct {
+	pattern
+	bars   int // Length in bars, 0 for no end
+	endBPM int // Tempo reached on the last beat, 0 for a steady tempo
+}
+
+// tempoAt returns the tempo of the kth beat of the segment, ramping evenly
+// from the first beat to the last
+func (s segment) tempoAt(k int) float64 {
+	total := s.bars * s.beats
+	if s.endBPM == 0 || total < 2 {
+		return float64(s.bpm)
+	}
+	return float64(s.bpm) + float64(s.endBPM-s.bpm)*float64(k)/float64(total-1)
+}
+
+// timeline walks through the clicks of a run in order. Each beat starts where
+// the last one ended, so tempo changes never leave a gap or overlap.
+type timeline struct {
+	segments []segment
+	run      uint64
+	seg      int       // Index of the current segment
+	pos      position  // Position within the current segment
+	bar      int       // Bar number across the whole run
+	beats    int       // Beats played in the current segment, not counting the count-in
+	beatAt   time.Time // When the current beat starts
+}
+
+// newTimeline starts a timeline at start, counting in the given number of
+// bars in the meter and tempo of the first segment
+func newTimeline(segments []segment, countIn int, start time.Time, run uint64) *timeline {
+	return &timeline{
+		segments: segments,
+		run:      run,
+		pos:      position{bar: 1 - countIn, beat: 1},
+		bar:      1 - countIn,
+		beatAt:   start,
+	}
+}
+
+// done reports whether every segment has been played. beatAt is then the
+// moment the last bar ends.
+func (t *timeline) done() bool {
+	return t.seg >= len(t.segments)
+}
+
+// next returns the next click and moves past it
+func (t *timeline) next() Beat {
+	seg := t.segments[t.seg]
+	bpm := seg.tempoAt(t.beats)
+	beatLen := time.Duration(float64(time.Minute) / bpm)
+	interval := beatLen / time.Duration(seg.subdivision)
+
+	at := t.beatAt.Add(time.Duration(t.pos.sub) * interval)
+	// Swing delays the second click of each pair of eighths or sixteenths
+	if seg.swing > 0 && seg.subdivision%2 == 0 && t.pos.sub%2 == 1 {
+		at = at.Add(2 * interval * time.Duration(seg.swing-50) / 100)
+	}
+
+	b := Beat{
+		Bar:          t.bar,
+		Beat:         t.pos.beat,
+		Subdivision:  t.pos.sub,
+		Subdivisions: seg.subdivision,
+		Beats:        seg.beats,
+		BeatValue:    seg.beatValue,
+		Accent:       t.pos.sub == 0 && seg.accented(t.pos.beat),
+		BPM:          int(math.Round(bpm)),
+		Time:         at,
+		Section:      t.seg,
+		SectionBar:   t.pos.bar,
+		run:          t.run,
+	}
+
+	next := t.pos.next(seg.beats, seg.subdivision)
+	if next.sub == 0 {
+		t.beatAt = t.beatAt.Add(beatLen)
+		if t.pos.bar >= 1 {
+			t.beats++
+		}
+	}
+	if next.bar != t.pos.bar {
+		t.bar++
+	}
+	if seg.bars > 0 && next.bar > seg.bars {
+		t.seg++
+		t.beats = 0
+		next = firstPosition
+	}
+	t.pos = next
+	return b
+}
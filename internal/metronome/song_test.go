@@ -0,0 +1,215 @@
+package metronome
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clicks lays out the clicks of a run the way the scheduler does, as
+// "section:bar.beat.subdivision offset tempo", with "!" marking accents,
+// and returns when the run ends
+func clicks(m *Metronome, countIn, bars int) ([]string, time.Duration) {
+	start := time.Now()
+	m.mu.Lock()
+	t := newTimeline(m.segments(), countIn, start, 0)
+	m.mu.Unlock()
+
+	var out []string
+	for !t.done() {
+		b := t.next()
+		if b.Bar > bars {
+			return out, b.Time.Sub(start)
+		}
+		click := fmt.Sprintf("%d:%d.%d.%d %s %dbpm", b.Section, b.Bar, b.Beat, b.Subdivision, b.Time.Sub(start).Truncate(time.Millisecond), b.BPM)
+		if b.Accent {
+			click += "!"
+		}
+		out = append(out, click)
+	}
+	return out, t.beatAt.Sub(start)
+}
+
+func TestTimeline(t *testing.T) {
+	twoFour := TimeSignature{Beats: 2, BeatValue: 4}
+	tests := []struct {
+		name    string
+		preset  Preset
+		song    *Song
+		countIn int
+		bars    int
+		want    []string
+		end     time.Duration
+	}{
+		{
+			name:   "straight eighths",
+			preset: Preset{BPM: 120, TimeSignature: twoFour, Subdivision: 2},
+			bars:   1,
+			want:   []string{"0:1.1.0 0s 120bpm!", "0:1.1.1 250ms 120bpm", "0:1.2.0 500ms 120bpm", "0:1.2.1 750ms 120bpm"},
+			end:    time.Second,
+		},
+		{
+			name:   "swung eighths",
+			preset: Preset{BPM: 120, TimeSignature: twoFour, Subdivision: 2, Swing: 75},
+			bars:   1,
+			want:   []string{"0:1.1.0 0s 120bpm!", "0:1.1.1 375ms 120bpm", "0:1.2.0 500ms 120bpm", "0:1.2.1 875ms 120bpm"},
+			end:    time.Second,
+		},
+		{
+			name:   "swung sixteenths",
+			preset: Preset{BPM: 120, TimeSignature: TimeSignature{Beats: 1, BeatValue: 4}, Subdivision: 4, Swing: 60},
+			bars:   1,
+			want:   []string{"0:1.1.0 0s 120bpm!", "0:1.1.1 150ms 120bpm", "0:1.1.2 250ms 120bpm", "0:1.1.3 400ms 120bpm"},
+			end:    500 * time.Millisecond,
+		},
+		{
+			name:   "swing leaves triplets straight",
+			preset: Preset{BPM: 60, TimeSignature: TimeSignature{Beats: 1, BeatValue: 4}, Subdivision: 3, Swing: 67},
+			bars:   1,
+			want:   []string{"0:1.1.0 0s 60bpm!", "0:1.1.1 333ms 60bpm", "0:1.1.2 666ms 60bpm"},
+			end:    time.Second,
+		},
+		{
+			name:    "accents and counting in",
+			preset:  Preset{BPM: 120, TimeSignature: twoFour, Accents: []int{2}},
+			countIn: 1,
+			bars:    1,
+			want:    []string{"0:0.1.0 0s 120bpm", "0:0.2.0 500ms 120bpm!", "0:1.1.0 1s 120bpm", "0:1.2.0 1.5s 120bpm!"},
+			end:     2 * time.Second,
+		},
+		{
+			name:   "ritardando",
+			preset: Preset{BPM: 90, TimeSignature: twoFour},
+			song: &Song{Name: "slowing", Sections: []Section{
+				{Name: "outro", Bars: 2, BPM: 120, EndBPM: 60, TimeSignature: twoFour},
+			}},
+			bars: 2,
+			// Each beat starts where the last one ended, at the tempo reached
+			// by then
+			want: []string{"0:1.1.0 0s 120bpm!", "0:1.2.0 500ms 100bpm", "0:2.1.0 1.1s 80bpm!", "0:2.2.0 1.85s 60bpm"},
+			end:  2850 * time.Millisecond,
+		},
+		{
+			name:   "counting in at the tempo a ramp starts from",
+			preset: Preset{BPM: 90, TimeSignature: twoFour},
+			song: &Song{Name: "speeding up", Sections: []Section{
+				{Name: "intro", Bars: 1, BPM: 60, EndBPM: 120, TimeSignature: twoFour},
+			}},
+			countIn: 1,
+			bars:    1,
+			want:    []string{"0:0.1.0 0s 60bpm!", "0:0.2.0 1s 60bpm", "0:1.1.0 2s 60bpm!", "0:1.2.0 3s 120bpm"},
+			end:     3500 * time.Millisecond,
+		},
+		{
+			name:   "sections switch meter and tempo at the bar line",
+			preset: Preset{BPM: 90, TimeSignature: twoFour, Subdivision: 2, Swing: 75},
+			song: &Song{Name: "two parts", Sections: []Section{
+				{Name: "verse", Bars: 1, BPM: 60, TimeSignature: TimeSignature{Beats: 3, BeatValue: 4}, Subdivision: 1},
+				{Name: "chorus", Bars: 2, BPM: 120, TimeSignature: TimeSignature{Beats: 1, BeatValue: 4}, Swing: 60},
+			}},
+			bars: 9,
+			// The chorus keeps the metronome's eighths but swings its own way
+			want: []string{
+				"0:1.1.0 0s 60bpm!", "0:1.2.0 1s 60bpm", "0:1.3.0 2s 60bpm",
+				"1:2.1.0 3s 120bpm!", "1:2.1.1 3.3s 120bpm",
+				"1:3.1.0 3.5s 120bpm!", "1:3.1.1 3.8s 120bpm",
+			},
+			end: 4 * time.Second,
+		},
+		{
+			name:   "a song cut short by the bar count",
+			preset: Preset{BPM: 90, TimeSignature: twoFour},
+			song: &Song{Name: "long", Sections: []Section{
+				{Name: "all of it", Bars: 8, BPM: 120, TimeSignature: twoFour},
+			}},
+			bars: 1,
+			want: []string{"0:1.1.0 0s 120bpm!", "0:1.2.0 500ms 120bpm"},
+			end:  time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(100, CommonTimeSignatures[0])
+			if err := m.Apply(tt.preset); err != nil {
+				t.Fatal(err)
+			}
+			if tt.song != nil {
+				if err := m.SetSong(tt.song); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, end := clicks(m, tt.countIn, tt.bars)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clicks\n got %q\nwant %q", got, tt.want)
+			}
+			// Computed in float, so allow for rounding
+			if d := end - tt.end; d < -time.Microsecond || d > time.Microsecond {
+				t.Errorf("ends at %s, want %s", end, tt.end)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m := New(100, CommonTimeSignatures[0])
+	m.SetSubdivision(2)
+	start := time.Now()
+
+	beats, end := m.Plan(start, 2)
+	if len(beats) != 16 {
+		t.Fatalf("planned %d clicks for 2 bars of eighths, want 16", len(beats))
+	}
+	if want := start.Add(2 * m.BarDuration()); !end.Equal(want) {
+		t.Errorf("plan ends %s in, want %s", end.Sub(start), want.Sub(start))
+	}
+	if got, want := beats[15].Time.Sub(start), 4500*time.Millisecond; got != want {
+		t.Errorf("last click %s in, want %s", got, want)
+	}
+}
+
+func TestSectionRamp(t *testing.T) {
+	tests := []struct {
+		bpm, endBPM int
+		want        string
+	}{
+		{120, 0, ""},
+		{120, 120, ""},
+		{120, 70, "ritardando to 70 BPM"},
+		{90, 140, "accelerando to 140 BPM"},
+	}
+	for _, tt := range tests {
+		s := Section{BPM: tt.bpm, EndBPM: tt.endBPM}
+		if got := s.Ramp(); got != tt.want {
+			t.Errorf("%d to %d: Ramp() = %q, want %q", tt.bpm, tt.endBPM, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSong(t *testing.T) {
+	fourFour := CommonTimeSignatures[0]
+	tests := []struct {
+		name string
+		song Song
+		want string
+	}{
+		{"no sections", Song{Name: "empty"}, "a song needs at least one section"},
+		{"nameless section", Song{Sections: []Section{{Bars: 4, BPM: 120, TimeSignature: fourFour}}}, `section 1 (""): a section needs a name`},
+		{"too long", Song{Sections: []Section{{Name: "jam", Bars: MaxSectionBars + 1, BPM: 120, TimeSignature: fourFour}}}, `section 1 ("jam"): a section must be between 1 and 999 bars, got 1000`},
+		{"ramp out of range", Song{Sections: []Section{
+			{Name: "a", Bars: 4, BPM: 120, TimeSignature: fourFour},
+			{Name: "b", Bars: 4, BPM: 120, EndBPM: 400, TimeSignature: fourFour},
+		}}, `section 2 ("b"): end tempo: `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSong(tt.song)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error starting %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package metronome
+
+import (
+	"math"
+	"time"
+)
+
+// SetExternal hands timing over to an external clock, or takes it back.
+// While external, Start does nothing and the clock drives playback through
+// SyncStart, SyncBeat and SyncStop. Playback stops on every switch, and a
+// loaded song is dropped since the clock sets the tempo.
+func (m *Metronome) SetExternal(on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.halt()
+	m.external = on
+	m.linked = false
+	if on {
+		m.song = nil
+	}
+}
+
+// SetLinked places beats on a timeline shared with other apps, or stops
+// doing so. While linked, Start and Stop work as usual but the scheduler
+// stays idle: whatever keeps the timeline announces each beat through
+// SyncBeat, so playback waits for it. Count-ins aren't played, and a loaded
+// song is dropped since the timeline sets the tempo.
+func (m *Metronome) SetLinked(on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.halt()
+	m.linked = on
+	m.external = false
+	if on {
+		m.song = nil
+	}
+}
+
+// Linked reports whether beats are placed on a shared timeline
+func (m *Metronome) Linked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.linked
+}
+
+// External reports whether an external clock drives the metronome
+func (m *Metronome) External() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.external
+}
+
+// SyncStart starts playback for the external clock
+func (m *Metronome) SyncStart() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.external || m.playing {
+		return
+	}
+	m.restarted = false
+	m.start(0)
+}
+
+// SyncStop stops playback for the external clock
+func (m *Metronome) SyncStop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.external {
+		m.halt()
+	}
+}
+
+// SyncBeat announces a beat for the external clock or shared timeline: beat
+// index counted from 0 at the start of the song, due at the given time and
+// tempo. The beat and its subdivisions are placed in the current meter and
+// published at once. The tempo also becomes the metronome's, so it shows
+// wherever BPM does.
+func (m *Metronome) SyncBeat(index int, at time.Time, bpm float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !(m.external || m.linked) || !m.playing || index < 0 {
+		return
+	}
+
+	m.bpm = max(MinBPM, min(int(math.Round(bpm)), MaxBPM))
+	pat := m.pattern()
+	t := newTimeline([]segment{{pattern: pat}}, 0, at, m.run)
+	t.pos = position{bar: index/pat.beats + 1, beat: index%pat.beats + 1}
+	t.bar = t.pos.bar
+
+	// The beat is due a look-ahead before it sounds, as the scheduler's are
+	due := at.Add(-m.lookAhead)
+	for {
+		b := t.next()
+		m.publish(b, due)
+		if t.pos.sub == 0 {
+			m.currentBeat = b.Beat
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package metronome
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metrics"
+)
+
+func TestSyncBeatRecordsJitter(t *testing.T) {
+	m := New(120, CommonTimeSignatures[0])
+	m.SetExternal(true)
+	m.SyncStart()
+	defer m.SyncStop()
+
+	// Announced a look-ahead ahead of time, as it should be: on time
+	before := metrics.SchedulerJitter.Snapshot()
+	m.SyncBeat(0, time.Now().Add(m.LookAhead()+10*time.Millisecond), 120)
+	after := metrics.SchedulerJitter.Snapshot()
+	if after.Count != before.Count+1 || after.Counts[0] != before.Counts[0]+1 {
+		t.Errorf("a beat on time wasn't recorded as on time: %+v", after)
+	}
+
+	// Announced as it sounds, a whole look-ahead late
+	before = after
+	m.SyncBeat(1, time.Now(), 120)
+	if n := lateJitter(before, 50*time.Millisecond); n != 1 {
+		t.Errorf("a beat a look-ahead late was recorded %d times as 50ms late or more", n)
+	}
+}
+
+func TestSyncBeat(t *testing.T) {
+	m := New(120, CommonTimeSignatures[0])
+	m.SetSubdivision(2)
+	beats, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	// announced returns the clicks published since the last call
+	announced := func() []Beat {
+		var got []Beat
+		for {
+			select {
+			case b := <-beats:
+				got = append(got, b)
+			default:
+				return got
+			}
+		}
+	}
+
+	at := time.Now().Add(time.Second)
+	m.SyncBeat(0, at, 120)
+	if got := announced(); len(got) != 0 {
+		t.Errorf("announced %d clicks without an external clock", len(got))
+	}
+	m.SetExternal(true)
+	m.SyncBeat(0, at, 120)
+	if got := announced(); len(got) != 0 {
+		t.Errorf("announced %d clicks before the clock started", len(got))
+	}
+
+	// Beat 5 counted from 0 is the second beat of bar 2, with its eighth
+	m.SyncStart()
+	m.SyncBeat(5, at, 90)
+	got := announced()
+	if len(got) != 2 {
+		t.Fatalf("announced %d clicks for a beat of eighths, want 2", len(got))
+	}
+	if b := got[0]; b.Bar != 2 || b.Beat != 2 || b.Subdivision != 0 || !b.Time.Equal(at) || b.BPM != 90 {
+		t.Errorf("beat placed at %d.%d.%d, %s off its time, at %d BPM", b.Bar, b.Beat, b.Subdivision, b.Time.Sub(at), b.BPM)
+	}
+	if b := got[1]; b.Subdivision != 1 || b.Time.Sub(at) != time.Minute/90/2 {
+		t.Errorf("eighth placed at subdivision %d, %s after the beat", b.Subdivision, b.Time.Sub(at))
+	}
+	if m.BPM() != 90 || m.CurrentBeat() != 2 {
+		t.Errorf("metronome at beat %d and %d BPM, want beat 2 and 90 BPM", m.CurrentBeat(), m.BPM())
+	}
+	if !m.IsScheduled(got[0]) {
+		t.Error("a beat of the current run isn't scheduled")
+	}
+
+	// The clock's tempo is kept within what the metronome can play
+	m.SyncBeat(6, at, 1000)
+	announced()
+	if m.BPM() != MaxBPM {
+		t.Errorf("at %d BPM after a clock at 1000, want %d", m.BPM(), MaxBPM)
+	}
+
+	// Beats still queued from before a stop are dropped by consumers, even
+	// once the clock has started again
+	m.SyncStop()
+	if m.IsScheduled(got[0]) {
+		t.Error("a beat is still scheduled after the clock stopped")
+	}
+	m.SyncStart()
+	if m.IsScheduled(got[0]) {
+		t.Error("a beat from before the clock stopped is scheduled again")
+	}
+	m.SyncBeat(0, at, 120)
+	if fresh := announced(); len(fresh) == 0 || !m.IsScheduled(fresh[0]) {
+		t.Error("a beat after the clock started again isn't scheduled")
+	}
+	m.SyncStop()
+}
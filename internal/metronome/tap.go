@@ -0,0 +1,60 @@
+package metronome
+
+import (
+	"math"
+	"time"
+)
+
+// Tap tempo settings
+const (
+	maxTaps   = 8               // Taps averaged into the tempo
+	tapReset  = 2 * time.Second // A pause this long starts a new measurement
+	minTapGap = 60 * time.Second / MaxBPM
+)
+
+// TapTempo works out a tempo from a series of taps
+type TapTempo struct {
+	taps []time.Time
+}
+
+// Tap records a tap and returns the tempo so far. ok is false until there
+// are at least two taps to measure between.
+func (t *TapTempo) Tap(at time.Time) (bpm int, ok bool) {
+	if n := len(t.taps); n > 0 {
+		gap := at.Sub(t.taps[n-1])
+		if gap > tapReset || gap < 0 {
+			t.taps = t.taps[:0]
+		} else if gap < minTapGap {
+			// Faster than any tempo we can play, probably a key bounce
+			return t.BPM()
+		}
+	}
+
+	t.taps = append(t.taps, at)
+	if len(t.taps) > maxTaps {
+		t.taps = t.taps[len(t.taps)-maxTaps:]
+	}
+	return t.BPM()
+}
+
+// BPM returns the average tempo of the recorded taps
+func (t *TapTempo) BPM() (bpm int, ok bool) {
+	if len(t.taps) < 2 {
+		return 0, false
+	}
+
+	span := t.taps[len(t.taps)-1].Sub(t.taps[0])
+	interval := span / time.Duration(len(t.taps)-1)
+	bpm = int(math.Round(float64(time.Minute) / float64(interval)))
+	if bpm < MinBPM {
+		bpm = MinBPM
+	} else if bpm > MaxBPM {
+		bpm = MaxBPM
+	}
+	return bpm, true
+}
+
+// Reset forgets all taps
+func (t *TapTempo) Reset() {
+	t.taps = t.taps[:0]
+}
@@ -0,0 +1,209 @@
+package midi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// System messages used to keep other gear in time
+const (
+	TimingClock  byte = 0xF8
+	Start        byte = 0xFA
+	Continue     byte = 0xFB
+	Stop         byte = 0xFC
+	SongPosition byte = 0xF2
+)
+
+// PPQN is how many timing clocks make up a quarter note
+const PPQN = 24
+
+// clocksPerStep is the length of a song position step, a sixteenth note
+const clocksPerStep = PPQN / 4
+
+// maxSongPosition is the furthest a song position pointer can point
+const maxSongPosition = 1<<14 - 1
+
+// SongPositionPointer encodes a move to a number of sixteenth notes from the
+// start of the song
+func SongPositionPointer(sixteenths int) []byte {
+	sixteenths = max(0, min(sixteenths, maxSongPosition))
+	return []byte{SongPosition, byte(sixteenths & 0x7f), byte(sixteenths >> 7 & 0x7f)}
+}
+
+// ClocksPerBeat returns how many timing clocks a beat of the given note
+// value lasts, such as 12 for an eighth note
+func ClocksPerBeat(beatValue int) int {
+	if beatValue < 1 {
+		beatValue = 4
+	}
+	return max(1, PPQN*4/beatValue)
+}
+
+// event is a message waiting to be sent at a set time
+type event struct {
+	at        time.Time
+	msg       []byte
+	barClocks int // Length of the bar for the first clock of one, otherwise 0
+}
+
+// Clock sends MIDI timing clock and transport messages that follow a
+// metronome's schedule, so drum machines and DAWs play along:
+//
+//   - timing clocks run at 24 per quarter note whenever the metronome plays,
+//     including during a count-in, so followers can lock on to the tempo
+//   - Start is sent on the first downbeat after a count-in, and Stop when
+//     playback stops
+//   - when a change of settings restarts the metronome on a fresh downbeat,
+//     followers are stopped, moved to the start of the next bar with a song
+//     position pointer and sent Continue, so they stay in step
+type Clock struct {
+	metro *metronome.Metronome
+	out   Transport
+
+	// OnError, if set, is called when sending fails. It is called once
+	// until sending works again.
+	OnError func(err error)
+
+	queue     []event
+	rolling   bool // Start or Continue has been sent, and Stop not since
+	clocks    int  // Clocks since the start of the song
+	barStart  int  // Clock the current bar started on
+	barClocks int  // Length of the current bar in clocks
+	failing   bool
+}
+
+// NewClock creates a clock that sends to out
+func NewClock(metro *metronome.Metronome, out Transport) *Clock {
+	return &Clock{metro: metro, out: out}
+}
+
+// Run sends clock and transport messages for beats from a subscription to
+// the metronome until stop is closed, sending Stop on the way out if
+// followers are playing. Subscribe before starting the metronome so the
+// first beat isn't missed.
+func (c *Clock) Run(beats <-chan metronome.Beat, stop <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var stopped <-chan struct{} // Closed when the run being followed ends
+
+	for {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var due <-chan time.Time
+		if len(c.queue) > 0 {
+			timer.Reset(time.Until(c.queue[0].at))
+			due = timer.C
+		}
+
+		select {
+		case <-stop:
+			c.endRun()
+			return
+
+		case <-stopped:
+			stopped = nil
+			c.endRun()
+
+		case b := <-beats:
+			if b.Subdivision > 0 || !c.metro.IsScheduled(b) {
+				continue
+			}
+			// A restart can announce its first beat before the end of the
+			// last run has been noticed
+			if run := c.metro.Stopped(); run != stopped {
+				if stopped != nil {
+					c.endRun()
+				}
+				stopped = run
+			}
+			c.add(b)
+
+		case <-due:
+			e := c.queue[0]
+			c.queue = c.queue[1:]
+			c.fire(e)
+		}
+	}
+}
+
+// add queues the clocks of a beat, and Start or Continue before the first
+// one if this is where followers should start playing
+func (c *Clock) add(b metronome.Beat) {
+	perBeat := ClocksPerBeat(b.BeatValue)
+
+	if !b.IsCountIn() && !c.rolling && !c.queued() {
+		if c.metro.Restarted() && c.clocks > 0 {
+			// Carry on from the next bar line
+			pos := c.barStart
+			if c.clocks > c.barStart {
+				pos += c.barClocks
+			}
+			steps := (pos + clocksPerStep - 1) / clocksPerStep
+			c.clocks, c.barStart = steps*clocksPerStep, steps*clocksPerStep
+			c.queue = append(c.queue, event{at: b.Time, msg: SongPositionPointer(steps)}, event{at: b.Time, msg: []byte{Continue}})
+		} else {
+			c.clocks, c.barStart = 0, 0
+			c.queue = append(c.queue, event{at: b.Time, msg: []byte{Start}})
+		}
+	}
+
+	beatLen := time.Minute / time.Duration(b.BPM)
+	for k := 0; k < perBeat; k++ {
+		e := event{at: b.Time.Add(beatLen * time.Duration(k) / time.Duration(perBeat)), msg: []byte{TimingClock}}
+		if k == 0 && b.IsDownbeat() && !b.IsCountIn() {
+			e.barClocks = perBeat * b.Beats
+		}
+		c.queue = append(c.queue, e)
+	}
+}
+
+// queued reports whether Start or Continue is waiting to be sent
+func (c *Clock) queued() bool {
+	for _, e := range c.queue {
+		if e.msg[0] == Start || e.msg[0] == Continue {
+			return true
+		}
+	}
+	return false
+}
+
+// fire sends a queued message, keeping track of the song position
+func (c *Clock) fire(e event) {
+	c.send(e.msg)
+	switch e.msg[0] {
+	case Start, Continue:
+		c.rolling = true
+	case TimingClock:
+		if !c.rolling {
+			return
+		}
+		if e.barClocks > 0 {
+			c.barStart, c.barClocks = c.clocks, e.barClocks
+		}
+		c.clocks++
+	}
+}
+
+// endRun drops the clocks of a run that has ended and stops followers
+func (c *Clock) endRun() {
+	c.queue = nil
+	if c.rolling {
+		c.rolling = false
+		c.send([]byte{Stop})
+	}
+}
+
+// send writes a message, reporting the first failure of a streak
+func (c *Clock) send(msg []byte) {
+	err := c.out.Send(msg)
+	if err != nil && !c.failing && c.OnError != nil {
+		c.OnError(fmt.Errorf("sending midi clock: %w", err))
+	}
+	c.failing = err != nil
+}
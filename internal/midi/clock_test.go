@@ -0,0 +1,229 @@
+package midi
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// sent is a message a fake transport was given, and when
+type sent struct {
+	msg []byte
+	at  time.Time
+}
+
+// fakeTransport records what it is sent instead of reaching any hardware
+type fakeTransport struct {
+	mu   sync.Mutex
+	sent []sent
+	err  error
+}
+
+func (f *fakeTransport) Send(msg []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sent{msg: append([]byte(nil), msg...), at: time.Now()})
+	return f.err
+}
+
+func (f *fakeTransport) Close() error { return nil }
+
+// messages returns a copy of what has been sent so far
+func (f *fakeTransport) messages() []sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sent(nil), f.sent...)
+}
+
+// runClock runs a clock for metro into a fake transport until the returned
+// function is called
+func runClock(t *testing.T, metro *metronome.Metronome) (*fakeTransport, func()) {
+	t.Helper()
+	out := &fakeTransport{}
+	clock := NewClock(metro, out)
+	beats, unsubscribe := metro.Subscribe()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		clock.Run(beats, stop)
+		close(done)
+	}()
+	return out, func() {
+		close(stop)
+		<-done
+		unsubscribe()
+	}
+}
+
+// split separates timing clocks from the other messages
+func split(msgs []sent) (clocks []time.Time, others [][]byte) {
+	for _, m := range msgs {
+		if len(m.msg) == 1 && m.msg[0] == TimingClock {
+			clocks = append(clocks, m.at)
+		} else {
+			others = append(others, m.msg)
+		}
+	}
+	return clocks, others
+}
+
+func TestClockStartSpacingStop(t *testing.T) {
+	const bpm = 300 // 200ms beats, a clock every 8.3ms
+	metro := metronome.New(bpm, metronome.CommonTimeSignatures[0])
+	out, stopClock := runClock(t, metro)
+
+	metro.Start()
+	time.Sleep(4*time.Minute/bpm + metronome.DefaultLookAhead)
+	metro.Stop()
+	time.Sleep(20 * time.Millisecond)
+	stopClock()
+
+	msgs := out.messages()
+	if len(msgs) == 0 || !bytes.Equal(msgs[0].msg, []byte{Start}) {
+		t.Fatalf("first message %v, want Start", msgs[:min(1, len(msgs))])
+	}
+	clocks, others := split(msgs)
+	if want := [][]byte{{Start}, {Stop}}; len(others) != 2 || !bytes.Equal(others[0], want[0]) || !bytes.Equal(others[1], want[1]) {
+		t.Errorf("transport messages %x, want Start then Stop", others)
+	}
+	if !bytes.Equal(msgs[len(msgs)-1].msg, []byte{Stop}) {
+		t.Errorf("last message %x, want Stop", msgs[len(msgs)-1].msg)
+	}
+
+	// Four beats at 24 per quarter note
+	if len(clocks) < 4*PPQN || len(clocks) > 5*PPQN {
+		t.Fatalf("sent %d clocks over four beats, want %d to %d", len(clocks), 4*PPQN, 5*PPQN)
+	}
+	interval := time.Minute / bpm / PPQN
+	span := clocks[4*PPQN-1].Sub(clocks[0])
+	if want := interval * (4*PPQN - 1); span < want-10*time.Millisecond || span > want+10*time.Millisecond {
+		t.Errorf("96 clocks spanned %s, want %s", span, want)
+	}
+	for i := 1; i < 4*PPQN; i++ {
+		if gap := clocks[i].Sub(clocks[i-1]); gap > interval+8*time.Millisecond {
+			t.Errorf("clock %d came %s after the last, want about %s", i, gap, interval)
+		}
+	}
+}
+
+func TestClockRestartContinuesAtNextBar(t *testing.T) {
+	const bpm = 300
+	metro := metronome.New(bpm, metronome.CommonTimeSignatures[0])
+	out, stopClock := runClock(t, metro)
+	defer stopClock()
+
+	metro.Start()
+	// Part way through the second beat of the first bar
+	time.Sleep(time.Minute/bpm + time.Minute/bpm/2)
+	if err := metro.SetBPM(240); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Minute/240 + metronome.DefaultLookAhead)
+	metro.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	_, others := split(out.messages())
+	// The bar had 96 clocks, so followers carry on at sixteenth 16
+	want := [][]byte{{Start}, {Stop}, SongPositionPointer(16), {Continue}, {Stop}}
+	if len(others) != len(want) {
+		t.Fatalf("transport messages %x, want %x", others, want)
+	}
+	for i := range want {
+		if !bytes.Equal(others[i], want[i]) {
+			t.Errorf("message %d is %x, want %x", i, others[i], want[i])
+		}
+	}
+}
+
+func TestClockCountIn(t *testing.T) {
+	const bpm = 300
+	metro := metronome.New(bpm, metronome.CommonTimeSignatures[1]) // 3/4
+	metro.SetCountIn(1)
+	out, stopClock := runClock(t, metro)
+	defer stopClock()
+
+	metro.Start()
+	time.Sleep(4*time.Minute/bpm + metronome.DefaultLookAhead/2)
+	metro.Stop()
+	time.Sleep(20 * time.Millisecond)
+
+	// Clocks run through the count-in so followers lock on, with Start on
+	// the first real downbeat
+	msgs := out.messages()
+	startAt := -1
+	for i, m := range msgs {
+		if bytes.Equal(m.msg, []byte{Start}) {
+			startAt = i
+			break
+		}
+	}
+	if startAt != 3*PPQN {
+		t.Errorf("Start sent after %d clocks, want all %d of the count-in bar", startAt, 3*PPQN)
+	}
+}
+
+func TestClockReportsErrorsOnce(t *testing.T) {
+	metro := metronome.New(300, metronome.CommonTimeSignatures[0])
+	out := &fakeTransport{err: errors.New("unplugged")}
+	clock := NewClock(metro, out)
+	var mu sync.Mutex
+	reported := 0
+	clock.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported++
+	}
+	beats, unsubscribe := metro.Subscribe()
+	defer unsubscribe()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		clock.Run(beats, stop)
+		close(done)
+	}()
+	stopClock := func() {
+		close(stop)
+		<-done
+	}
+
+	metro.Start()
+	time.Sleep(100*time.Millisecond + metronome.DefaultLookAhead)
+	metro.Stop()
+	stopClock()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if reported != 1 {
+		t.Errorf("a streak of failures was reported %d times, want once", reported)
+	}
+}
+
+func TestClocksPerBeat(t *testing.T) {
+	for value, want := range map[int]int{1: 96, 2: 48, 4: 24, 8: 12, 16: 6, 32: 3, 0: 24} {
+		if got := ClocksPerBeat(value); got != want {
+			t.Errorf("ClocksPerBeat(%d) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestSongPositionPointer(t *testing.T) {
+	tests := []struct {
+		sixteenths int
+		want       []byte
+	}{
+		{0, []byte{SongPosition, 0, 0}},
+		{16, []byte{SongPosition, 16, 0}},
+		{200, []byte{SongPosition, 0x48, 0x01}},
+		{-3, []byte{SongPosition, 0, 0}},
+		{1 << 20, []byte{SongPosition, 0x7f, 0x7f}},
+	}
+	for _, tt := range tests {
+		if got := SongPositionPointer(tt.sixteenths); !bytes.Equal(got, tt.want) {
+			t.Errorf("SongPositionPointer(%d) = %x, want %x", tt.sixteenths, got, tt.want)
+		}
+	}
+}
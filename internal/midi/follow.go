@@ -0,0 +1,215 @@
+package midi
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// tempoWindow is how many recent clocks the tempo is worked out from. A
+// longer window smooths out more jitter but follows tempo changes slower.
+const tempoWindow = 48
+
+// clockGap is the longest pause between clocks before the tempo is worked
+// out afresh, as when the master's clock was stopped
+const clockGap = time.Second
+
+// Follower drives a metronome from an incoming MIDI clock, for when a DAW
+// or drum machine is the master:
+//
+//   - the tempo comes from a straight line fitted through the arrival times
+//     of the last 48 clocks, which smooths out jitter from the sender, the
+//     cable and the operating system
+//   - Start plays from the top, Continue plays on from the song position,
+//     Stop stops, and a Song Position Pointer moves to another sixteenth
+//   - each beat is announced a look-ahead window before it is predicted to
+//     land, so clicks sound on time rather than a clock late
+type Follower struct {
+	metro *metronome.Metronome
+
+	pulses  []time.Time   // Arrival times of recent clocks
+	period  time.Duration // Smoothed time between clocks, 0 until known
+	last    time.Time     // When the last clock arrived
+	fitted  time.Time     // When the last clock should have arrived, going by the tempo
+	pos     int           // Song position of the last clock
+	running bool          // Between Start or Continue and Stop
+	next    int           // Next beat to announce, counted from the start of the song
+}
+
+// NewFollower creates a follower for a metronome, putting it under external
+// control
+func NewFollower(metro *metronome.Metronome) *Follower {
+	metro.SetExternal(true)
+	return &Follower{metro: metro, pos: -1}
+}
+
+// bpm returns the tempo of the incoming clock in the metronome's beats, or 0
+// until enough clocks have arrived to tell
+func (f *Follower) bpm() float64 {
+	if f.period <= 0 {
+		return 0
+	}
+	return float64(time.Minute) / float64(f.period*time.Duration(f.clocksPerBeat()))
+}
+
+// Run reads MIDI from r until it ends or fails, following the clock. The
+// metronome stops when the input ends.
+func (f *Follower) Run(r io.Reader) error {
+	defer f.metro.SyncStop()
+
+	in := bufio.NewReader(r)
+	var status byte // System common message being read, if any
+	var data []byte
+	for {
+		c, err := in.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		switch {
+		case c >= 0xF8:
+			// Real-time messages can arrive in the middle of others
+			f.Handle([]byte{c}, now)
+		case c >= 0x80:
+			status, data = c, nil
+		case status == SongPosition:
+			data = append(data, c)
+			if len(data) == 2 {
+				f.Handle([]byte{SongPosition, data[0], data[1]}, now)
+				status = 0
+			}
+		}
+	}
+}
+
+// Handle acts on a single MIDI message that arrived at the given time.
+// Anything but clock and transport messages is ignored.
+func (f *Follower) Handle(msg []byte, at time.Time) {
+	if len(msg) == 0 {
+		return
+	}
+	switch msg[0] {
+	case TimingClock:
+		f.tick(at)
+
+	case Start:
+		f.pos = -1
+		f.next = 0
+		f.running = true
+		f.metro.SyncStart()
+		f.announce(at)
+
+	case Continue:
+		f.next = f.beatAfter(f.pos)
+		f.running = true
+		f.metro.SyncStart()
+		f.announce(at)
+
+	case Stop:
+		f.running = false
+		f.metro.SyncStop()
+
+	case SongPosition:
+		if len(msg) < 3 || f.running {
+			return
+		}
+		sixteenths := int(msg[1]&0x7f) | int(msg[2]&0x7f)<<7
+		f.pos = sixteenths*clocksPerStep - 1
+		f.next = f.beatAfter(f.pos)
+	}
+}
+
+// tick takes in a clock, refining the tempo and announcing beats that are
+// now close enough
+func (f *Follower) tick(at time.Time) {
+	if !f.last.IsZero() && at.Sub(f.last) > clockGap {
+		f.pulses = f.pulses[:0]
+	}
+	f.pulses = append(f.pulses, at)
+	if len(f.pulses) > tempoWindow {
+		f.pulses = f.pulses[len(f.pulses)-tempoWindow:]
+	}
+	f.period, f.fitted = fit(f.pulses)
+	f.last = at
+	if f.running {
+		f.pos++
+		f.announce(at)
+	}
+}
+
+// announce sends the metronome every beat due within the look-ahead window,
+// predicting when each lands from the last clock and the tempo
+func (f *Follower) announce(now time.Time) {
+	if !f.running {
+		return
+	}
+	perBeat := f.clocksPerBeat()
+	lookAhead := f.metro.LookAhead()
+	for {
+		q := f.next * perBeat
+		var at time.Time
+		switch {
+		case q < f.pos:
+			// Missed, as after the meter changed
+			f.next = f.beatAfter(f.pos - 1)
+			continue
+		case q == f.pos:
+			at = f.last
+		case f.period > 0 && now.Sub(f.last) <= clockGap:
+			at = f.fitted.Add(time.Duration(q-f.pos) * f.period)
+			if at.Sub(now) > lookAhead {
+				return
+			}
+		default:
+			return
+		}
+
+		bpm := f.bpm()
+		if bpm == 0 {
+			bpm = float64(f.metro.BPM())
+		}
+		f.metro.SyncBeat(f.next, at, bpm)
+		f.next++
+	}
+}
+
+// beatAfter returns the first beat at or after the clock following pos
+func (f *Follower) beatAfter(pos int) int {
+	perBeat := f.clocksPerBeat()
+	return (pos + 1 + perBeat - 1) / perBeat
+}
+
+// clocksPerBeat returns how many clocks make up one of the metronome's beats
+func (f *Follower) clocksPerBeat() int {
+	return ClocksPerBeat(f.metro.TimeSignature().BeatValue)
+}
+
+// fit draws the least-squares line through the clock times, returning the
+// time between clocks and when the last one should have arrived with the
+// jitter averaged out
+func fit(times []time.Time) (time.Duration, time.Time) {
+	n := len(times)
+	if n < 3 {
+		return 0, times[n-1]
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, t := range times {
+		x := float64(i)
+		y := float64(t.Sub(times[0]))
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	fn := float64(n)
+	slope := (fn*sumXY - sumX*sumY) / (fn*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / fn
+	return time.Duration(slope), times[0].Add(time.Duration(intercept + slope*(fn-1)))
+}
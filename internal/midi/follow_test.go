@@ -0,0 +1,190 @@
+package midi
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// clockPeriod is the time between clocks at 120 BPM
+const clockPeriod = time.Minute / 120 / PPQN
+
+// drain returns the beats waiting on a subscription
+func drain(beats <-chan metronome.Beat) []metronome.Beat {
+	var got []metronome.Beat
+	for {
+		select {
+		case b := <-beats:
+			got = append(got, b)
+		default:
+			return got
+		}
+	}
+}
+
+// near reports whether two times are within a millisecond of each other
+func near(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func TestBeatAfter(t *testing.T) {
+	tests := []struct {
+		sig  string
+		pos  int
+		want int
+	}{
+		{"4/4", -1, 0},
+		{"4/4", 0, 1},
+		{"4/4", 22, 1},
+		{"4/4", 23, 1},
+		{"4/4", 24, 2},
+		{"4/4", 95, 4},
+		{"6/8", -1, 0},
+		{"6/8", 11, 1},
+		{"6/8", 12, 2},
+	}
+	for _, tt := range tests {
+		ts, err := metronome.ParseTimeSignature(tt.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := NewFollower(metronome.New(120, ts))
+		if got := f.beatAfter(tt.pos); got != tt.want {
+			t.Errorf("in %s, beatAfter(%d) = %d, want %d", tt.sig, tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestFitSmoothsJitter(t *testing.T) {
+	t0 := time.Now()
+	times := make([]time.Time, tempoWindow)
+	for i := range times {
+		jitter := 2 * time.Millisecond
+		if i%2 == 1 {
+			jitter = -jitter
+		}
+		times[i] = t0.Add(time.Duration(i)*clockPeriod + jitter)
+	}
+
+	period, fitted := fit(times)
+	if d := period - clockPeriod; d < -50*time.Microsecond || d > 50*time.Microsecond {
+		t.Errorf("period %s, want about %s", period, clockPeriod)
+	}
+	// The last clock came 2ms early; the line puts it back where it belongs
+	ideal := t0.Add(time.Duration(len(times)-1) * clockPeriod)
+	if d := fitted.Sub(ideal); d < -500*time.Microsecond || d > 500*time.Microsecond {
+		t.Errorf("last clock fitted %s from where it should be, raw %s", d, times[len(times)-1].Sub(ideal))
+	}
+
+	// Too few clocks to tell the tempo
+	if period, fitted := fit(times[:2]); period != 0 || !fitted.Equal(times[1]) {
+		t.Errorf("two clocks gave %s at %s, want 0 at the last clock", period, fitted)
+	}
+}
+
+func TestFollowStartAndStop(t *testing.T) {
+	metro := metronome.New(90, metronome.CommonTimeSignatures[0])
+	f := NewFollower(metro)
+	beats, unsubscribe := metro.Subscribe()
+	defer unsubscribe()
+
+	t0 := time.Now()
+	f.Handle([]byte{Start}, t0)
+	if !metro.IsPlaying() {
+		t.Fatal("not playing after Start")
+	}
+	// Four beats of clocks, the first landing with Start
+	for i := 0; i < 4*PPQN; i++ {
+		f.Handle([]byte{TimingClock}, t0.Add(time.Duration(i)*clockPeriod))
+	}
+
+	// The fifth beat is within the look-ahead of the last clock
+	got := drain(beats)
+	if len(got) != 5 {
+		t.Fatalf("announced %d beats, want 5: %+v", len(got), got)
+	}
+	for i, b := range got {
+		bar, beat := i/4+1, i%4+1
+		if b.Bar != bar || b.Beat != beat {
+			t.Errorf("beat %d is %d.%d, want %d.%d", i, b.Bar, b.Beat, bar, beat)
+		}
+		if want := t0.Add(time.Duration(i) * time.Minute / 120); !near(b.Time, want) {
+			t.Errorf("beat %d due %s after Start, want %s", i, b.Time.Sub(t0), want.Sub(t0))
+		}
+		// The first beat comes before the tempo is known
+		if i > 0 && b.BPM != 120 {
+			t.Errorf("beat %d at %d BPM, want the clock's 120", i, b.BPM)
+		}
+	}
+
+	f.Handle([]byte{Stop}, t0.Add(4*PPQN*clockPeriod))
+	if metro.IsPlaying() {
+		t.Error("still playing after Stop")
+	}
+}
+
+func TestFollowSongPositionAndContinue(t *testing.T) {
+	metro := metronome.New(120, metronome.CommonTimeSignatures[0])
+	f := NewFollower(metro)
+	beats, unsubscribe := metro.Subscribe()
+	defer unsubscribe()
+
+	// Clocks while stopped set the tempo but don't move the position
+	t0 := time.Now()
+	for i := 0; i < 8; i++ {
+		f.Handle([]byte{TimingClock}, t0.Add(time.Duration(i)*clockPeriod))
+	}
+	// Sixteenth 16 is the top of bar 2
+	f.Handle(SongPositionPointer(16), t0)
+	if f.pos != 95 || f.next != 4 {
+		t.Fatalf("after pointing at sixteenth 16, pos %d and next beat %d, want 95 and 4", f.pos, f.next)
+	}
+
+	at := t0.Add(8 * clockPeriod)
+	f.Handle([]byte{Continue}, at)
+	f.Handle([]byte{TimingClock}, at)
+	got := drain(beats)
+	if len(got) == 0 || got[0].Bar != 2 || got[0].Beat != 1 || !near(got[0].Time, at) {
+		t.Fatalf("after Continue, announced %+v, want bar 2 beat 1 at the next clock", got)
+	}
+
+	// Pointers are ignored while running
+	f.Handle(SongPositionPointer(0), at)
+	if f.pos != 96 {
+		t.Errorf("a pointer while running moved pos to %d", f.pos)
+	}
+
+	// Stop and Continue carry on from where the clock stopped
+	f.Handle([]byte{Stop}, at)
+	f.Handle([]byte{Continue}, at)
+	if f.next != 5 {
+		t.Errorf("continuing from pos %d starts at beat %d, want 5", f.pos, f.next)
+	}
+}
+
+func TestFollowIgnoresShortMessages(t *testing.T) {
+	f := NewFollower(metronome.New(120, metronome.CommonTimeSignatures[0]))
+	f.Handle(nil, time.Now())
+	f.Handle([]byte{}, time.Now())
+	f.Handle([]byte{SongPosition, 16}, time.Now())
+	if f.pos != -1 {
+		t.Errorf("pos %d after a short pointer, want it left at -1", f.pos)
+	}
+}
+
+func TestFollowRunReadsPointerAroundClock(t *testing.T) {
+	metro := metronome.New(120, metronome.CommonTimeSignatures[0])
+	f := NewFollower(metro)
+
+	// A clock in the middle of a pointer still counts, and the pointer too
+	in := []byte{SongPosition, TimingClock, 16, 0, 0xB0, 7, 100}
+	if err := f.Run(bytes.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	if f.pos != 95 || len(f.pulses) != 1 {
+		t.Errorf("pos %d with %d clocks, want 95 with 1", f.pos, len(f.pulses))
+	}
+}
@@ -0,0 +1,46 @@
+package midi
+
+import (
+	"os"
+	"sync"
+)
+
+// Transport carries raw MIDI messages to a device, port or file
+type Transport interface {
+	// Send writes a single complete MIDI message
+	Send(msg []byte) error
+	Close() error
+}
+
+// File is a transport that writes MIDI bytes to a file, such as an ALSA raw
+// MIDI device (/dev/snd/midiC1D0), a FIFO read by another program, or a
+// plain file to capture the stream
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens a transport that writes to path, creating it as a plain
+// file if nothing is there. Opening a FIFO waits until something reads it.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+// Send writes a message in a single write, so devices never see half of one
+func (t *File) Send(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.f.Write(msg)
+	return err
+}
+
+// Close closes the file
+func (t *File) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.f.Close()
+}
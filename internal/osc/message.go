@@ -0,0 +1,253 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Message is an OSC message: an address such as "/metrognome/bpm" and its
+// arguments. Arguments are int32, float32, string, []byte (a blob), int64,
+// float64, bool or nil.
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// bundleTag starts every OSC bundle
+const bundleTag = "#bundle"
+
+// String shows a message the way OSC tools usually print it
+func (m Message) String() string {
+	var b strings.Builder
+	b.WriteString(m.Address)
+	for _, arg := range m.Args {
+		fmt.Fprintf(&b, " %v", arg)
+	}
+	return b.String()
+}
+
+// MarshalBinary encodes the message as an OSC packet
+func (m Message) MarshalBinary() ([]byte, error) {
+	if !strings.HasPrefix(m.Address, "/") {
+		return nil, fmt.Errorf("osc address %q must start with /", m.Address)
+	}
+
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, v)
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, math.Float32bits(v))
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(&args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, pad(len(v))-len(v)))
+		case int64:
+			tags = append(tags, 'h')
+			binary.Write(&args, binary.BigEndian, v)
+		case float64:
+			tags = append(tags, 'd')
+			binary.Write(&args, binary.BigEndian, math.Float64bits(v))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		case nil:
+			tags = append(tags, 'N')
+		default:
+			return nil, fmt.Errorf("osc can't send a %T argument", arg)
+		}
+	}
+
+	var packet bytes.Buffer
+	writeString(&packet, m.Address)
+	writeString(&packet, string(tags))
+	packet.Write(args.Bytes())
+	return packet.Bytes(), nil
+}
+
+// Decode reads the messages in an OSC packet. The messages of a bundle,
+// including nested ones, are returned in order; their time tags are ignored.
+func Decode(packet []byte) ([]Message, error) {
+	if bytes.HasPrefix(packet, []byte(bundleTag+"\x00")) {
+		return decodeBundle(packet)
+	}
+	m, err := decodeMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{m}, nil
+}
+
+// decodeBundle reads each element of a bundle
+func decodeBundle(packet []byte) ([]Message, error) {
+	r := &reader{data: packet}
+	r.string() // #bundle
+	if _, err := r.take(8); err != nil {
+		return nil, errors.New("osc bundle has no time tag")
+	}
+
+	var msgs []Message
+	for len(r.data) > 0 {
+		size, err := r.int32()
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 || size%4 != 0 {
+			return nil, fmt.Errorf("osc bundle element has a bad size %d", size)
+		}
+		element, err := r.take(int(size))
+		if err != nil {
+			return nil, err
+		}
+		inner, err := Decode(element)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, inner...)
+	}
+	return msgs, nil
+}
+
+// decodeMessage reads a single message
+func decodeMessage(packet []byte) (Message, error) {
+	r := &reader{data: packet}
+	address, err := r.string()
+	if err != nil {
+		return Message{}, err
+	}
+	if !strings.HasPrefix(address, "/") {
+		return Message{}, fmt.Errorf("osc address %q must start with /", address)
+	}
+	m := Message{Address: address}
+	if len(r.data) == 0 {
+		// Very old senders leave out the type tags when there are no arguments
+		return m, nil
+	}
+
+	tags, err := r.string()
+	if err != nil {
+		return Message{}, err
+	}
+	if !strings.HasPrefix(tags, ",") {
+		return Message{}, fmt.Errorf("osc message %s has no type tags", address)
+	}
+	for _, tag := range tags[1:] {
+		arg, err := r.arg(tag)
+		if err != nil {
+			return Message{}, fmt.Errorf("osc message %s: %w", address, err)
+		}
+		m.Args = append(m.Args, arg)
+	}
+	return m, nil
+}
+
+// reader reads the parts of a packet in order
+type reader struct {
+	data []byte
+}
+
+// take reads the next n bytes
+func (r *reader) take(n int) ([]byte, error) {
+	if n > len(r.data) {
+		return nil, errors.New("osc packet is cut short")
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+// int32 reads a big-endian 32-bit integer
+func (r *reader) int32() (int32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+// int64 reads a big-endian 64-bit integer
+func (r *reader) int64() (int64, error) {
+	b, err := r.take(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// string reads a null-terminated string padded to four bytes
+func (r *reader) string() (string, error) {
+	end := bytes.IndexByte(r.data, 0)
+	if end < 0 {
+		return "", errors.New("osc string is not terminated")
+	}
+	b, err := r.take(pad(end + 1))
+	if err != nil {
+		return "", err
+	}
+	return string(b[:end]), nil
+}
+
+// arg reads an argument of the given type
+func (r *reader) arg(tag rune) (any, error) {
+	switch tag {
+	case 'i':
+		return r.int32()
+	case 'f':
+		v, err := r.int32()
+		return math.Float32frombits(uint32(v)), err
+	case 's', 'S':
+		return r.string()
+	case 'b':
+		size, err := r.int32()
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("blob has a negative size %d", size)
+		}
+		b, err := r.take(pad(int(size)))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b[:size]...), nil
+	case 'h':
+		return r.int64()
+	case 'd':
+		v, err := r.int64()
+		return math.Float64frombits(uint64(v)), err
+	case 'T':
+		return true, nil
+	case 'F':
+		return false, nil
+	case 'N', 'I':
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported argument type %q", tag)
+	}
+}
+
+// writeString writes a null-terminated string padded to four bytes
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.Write(make([]byte, pad(len(s)+1)-len(s)))
+}
+
+// pad rounds n up to a multiple of four
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
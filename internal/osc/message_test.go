@@ -0,0 +1,186 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []Message{
+		{Address: "/metrognome/start"},
+		{Address: "/metrognome/bpm", Args: []any{int32(132)}},
+		{Address: "/metrognome/bpm", Args: []any{float32(99.5)}},
+		{Address: "/metrognome/preset", Args: []any{"Toadstool Waltz"}},
+		{Address: "/metrognome/beat", Args: []any{int32(1), int32(-2), int32(1), int32(120)}},
+		{Address: "/mixed", Args: []any{"abc", int32(7), float32(-0.25), "", []byte{1, 2, 3, 4, 5}, int64(-1 << 40), 3.5, true, false, nil}},
+	}
+	for _, want := range tests {
+		t.Run(want.String(), func(t *testing.T) {
+			packet, err := want.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(packet)%4 != 0 {
+				t.Errorf("packet is %d bytes, not a multiple of four", len(packet))
+			}
+			got, err := Decode(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalPadding(t *testing.T) {
+	tests := []struct {
+		msg  Message
+		want []byte
+	}{
+		{
+			// "/abc" needs a whole word of nulls after it
+			msg:  Message{Address: "/abc"},
+			want: []byte("/abc\x00\x00\x00\x00,\x00\x00\x00"),
+		},
+		{
+			msg:  Message{Address: "/ab", Args: []any{int32(1)}},
+			want: []byte("/ab\x00,i\x00\x00\x00\x00\x00\x01"),
+		},
+		{
+			msg:  Message{Address: "/s", Args: []any{"hello"}},
+			want: []byte("/s\x00\x00,s\x00\x00hello\x00\x00\x00"),
+		},
+		{
+			msg:  Message{Address: "/f", Args: []any{float32(1)}},
+			want: []byte("/f\x00\x00,f\x00\x00\x3f\x80\x00\x00"),
+		},
+		{
+			msg:  Message{Address: "/b", Args: []any{[]byte{9}}},
+			want: []byte("/b\x00\x00,b\x00\x00\x00\x00\x00\x01\x09\x00\x00\x00"),
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s encoded as %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalRejects(t *testing.T) {
+	if _, err := (Message{Address: "metrognome/bpm"}).MarshalBinary(); err == nil {
+		t.Error("address without a leading / was encoded")
+	}
+	if _, err := (Message{Address: "/bpm", Args: []any{132}}).MarshalBinary(); err == nil {
+		t.Error("a plain int was encoded; OSC needs int32 or int64")
+	}
+}
+
+// bundle wraps packets in a bundle with an immediate time tag
+func bundle(elements ...[]byte) []byte {
+	b := []byte("#bundle\x00\x00\x00\x00\x00\x00\x00\x00\x01")
+	for _, e := range elements {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(e)))
+		b = append(b, e...)
+	}
+	return b
+}
+
+// mustMarshal encodes a message or fails the test
+func mustMarshal(t *testing.T, m Message) []byte {
+	t.Helper()
+	packet, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestDecodeBundle(t *testing.T) {
+	start := Message{Address: AddrStart}
+	bpm := Message{Address: AddrBPM, Args: []any{int32(100)}}
+	stop := Message{Address: AddrStop}
+
+	packet := bundle(
+		mustMarshal(t, start),
+		bundle(mustMarshal(t, bpm)), // Nested
+		mustMarshal(t, stop),
+	)
+	got, err := Decode(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Message{start, bpm, stop}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	empty, err := Decode(bundle())
+	if err != nil || len(empty) != 0 {
+		t.Errorf("empty bundle gave %v, %v", empty, err)
+	}
+}
+
+func TestDecodeWithoutTypeTags(t *testing.T) {
+	got, err := Decode([]byte("/metrognome/stop\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Address != AddrStop || got[0].Args != nil {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":                 {},
+		"unterminated address":  []byte("/abc"),
+		"address without slash": []byte("abc\x00,\x00\x00\x00"),
+		"tags without comma":    []byte("/abc\x00\x00\x00\x00i\x00\x00\x00\x00\x00\x00\x01"),
+		"missing int":           []byte("/abc\x00\x00\x00\x00,i\x00\x00"),
+		"short int":             []byte("/abc\x00\x00\x00\x00,i\x00\x00\x00\x01"),
+		"missing string":        []byte("/abc\x00\x00\x00\x00,s\x00\x00"),
+		"unterminated string":   []byte("/abc\x00\x00\x00\x00,s\x00\x00abcd"),
+		"negative blob":         []byte("/abc\x00\x00\x00\x00,b\x00\x00\xff\xff\xff\xff"),
+		"huge blob":             []byte("/abc\x00\x00\x00\x00,b\x00\x00\x7f\xff\xff\xff"),
+		"unknown type":          []byte("/abc\x00\x00\x00\x00,x\x00\x00"),
+		"bundle without time":   []byte("#bundle\x00\x00\x00"),
+		"bundle element size":   append(bundle(), 0, 0, 0, 3, 1, 2, 3),
+		"negative element size": append(bundle(), 0xff, 0xff, 0xff, 0xfc),
+		"element past the end":  append(bundle(), 0, 0, 0, 16, '/', 'a', 0, 0),
+		"bad nested message":    bundle([]byte("nope")),
+	}
+	for name, packet := range tests {
+		t.Run(name, func(t *testing.T) {
+			if msgs, err := Decode(packet); err == nil {
+				t.Errorf("decoded %v, want an error", msgs)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	full := bundle(
+		mustMarshal(t, Message{Address: "/mixed", Args: []any{"abc", int32(7), float32(1), []byte{1, 2, 3}, int64(5), 2.5, true, nil}}),
+		mustMarshal(t, Message{Address: AddrStop}),
+	)
+	// Every cut must be reported rather than panic; a few happen to fall on
+	// the end of the bundle's first element and decode cleanly
+	for n := 0; n < len(full); n++ {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("Decode panicked on the first %d bytes: %v", n, r)
+				}
+			}()
+			Decode(full[:n])
+		}()
+	}
+}
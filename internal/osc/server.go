@@ -0,0 +1,224 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/presets"
+)
+
+// Addresses of the messages the metronome sends and understands:
+//
+//	/metrognome/beat   bar beat accent bpm   sent on every beat (all ints)
+//	/metrognome/bpm    tempo                 sets the tempo (int or float)
+//	/metrognome/start                        starts playing
+//	/metrognome/stop                         stops playing
+//	/metrognome/toggle                       starts or stops
+//	/metrognome/preset name                  applies a built-in or saved preset
+const (
+	AddrBeat   = "/metrognome/beat"
+	AddrBPM    = "/metrognome/bpm"
+	AddrStart  = "/metrognome/start"
+	AddrStop   = "/metrognome/stop"
+	AddrToggle = "/metrognome/toggle"
+	AddrPreset = "/metrognome/preset"
+)
+
+// errExternal is returned when asked to start while an external clock
+// decides when to play
+var errExternal = errors.New("the metronome is following an external clock")
+
+// maxPacket is the largest OSC packet read, the most a UDP datagram holds
+const maxPacket = 65535
+
+// Server drives a metronome from OSC messages and sends its beats as OSC
+type Server struct {
+	metro *metronome.Metronome
+
+	// OnChange, if set, is called after a message changes the metronome
+	// with a short description of what happened, such as "bpm 132"
+	OnChange func(change string)
+
+	// OnError, if set, is called with messages that could not be handled,
+	// since OSC has no way to answer them
+	OnError func(err error)
+}
+
+// NewServer creates an OSC server for a metronome
+func NewServer(metro *metronome.Metronome) *Server {
+	return &Server{metro: metro}
+}
+
+// Serve handles OSC packets arriving on conn until it is closed
+func (s *Server) Serve(conn net.PacketConn) error {
+	buf := make([]byte, maxPacket)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		msgs, err := Decode(buf[:n])
+		if err != nil {
+			s.reportError(err)
+			continue
+		}
+		for _, msg := range msgs {
+			change, err := s.Handle(msg)
+			if err != nil {
+				s.reportError(fmt.Errorf("%s: %w", msg.Address, err))
+				continue
+			}
+			if change != "" && s.OnChange != nil {
+				s.OnChange(change)
+			}
+		}
+	}
+}
+
+// Handle carries out a single message, returning a description of the
+// change. Messages for other addresses are ignored, so the metronome can
+// share a port with the rest of a rig.
+func (s *Server) Handle(msg Message) (string, error) {
+	switch msg.Address {
+	case AddrBPM:
+		if len(msg.Args) != 1 {
+			return "", fmt.Errorf("expected one tempo argument, got %d", len(msg.Args))
+		}
+		bpm, ok := number(msg.Args[0])
+		if !ok {
+			return "", fmt.Errorf("the tempo must be a number, got %v", msg.Args[0])
+		}
+		if err := s.metro.SetBPM(bpm); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("bpm %d", bpm), nil
+
+	case AddrStart:
+		if s.metro.External() {
+			return "", errExternal
+		}
+		s.metro.Start()
+		return "start", nil
+
+	case AddrStop:
+		s.metro.Stop()
+		return "stop", nil
+
+	case AddrToggle:
+		if s.metro.IsPlaying() {
+			s.metro.Stop()
+			return "stop", nil
+		}
+		if s.metro.External() {
+			return "", errExternal
+		}
+		s.metro.Start()
+		return "start", nil
+
+	case AddrPreset:
+		if len(msg.Args) != 1 {
+			return "", fmt.Errorf("expected one preset name, got %d arguments", len(msg.Args))
+		}
+		name, ok := msg.Args[0].(string)
+		if !ok {
+			return "", fmt.Errorf("the preset name must be a string, got %v", msg.Args[0])
+		}
+		p, ok, err := presets.Find(name)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("no preset called %q", name)
+		}
+		if err := s.metro.Apply(p); err != nil {
+			return "", err
+		}
+		return "preset " + p.Name, nil
+	}
+	return "", nil
+}
+
+// SendBeats sends a beat message on conn as each beat sounds, until stop is
+// closed. Subdivisions are not sent. A receiver that isn't running yet is
+// reported to OnError once, and beats keep being sent until it appears.
+func (s *Server) SendBeats(conn net.Conn, stop <-chan struct{}) {
+	beats, unsubscribe := s.metro.Subscribe()
+	defer unsubscribe()
+
+	failing := false
+	for {
+		select {
+		case <-stop:
+			return
+		case b := <-beats:
+			if b.Subdivision > 0 {
+				continue
+			}
+			// Beats arrive a look-ahead window early
+			wait := time.NewTimer(time.Until(b.Time))
+			select {
+			case <-stop:
+				wait.Stop()
+				return
+			case <-wait.C:
+			}
+			if !s.metro.IsScheduled(b) {
+				continue
+			}
+			err := send(conn, BeatMessage(b))
+			if err != nil && !failing {
+				s.reportError(fmt.Errorf("sending beats: %w", err))
+			}
+			failing = err != nil
+		}
+	}
+}
+
+// BeatMessage describes a beat as an OSC message
+func BeatMessage(b metronome.Beat) Message {
+	accent := int32(0)
+	if b.Accent {
+		accent = 1
+	}
+	return Message{Address: AddrBeat, Args: []any{int32(b.Bar), int32(b.Beat), accent, int32(b.BPM)}}
+}
+
+// send writes a message to conn
+func send(conn net.Conn, msg Message) error {
+	packet, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(packet)
+	return err
+}
+
+// reportError passes an error to OnError, if set
+func (s *Server) reportError(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+// number reads a numeric argument as a whole number
+func number(arg any) (int, bool) {
+	switch v := arg.(type) {
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float32:
+		return int(math.Round(float64(v))), true
+	case float64:
+		return int(math.Round(v)), true
+	}
+	return 0, false
+}
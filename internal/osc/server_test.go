@@ -0,0 +1,207 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// waitFor polls until cond holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeLoopback(t *testing.T) {
+	// Presets are looked up in the config directory; keep the user's out of it
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	metro := metronome.New(120, metronome.CommonTimeSignatures[0])
+	defer metro.Stop()
+	srv := NewServer(metro)
+
+	var mu sync.Mutex
+	var changes []string
+	var errs []error
+	srv.OnChange = func(change string) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, change)
+	}
+	srv.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	seen := func(n int) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(changes)+len(errs) >= n
+		}
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	sent := 0
+	sendMsg := func(m Message) {
+		t.Helper()
+		if err := send(client, m); err != nil {
+			t.Fatal(err)
+		}
+		sent++
+		waitFor(t, m.String(), seen(sent))
+	}
+
+	sendMsg(Message{Address: AddrBPM, Args: []any{int32(132)}})
+	if metro.BPM() != 132 {
+		t.Errorf("bpm = %d after /metrognome/bpm 132", metro.BPM())
+	}
+	sendMsg(Message{Address: AddrBPM, Args: []any{float32(99.6)}})
+	if metro.BPM() != 100 {
+		t.Errorf("bpm = %d after /metrognome/bpm 99.6, want it rounded to 100", metro.BPM())
+	}
+	sendMsg(Message{Address: AddrStart})
+	if !metro.IsPlaying() {
+		t.Error("not playing after /metrognome/start")
+	}
+	sendMsg(Message{Address: AddrStop})
+	if metro.IsPlaying() {
+		t.Error("still playing after /metrognome/stop")
+	}
+	sendMsg(Message{Address: AddrPreset, Args: []any{"toadstool waltz"}})
+	if metro.BPM() != 90 || metro.TimeSignature().String() != "3/4" {
+		t.Errorf("got %d BPM in %s after the Toadstool Waltz preset", metro.BPM(), metro.TimeSignature())
+	}
+	sendMsg(Message{Address: AddrBPM, Args: []any{"fast"}})
+	sendMsg(Message{Address: AddrPreset, Args: []any{"No Such Dance"}})
+
+	// Other addresses are ignored, and bad packets reported
+	if _, err := client.Write([]byte("/other/thing\x00\x00\x00\x00,\x00\x00\x00")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Write([]byte("garbage")); err != nil {
+		t.Fatal(err)
+	}
+	sent++
+	waitFor(t, "the bad packet", seen(sent))
+
+	mu.Lock()
+	wantChanges := []string{"bpm 132", "bpm 100", "start", "stop", "preset Toadstool Waltz"}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("changes %q, want %q", changes, wantChanges)
+	}
+	wantErrs := []string{"/metrognome/bpm: the tempo must be a number", `/metrognome/preset: no preset called "No Such Dance"`, "osc string is not terminated"}
+	if len(errs) != len(wantErrs) {
+		t.Errorf("errors %v, want %d", errs, len(wantErrs))
+	} else {
+		for i, err := range errs {
+			if !strings.Contains(err.Error(), wantErrs[i]) {
+				t.Errorf("error %q, want one containing %q", err, wantErrs[i])
+			}
+		}
+	}
+	mu.Unlock()
+
+	conn.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v after the connection closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve didn't return after the connection closed")
+	}
+}
+
+func TestSendBeatsLoopback(t *testing.T) {
+	const bpm = 240
+	metro := metronome.New(bpm, metronome.CommonTimeSignatures[1]) // 3/4
+	metro.SetSubdivision(2)
+	defer metro.Stop()
+	srv := NewServer(metro)
+
+	receiver, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+	conn, err := net.Dial("udp", receiver.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		srv.SendBeats(conn, stop)
+		close(done)
+	}()
+	// Give SendBeats time to subscribe before the first beat is announced
+	time.Sleep(50 * time.Millisecond)
+	metro.Start()
+
+	buf := make([]byte, maxPacket)
+	var prev time.Time
+	for i := 0; i < 4; i++ {
+		receiver.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := receiver.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now()
+		msgs, err := Decode(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Subdivisions aren't sent, so these are beats 1, 2, 3, 1
+		beat := i%3 + 1
+		accent := int32(0)
+		if beat == 1 {
+			accent = 1
+		}
+		want := Message{Address: AddrBeat, Args: []any{int32(i/3 + 1), int32(beat), accent, int32(bpm)}}
+		if len(msgs) != 1 || !reflect.DeepEqual(msgs[0], want) {
+			t.Errorf("got %v, want %v", msgs, want)
+		}
+
+		// Sent as each beat sounds, not when it is announced
+		if !prev.IsZero() {
+			if gap := at.Sub(prev); gap < time.Minute/bpm/2 {
+				t.Errorf("beats arrived %s apart, want about %s", gap, time.Minute/bpm)
+			}
+		}
+		prev = at
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("SendBeats didn't return after stop closed")
+	}
+}
@@ -0,0 +1,149 @@
+package presets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Preset files, whether the user's own store or a shared library, look like
+//
+//	{
+//	  "format": "metrognome-presets",
+//	  "version": 1,
+//	  "presets": [
+//	    {"name": "Toadstool Waltz", "bpm": 90, "time_signature": "3/4"}
+//	  ]
+//	}
+//
+// Files from older versions are migrated forward when they are read.
+
+// Format is the value of the "format" field of a preset file
+const Format = "metrognome-presets"
+
+// Version is the schema version written to new preset files
+const Version = 1
+
+// document is the layout of a preset file
+type document struct {
+	Format  string             `json:"format"`
+	Version int                `json:"version"`
+	Presets []metronome.Preset `json:"presets"`
+}
+
+// migrations upgrade a raw preset file from the version it is keyed by to
+// the next one
+var migrations = map[int]func(doc map[string]any) error{
+	0: migrateUnversioned,
+}
+
+// Encode writes presets as a preset file of the current version
+func Encode(w io.Writer, presets []metronome.Preset) error {
+	if presets == nil {
+		presets = []metronome.Preset{}
+	}
+	data, err := json.MarshalIndent(document{Format: Format, Version: Version, Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Decode reads a preset file of any version, migrating it forward, and
+// checks that every preset can be played
+func Decode(r io.Reader) ([]metronome.Preset, error) {
+	var top any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&top); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is empty")
+		}
+		return nil, err
+	}
+
+	var raw map[string]any
+	switch top := top.(type) {
+	case map[string]any:
+		raw = top
+	case []any:
+		// A bare list of presets from before there was a file format
+		raw = map[string]any{"presets": top}
+	default:
+		return nil, errors.New("not a preset file: expected an object or a list of presets")
+	}
+
+	if format, ok := raw["format"]; ok && format != Format {
+		return nil, fmt.Errorf("not a preset file: format is %v, expected %q", format, Format)
+	}
+
+	version := 0
+	if v, ok := raw["version"]; ok {
+		n, ok := v.(json.Number)
+		i, err := n.Int64()
+		if !ok || err != nil {
+			return nil, fmt.Errorf("version %v is not a whole number", v)
+		}
+		version = int(i)
+	}
+	if version > Version {
+		return nil, fmt.Errorf("preset file version %d is newer than this metrognome understands (%d); please upgrade", version, Version)
+	}
+	for ; version < Version; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("preset file version %d is not supported", version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("upgrading from version %d: %w", version, err)
+		}
+	}
+
+	// Round trip through JSON to get typed presets out of the migrated map
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for i, p := range doc.Presets {
+		if err := metronome.ValidatePreset(p); err != nil {
+			return nil, fmt.Errorf("preset %d (%q): %w", i+1, p.Name, err)
+		}
+	}
+	return doc.Presets, nil
+}
+
+// migrateUnversioned upgrades files written before preset files had a
+// version: the first presets.json files, and plain JSON dumps of presets
+// whose time signatures are objects such as {"Beats": 3, "BeatValue": 4}
+func migrateUnversioned(doc map[string]any) error {
+	presets, _ := doc["presets"].([]any)
+	for i, p := range presets {
+		preset, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("preset %d is not an object", i+1)
+		}
+		for _, k := range []string{"TimeSignature", "time_signature"} {
+			ts, ok := preset[k].(map[string]any)
+			if !ok {
+				continue
+			}
+			beats, value := ts["Beats"], ts["BeatValue"]
+			if beats == nil || value == nil {
+				return fmt.Errorf("preset %d has a time signature without Beats and BeatValue", i+1)
+			}
+			delete(preset, k)
+			preset["time_signature"] = fmt.Sprintf("%v/%v", beats, value)
+		}
+	}
+	doc["format"] = Format
+	doc["version"] = 1
+	return nil
+}
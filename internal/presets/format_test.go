@@ -0,0 +1,201 @@
+package presets
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// mustSig parses a time signature or fails the test
+func mustSig(t *testing.T, s string) metronome.TimeSignature {
+	t.Helper()
+	ts, err := metronome.ParseTimeSignature(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	want := []metronome.Preset{
+		{Name: "Toadstool Waltz", BPM: 90, TimeSignature: mustSig(t, "3/4"), Description: "Dance beneath the moonlit mushrooms"},
+		{Name: "Odd Burrow", BPM: 132, TimeSignature: mustSig(t, "7/8"), Subdivision: 2, Accents: []int{1, 3, 5}, Swing: 60},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"time_signature": "7/8"`) {
+		t.Errorf("time signature not written as text:\n%s", buf.String())
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the presets:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d presets, want none", len(got))
+	}
+}
+
+func TestTimeSignatureText(t *testing.T) {
+	for _, s := range []string{"4/4", "3/4", "6/8", "7/8", "11/16"} {
+		ts := mustSig(t, s)
+		text, err := ts.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(text) != s {
+			t.Errorf("MarshalText(%s) = %q", s, text)
+		}
+		var back metronome.TimeSignature
+		if err := back.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText(%q): %v", text, err)
+		}
+		if back != ts {
+			t.Errorf("UnmarshalText(%q) = %+v, want %+v", text, back, ts)
+		}
+	}
+
+	// Common signatures get their gnome names back
+	var common metronome.TimeSignature
+	if err := common.UnmarshalText([]byte("4/4")); err != nil {
+		t.Fatal(err)
+	}
+	if common != metronome.CommonTimeSignatures[0] {
+		t.Errorf("4/4 = %+v, want %+v", common, metronome.CommonTimeSignatures[0])
+	}
+
+	for _, bad := range []string{"", "4", "4/", "/4", "4/4 ", "a/b", "4/3", "0/4"} {
+		var ts metronome.TimeSignature
+		if err := ts.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalText(%q) = %+v, want an error", bad, ts)
+		}
+	}
+}
+
+func TestDecodeMigrates(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"bare list", `[{"name": "Burrow", "bpm": 100, "time_signature": "5/4"}]`},
+		{"bare list of structs", `[{"Name": "Burrow", "BPM": 100, "TimeSignature": {"Beats": 5, "BeatValue": 4, "Name": "5/4"}}]`},
+		{"unversioned object", `{"presets": [{"name": "Burrow", "bpm": 100, "time_signature": {"Beats": 5, "BeatValue": 4}}]}`},
+	}
+	want := []metronome.Preset{{Name: "Burrow", BPM: 100, TimeSignature: mustSig(t, "5/4")}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"empty", ``, "file is empty"},
+		{"newer version", `{"format": "metrognome-presets", "version": 2, "presets": []}`, "newer than this metrognome understands"},
+		{"other format", `{"format": "gnome-songs", "version": 1}`, "not a preset file"},
+		{"not an object", `"waltz"`, "not a preset file"},
+		{"fractional version", `{"version": 1.5, "presets": []}`, "not a whole number"},
+		{"half a time signature", `[{"name": "Burrow", "bpm": 100, "time_signature": {"Beats": 5}}]`, "without Beats and BeatValue"},
+		{"unplayable", `{"version": 1, "presets": [{"name": "Burrow", "bpm": 5000, "time_signature": "4/4"}]}`, `preset 1 ("Burrow")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestImportDuplicates(t *testing.T) {
+	existing := metronome.Preset{Name: "Burrow", BPM: 100, TimeSignature: mustSig(t, "4/4")}
+	incoming := []metronome.Preset{
+		{Name: "burrow", BPM: 110, TimeSignature: mustSig(t, "3/4")},
+		{Name: "Toadstool Waltz", BPM: 95, TimeSignature: mustSig(t, "3/4")}, // Built in
+		{Name: "Meadow", BPM: 80, TimeSignature: mustSig(t, "6/8")},
+	}
+
+	tests := []struct {
+		dup   Duplicates
+		want  ImportResult
+		names []string
+	}{
+		{
+			dup:   SkipDuplicates,
+			want:  ImportResult{Added: []string{"Meadow"}, Skipped: []string{"burrow", "Toadstool Waltz"}},
+			names: []string{"Burrow", "Meadow"},
+		},
+		{
+			dup:   ReplaceDuplicates,
+			want:  ImportResult{Added: []string{"Meadow"}, Replaced: []string{"burrow"}, Skipped: []string{"Toadstool Waltz"}},
+			names: []string{"burrow", "Meadow"},
+		},
+		{
+			dup:   RenameDuplicates,
+			want:  ImportResult{Added: []string{"Meadow"}, Renamed: []string{"burrow (2)", "Toadstool Waltz (2)"}},
+			names: []string{"Burrow", "burrow (2)", "Toadstool Waltz (2)", "Meadow"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(duplicateModes[tt.dup], func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), fileName)
+			s := &Store{path: path}
+			if err := s.Add(existing); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.Import(incoming, tt.dup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result %+v, want %+v", got, tt.want)
+			}
+
+			// What was imported is what was saved
+			saved, err := LoadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, p := range saved.Presets() {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("saved %q, want %q", names, tt.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package presets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// fileName is the name of the preset file inside the config directory
+const fileName = "presets.json"
+
+// Store holds the user's own presets, saved to a file after every change.
+// The built-in gnome presets are not part of it.
+type Store struct {
+	path    string
+	presets []metronome.Preset
+}
+
+// Path returns the location of the preset file
+func Path() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Load reads the preset file, returning an empty store if it doesn't exist
+func Load() (*Store, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads a preset file from a specific location
+func LoadFile(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	presets, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.presets = presets
+	return s, nil
+}
+
+// Find looks up a built-in or saved preset by name, ignoring case. The
+// preset file is read afresh, so presets saved since startup are found.
+func Find(name string) (metronome.Preset, bool, error) {
+	s, err := Load()
+	if err != nil {
+		return metronome.Preset{}, false, err
+	}
+	all := append(append([]metronome.Preset(nil), metronome.CommonPresets...), s.presets...)
+	for _, p := range all {
+		if strings.EqualFold(p.Name, name) {
+			return p, true, nil
+		}
+	}
+	return metronome.Preset{}, false, nil
+}
+
+// Presets returns the stored presets in order
+func (s *Store) Presets() []metronome.Preset {
+	return append([]metronome.Preset(nil), s.presets...)
+}
+
+// Len returns the number of stored presets
+func (s *Store) Len() int {
+	return len(s.presets)
+}
+
+// Add appends a new preset
+func (s *Store) Add(p metronome.Preset) error {
+	return s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		if err := checkName(presets, p.Name, -1); err != nil {
+			return nil, err
+		}
+		return append(presets, p), nil
+	})
+}
+
+// Rename changes the name of the preset at index i
+func (s *Store) Rename(i int, name string) error {
+	return s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		if err := checkName(presets, name, i); err != nil {
+			return nil, err
+		}
+		presets[i].Name = name
+		return presets, nil
+	})
+}
+
+// Update replaces the settings of the preset at index i, keeping its name
+// and description
+func (s *Store) Update(i int, p metronome.Preset) error {
+	return s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		p.Name = presets[i].Name
+		p.Description = presets[i].Description
+		presets[i] = p
+		return presets, nil
+	})
+}
+
+// Delete removes the preset at index i
+func (s *Store) Delete(i int) error {
+	return s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		return append(presets[:i], presets[i+1:]...), nil
+	})
+}
+
+// Move moves the preset at index i to index j, shifting the ones between
+func (s *Store) Move(i, j int) error {
+	return s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		if j < 0 || j >= len(presets) {
+			return nil, fmt.Errorf("can't move a preset to position %d of %d", j+1, len(presets))
+		}
+		p := presets[i]
+		presets = append(presets[:i], presets[i+1:]...)
+		return append(presets[:j], append([]metronome.Preset{p}, presets[j:]...)...), nil
+	})
+}
+
+// Duplicates is what Import does with a preset whose name is already taken
+type Duplicates int
+
+const (
+	SkipDuplicates    Duplicates = iota // Keep the existing preset
+	ReplaceDuplicates                   // Overwrite the existing preset
+	RenameDuplicates                    // Import under a new name like "Waltz (2)"
+)
+
+// duplicateModes are the names accepted by ParseDuplicates
+var duplicateModes = []string{"skip", "replace", "rename"}
+
+// ParseDuplicates parses a duplicate handling mode such as "rename"
+func ParseDuplicates(s string) (Duplicates, error) {
+	for i, name := range duplicateModes {
+		if s == name {
+			return Duplicates(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown duplicate mode %q, expected skip, replace or rename", s)
+}
+
+// ImportResult lists what happened to each imported preset, by name
+type ImportResult struct {
+	Added    []string
+	Replaced []string
+	Renamed  []string // The new names
+	Skipped  []string
+}
+
+// Import adds presets from a shared library. A preset whose name matches one
+// already in the store, or a built-in one, is handled as dup says; built-in
+// presets are never replaced.
+func (s *Store) Import(incoming []metronome.Preset, dup Duplicates) (ImportResult, error) {
+	var result ImportResult
+	err := s.change(func(presets []metronome.Preset) ([]metronome.Preset, error) {
+		result = ImportResult{}
+		for _, p := range incoming {
+			if strings.TrimSpace(p.Name) == "" {
+				return nil, errors.New("a preset in the file has no name")
+			}
+
+			existing, builtin := find(presets, p.Name)
+			switch {
+			case existing < 0 && !builtin:
+				presets = append(presets, p)
+				result.Added = append(result.Added, p.Name)
+			case dup == ReplaceDuplicates && !builtin:
+				presets[existing] = p
+				result.Replaced = append(result.Replaced, p.Name)
+			case dup == RenameDuplicates:
+				p.Name = uniqueName(presets, p.Name)
+				presets = append(presets, p)
+				result.Renamed = append(result.Renamed, p.Name)
+			default:
+				result.Skipped = append(result.Skipped, p.Name)
+			}
+		}
+		return presets, nil
+	})
+	return result, err
+}
+
+// find returns the index of the preset with the given name, or -1, and
+// whether the name belongs to a built-in preset
+func find(presets []metronome.Preset, name string) (int, bool) {
+	for _, p := range metronome.CommonPresets {
+		if strings.EqualFold(p.Name, name) {
+			return -1, true
+		}
+	}
+	for i, p := range presets {
+		if strings.EqualFold(p.Name, name) {
+			return i, false
+		}
+	}
+	return -1, false
+}
+
+// uniqueName numbers a name until no preset has it
+func uniqueName(presets []metronome.Preset, name string) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if i, builtin := find(presets, candidate); i < 0 && !builtin {
+			return candidate
+		}
+	}
+}
+
+// change applies an edit to a copy of the presets and saves it, keeping the
+// store as it was if either step fails
+func (s *Store) change(edit func([]metronome.Preset) ([]metronome.Preset, error)) error {
+	presets, err := edit(s.Presets())
+	if err != nil {
+		return err
+	}
+	for _, p := range presets {
+		if err := metronome.ValidatePreset(p); err != nil {
+			return fmt.Errorf("preset %q: %w", p.Name, err)
+		}
+	}
+	if err := save(s.path, presets); err != nil {
+		return err
+	}
+	s.presets = presets
+	return nil
+}
+
+// checkName checks that a name is usable for the preset at index self
+func checkName(presets []metronome.Preset, name string, self int) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("a preset needs a name")
+	}
+	i, builtin := find(presets, name)
+	if builtin {
+		return fmt.Errorf("%q is the name of a built-in gnome preset", name)
+	}
+	if i >= 0 && i != self {
+		return fmt.Errorf("there is already a preset called %q", presets[i].Name)
+	}
+	return nil
+}
+
+// save writes the presets to path, replacing the file atomically
+func save(path string, presets []metronome.Preset) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, presets); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
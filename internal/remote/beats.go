@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/drj613/metrognome/internal/stream"
+)
+
+// heartbeat is how often an idle beat stream sends a comment, so proxies and
+// browsers keep the connection open while the metronome is stopped
+const heartbeat = 15 * time.Second
+
+// Clock is the server's time, for clients to work out how far their own
+// clock is from it
+type Clock struct {
+	Time     time.Time `json:"time"`
+	UnixNano int64     `json:"unix_nano"`
+	Client   string    `json:"client,omitempty"` // The client's "t" parameter, echoed back
+}
+
+// handleClock reports the server's time. A client notes its own time t0
+// before the request and t1 after it; the server's clock is then about
+// unix_nano - (t0 + t1) / 2 ahead of its own.
+func (s *Server) handleClock(w http.ResponseWriter, r *http.Request) {
+	if !onlyGET(w, r) {
+		return
+	}
+	now := time.Now()
+	allowBrowsers(w)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, Clock{Time: now.UTC(), UnixNano: now.UnixNano(), Client: r.URL.Query().Get("t")})
+}
+
+// BeatEvent is a beat sent over the beat stream. Beats are sent as soon as
+// they are scheduled, a look-ahead window before they sound, so clients can
+// play or light them at Time on the server's clock.
+type BeatEvent struct {
+	stream.Event
+	UnixNano int64 `json:"unix_nano"`
+}
+
+// handleBeats streams every beat as Server-Sent Events:
+//
+//	event: beat
+//	data: {"time":"...","bar":1,"beat":1,"subdivision":0,"accent":true,"bpm":120,"unix_nano":...}
+//
+// The stream opens with a state event, and runs until the client goes away.
+func (s *Server) handleBeats(w http.ResponseWriter, r *http.Request) {
+	if !onlyGET(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	beats, unsubscribe := s.metro.Subscribe()
+	defer unsubscribe()
+
+	allowBrowsers(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(w, "state", s.State()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": still here\n\n"); err != nil {
+				return
+			}
+
+		case b := <-beats:
+			// Skip beats left over from before a stop or change
+			if !s.metro.IsScheduled(b) {
+				continue
+			}
+			e := BeatEvent{Event: stream.EventFor(b), UnixNano: b.Time.UnixNano()}
+			if err := writeEvent(w, "beat", e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
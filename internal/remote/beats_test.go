@@ -0,0 +1,187 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// sseEvent is one event read off a Server-Sent Event stream
+type sseEvent struct {
+	name string
+	data string
+	at   time.Time // When it arrived
+}
+
+// readEvents reads events from a stream onto a channel until it ends
+func readEvents(t *testing.T, resp *http.Response) <-chan sseEvent {
+	t.Helper()
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		lines := bufio.NewScanner(resp.Body)
+		var e sseEvent
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				e.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && e.name != "":
+				e.at = time.Now()
+				events <- e
+				e = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+// next waits for the next event
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("the stream ended")
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+	}
+	return sseEvent{}
+}
+
+func TestBeats(t *testing.T) {
+	const bpm = 240
+	metro := metronome.New(bpm, metronome.CommonTimeSignatures[0])
+	defer metro.Stop()
+	srv := httptest.NewServer(New(metro))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/beats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if acao := resp.Header.Get("Access-Control-Allow-Origin"); acao != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q", acao)
+	}
+	events := readEvents(t, resp)
+
+	first := next(t, events)
+	if first.name != "state" {
+		t.Fatalf("first event is %q, want state", first.name)
+	}
+	var st State
+	if err := json.Unmarshal([]byte(first.data), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Playing || st.BPM != bpm || st.TimeSignature != "4/4" {
+		t.Errorf("state = %+v", st)
+	}
+
+	metro.Start()
+	var prev time.Time
+	for want := 1; want <= 5; want++ {
+		e := next(t, events)
+		if e.name != "beat" {
+			t.Fatalf("event %q, want beat", e.name)
+		}
+		var b BeatEvent
+		if err := json.Unmarshal([]byte(e.data), &b); err != nil {
+			t.Fatal(err)
+		}
+		wantBeat := (want-1)%4 + 1
+		wantBar := (want-1)/4 + 1
+		if b.Bar != wantBar || b.Beat != wantBeat || b.BPM != bpm || b.Accent != (wantBeat == 1) {
+			t.Errorf("beat %d = %+v, want bar %d beat %d", want, b, wantBar, wantBeat)
+		}
+		if b.UnixNano != b.Time.UnixNano() {
+			t.Errorf("unix_nano %d doesn't match time %s", b.UnixNano, b.Time)
+		}
+
+		// Beats are announced ahead of when they sound
+		if lead := b.Time.Sub(e.at); lead <= 0 || lead > 2*metronome.DefaultLookAhead {
+			t.Errorf("beat %d arrived %s before it sounds, want up to the look-ahead of %s", want, lead, metronome.DefaultLookAhead)
+		}
+		if !prev.IsZero() {
+			if gap := b.Time.Sub(prev); gap != time.Minute/bpm {
+				t.Errorf("beats %s apart, want %s", gap, time.Minute/bpm)
+			}
+		}
+		prev = b.Time
+	}
+}
+
+func TestBeatsOnlyGET(t *testing.T) {
+	srv := httptest.NewServer(New(metronome.New(120, metronome.CommonTimeSignatures[0])))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/beats", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /beats = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestClock(t *testing.T) {
+	srv := httptest.NewServer(New(metronome.New(120, metronome.CommonTimeSignatures[0])))
+	defer srv.Close()
+
+	before := time.Now()
+	resp, err := http.Get(srv.URL + "/clock?t=12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	after := time.Now()
+
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	var c Clock
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Client != "12345" {
+		t.Errorf("client = %q, want the t parameter echoed back", c.Client)
+	}
+	if c.Time.Location() != time.UTC {
+		t.Errorf("time %s is not in UTC", c.Time)
+	}
+	if c.UnixNano != c.Time.UnixNano() {
+		t.Errorf("unix_nano %d doesn't match time %s", c.UnixNano, c.Time)
+	}
+	// Same machine, same clock
+	if server := time.Unix(0, c.UnixNano); server.Before(before) || server.After(after) {
+		t.Errorf("server time %s is outside the request, %s to %s", server, before, after)
+	}
+
+	// Without t nothing is echoed
+	resp2, err := http.Get(srv.URL + "/clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	var raw map[string]any
+	if err := json.NewDecoder(resp2.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["client"]; ok {
+		t.Errorf("client echoed without a t parameter: %v", raw)
+	}
+}
@@ -0,0 +1,316 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/drj613/metrognome/internal/metrics"
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/presets"
+)
+
+// DefaultAddr is where the control API listens unless told otherwise. It
+// only accepts connections from the same machine.
+const DefaultAddr = "127.0.0.1:7777"
+
+// errExternal is returned when asked to start while an external clock
+// decides when to play
+var errExternal = errors.New("the metronome is following an external clock")
+
+// maxBody is the largest request body accepted
+const maxBody = 1 << 16
+
+// State is the metronome's state as reported by the API
+type State struct {
+	Playing       bool   `json:"playing"`
+	BPM           int    `json:"bpm"`
+	TimeSignature string `json:"time_signature"`
+	Subdivision   int    `json:"subdivision"`
+	Accents       []int  `json:"accents"`
+	Swing         int    `json:"swing"`
+	CountIn       int    `json:"count_in"`
+	Song          string `json:"song,omitempty"`
+}
+
+// Server is an HTTP control API for a metronome:
+//
+//	GET  /, /state                               current state
+//	GET  /beats                                  live beats as Server-Sent Events
+//	GET  /clock                                  server time, for clock sync
+//	GET  /metrics                                timing metrics for Prometheus
+//	POST /start, /stop, /toggle                  transport
+//	POST /bpm     {"bpm": 132} or {"delta": -5}  tempo
+//	POST /meter   {"time_signature": "7/8"}      meter
+//	POST /preset  {"name": "Toadstool Waltz"}    built-in or saved preset
+//
+// POST requests must be sent as Content-Type: application/json, even those
+// without a body. Every request but /metrics, which answers in the
+// Prometheus text format, answers with the state after it, or
+// {"error": "..."}.
+type Server struct {
+	metro *metronome.Metronome
+	mux   *http.ServeMux
+
+	// OnChange, if set, is called after a request changes the metronome
+	// with a short description of what happened, such as "bpm 132"
+	OnChange func(change string)
+}
+
+// New creates a control API for a metronome
+func New(metro *metronome.Metronome) *Server {
+	s := &Server{metro: metro, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.handleRoot)
+	s.mux.HandleFunc("/state", s.handleState)
+	s.mux.HandleFunc("/beats", s.handleBeats)
+	s.mux.HandleFunc("/clock", s.handleClock)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/start", s.post(s.start))
+	s.mux.HandleFunc("/stop", s.post(s.stop))
+	s.mux.HandleFunc("/toggle", s.post(s.toggle))
+	s.mux.HandleFunc("/bpm", s.post(s.setBPM))
+	s.mux.HandleFunc("/meter", s.post(s.setMeter))
+	s.mux.HandleFunc("/preset", s.post(s.applyPreset))
+	return s
+}
+
+// ServeHTTP routes a request to its endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// State returns the metronome's current state
+func (s *Server) State() State {
+	st := State{
+		Playing:       s.metro.IsPlaying(),
+		BPM:           s.metro.BPM(),
+		TimeSignature: s.metro.TimeSignature().String(),
+		Subdivision:   s.metro.Subdivision(),
+		Accents:       s.metro.Accents(),
+		Swing:         s.metro.Swing(),
+		CountIn:       s.metro.CountIn(),
+	}
+	if song := s.metro.Song(); song != nil {
+		st.Song = song.Name
+	}
+	return st
+}
+
+// handleRoot reports the state at / and answers anything unknown in JSON
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no endpoint %s", r.URL.Path))
+		return
+	}
+	s.handleState(w, r)
+}
+
+// handleState reports the current state
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if !onlyGET(w, r) {
+		return
+	}
+	allowBrowsers(w)
+	writeJSON(w, http.StatusOK, s.State())
+}
+
+// handleMetrics reports the engine's timing metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !onlyGET(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	metrics.WriteText(w)
+}
+
+// onlyGET rejects anything but a GET or HEAD request, reporting whether the
+// request may go ahead
+func onlyGET(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s %s is not supported, use GET", r.Method, r.URL.Path))
+	return false
+}
+
+// action changes the metronome from a request, returning a description of
+// the change
+type action func(r *http.Request) (string, error)
+
+// requestError is a problem with what the client sent
+type requestError struct {
+	status int
+	err    error
+}
+
+// Error returns the underlying problem
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// badRequest wraps an error as the client's fault
+func badRequest(err error) error {
+	return &requestError{status: http.StatusBadRequest, err: err}
+}
+
+// post wraps an action as a POST endpoint that answers with the new state
+func (s *Server) post(act action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s %s is not supported, use POST", r.Method, r.URL.Path))
+			return
+		}
+		// Browsers send form and text/plain posts from any page without
+		// asking first, so only JSON is taken, even with no body
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("%s needs Content-Type: application/json", r.URL.Path))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+		change, err := act(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				status = reqErr.status
+			}
+			writeError(w, status, err)
+			return
+		}
+		if s.OnChange != nil {
+			s.OnChange(change)
+		}
+		writeJSON(w, http.StatusOK, s.State())
+	}
+}
+
+// start starts playback
+func (s *Server) start(r *http.Request) (string, error) {
+	if s.metro.External() {
+		return "", &requestError{status: http.StatusConflict, err: errExternal}
+	}
+	s.metro.Start()
+	return "start", nil
+}
+
+// stop stops playback
+func (s *Server) stop(r *http.Request) (string, error) {
+	s.metro.Stop()
+	return "stop", nil
+}
+
+// toggle starts or stops playback, for a single foot switch
+func (s *Server) toggle(r *http.Request) (string, error) {
+	if s.metro.IsPlaying() {
+		return s.stop(r)
+	}
+	return s.start(r)
+}
+
+// setBPM sets the tempo, or nudges it by a delta
+func (s *Server) setBPM(r *http.Request) (string, error) {
+	var body struct {
+		BPM   *int `json:"bpm"`
+		Delta *int `json:"delta"`
+	}
+	if err := decode(r, &body); err != nil {
+		return "", err
+	}
+
+	var bpm int
+	switch {
+	case body.BPM != nil && body.Delta != nil:
+		return "", badRequest(errors.New(`give either "bpm" or "delta", not both`))
+	case body.BPM != nil:
+		bpm = *body.BPM
+	case body.Delta != nil:
+		bpm = s.metro.BPM() + *body.Delta
+	default:
+		return "", badRequest(errors.New(`missing "bpm" or "delta"`))
+	}
+	if err := s.metro.SetBPM(bpm); err != nil {
+		return "", badRequest(err)
+	}
+	return fmt.Sprintf("bpm %d", bpm), nil
+}
+
+// setMeter sets the time signature
+func (s *Server) setMeter(r *http.Request) (string, error) {
+	var body struct {
+		TimeSignature string `json:"time_signature"`
+	}
+	if err := decode(r, &body); err != nil {
+		return "", err
+	}
+	if body.TimeSignature == "" {
+		return "", badRequest(errors.New(`missing "time_signature"`))
+	}
+
+	ts, err := metronome.ParseTimeSignature(body.TimeSignature)
+	if err != nil {
+		return "", badRequest(err)
+	}
+	if err := s.metro.SetTimeSignature(ts); err != nil {
+		return "", badRequest(err)
+	}
+	return "meter " + ts.String(), nil
+}
+
+// applyPreset switches to a built-in or saved preset by name
+func (s *Server) applyPreset(r *http.Request) (string, error) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := decode(r, &body); err != nil {
+		return "", err
+	}
+	if body.Name == "" {
+		return "", badRequest(errors.New(`missing "name"`))
+	}
+
+	p, ok, err := presets.Find(body.Name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", &requestError{status: http.StatusNotFound, err: fmt.Errorf("no preset called %q", body.Name)}
+	}
+	if err := s.metro.Apply(p); err != nil {
+		return "", badRequest(err)
+	}
+	return "preset " + p.Name, nil
+}
+
+// decode reads a JSON request body, rejecting unknown fields
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest(fmt.Errorf("invalid JSON body: %w", err))
+	}
+	return nil
+}
+
+// allowBrowsers lets pages served from anywhere read a response, for
+// browser-based displays. Only endpoints that change nothing allow it.
+func allowBrowsers(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+}
+
+// writeJSON sends a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends an error as {"error": "..."}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,91 @@
+package setlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// A setlist file lists the songs of a gig in the order they are played.
+// Each song is written like a preset, with an optional bar count and notes:
+//
+//	{
+//	  "name": "Friday at the Mushroom",
+//	  "auto_advance": true,
+//	  "songs": [
+//	    {"name": "Opener", "bpm": 132, "time_signature": "4/4", "bars": 64},
+//	    {"name": "Slow One", "bpm": 72, "time_signature": "6/8", "notes": "Capo 2"}
+//	  ]
+//	}
+
+// Song is one entry of a setlist
+type Song struct {
+	metronome.Preset
+	Bars  int    `json:"bars,omitempty"`  // Length of the song, 0 if open-ended
+	Notes string `json:"notes,omitempty"` // Reminders shown while it plays
+}
+
+// Setlist is an ordered list of songs
+type Setlist struct {
+	Name        string `json:"name,omitempty"`
+	AutoAdvance bool   `json:"auto_advance,omitempty"` // Move on when a song's bars are played
+	Songs       []Song `json:"songs"`
+}
+
+// Load reads a setlist file
+func Load(path string) (*Setlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Decode reads a setlist and checks that every song can be played
+func Decode(r io.Reader) (*Setlist, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var s Setlist
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that the setlist has songs and that each can be played
+func (s *Setlist) Validate() error {
+	if len(s.Songs) == 0 {
+		return errors.New("the setlist has no songs")
+	}
+	for i, song := range s.Songs {
+		if err := metronome.ValidatePreset(song.Preset); err != nil {
+			return fmt.Errorf("song %d (%q): %w", i+1, song.Name, err)
+		}
+		if song.Bars < 0 {
+			return fmt.Errorf("song %d (%q): bars can't be negative, got %d", i+1, song.Name, song.Bars)
+		}
+	}
+	return nil
+}
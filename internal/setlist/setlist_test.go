@@ -0,0 +1,64 @@
+package setlist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	s, err := Decode(strings.NewReader(`{
+	  "name": "Friday at the Mushroom",
+	  "auto_advance": true,
+	  "songs": [
+	    {"name": "Opener", "bpm": 132, "time_signature": "4/4", "bars": 64},
+	    {"name": "Slow One", "bpm": 72, "time_signature": "6/8", "notes": "Capo 2"}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "Friday at the Mushroom" || !s.AutoAdvance || len(s.Songs) != 2 {
+		t.Fatalf("decoded %+v", s)
+	}
+	if song := s.Songs[0]; song.Name != "Opener" || song.BPM != 132 || song.Bars != 64 {
+		t.Errorf("first song %+v", song)
+	}
+	if song := s.Songs[1]; song.TimeSignature.String() != "6/8" || song.Bars != 0 || song.Notes != "Capo 2" {
+		t.Errorf("second song %+v", song)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", " \n", "file is empty"},
+		{"not JSON", "songs:", "invalid character"},
+		{"unknown field", `{"songs": [], "encore": true}`, `json: unknown field "encore"`},
+		{"no songs", `{"name": "Quiet Night", "songs": []}`, "the setlist has no songs"},
+		{"bad tempo", `{"songs": [{"name": "Opener", "bpm": 132, "time_signature": "4/4"}, {"name": "Blur", "bpm": 900, "time_signature": "4/4"}]}`, `song 2 ("Blur"): `},
+		{"negative bars", `{"songs": [{"name": "Opener", "bpm": 132, "time_signature": "4/4", "bars": -8}]}`, `song 1 ("Opener"): bars can't be negative, got -8`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error starting %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadNamesTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gig.json")
+	if err := os.WriteFile(path, []byte(`{"songs": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || err.Error() != path+": the setlist has no songs" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package songs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// The song file holds the songs built in the song editor:
+//
+//	{
+//	  "format": "metrognome-songs",
+//	  "version": 1,
+//	  "songs": [
+//	    {"name": "Mushroom Blues", "sections": [
+//	      {"name": "Intro", "bars": 4, "bpm": 90, "time_signature": "4/4"},
+//	      {"name": "Bridge", "bars": 8, "bpm": 90, "time_signature": "6/8", "end_bpm": 70}
+//	    ]}
+//	  ]
+//	}
+
+// Format is the value of the "format" field of a song file
+const Format = "metrognome-songs"
+
+// Version is the schema version written to new song files
+const Version = 1
+
+// fileName is the name of the song file inside the config directory
+const fileName = "songs.json"
+
+// document is the layout of a song file
+type document struct {
+	Format  string           `json:"format"`
+	Version int              `json:"version"`
+	Songs   []metronome.Song `json:"songs"`
+}
+
+// Store holds the user's songs, saved to a file after every change
+type Store struct {
+	path  string
+	songs []metronome.Song
+}
+
+// Path returns the location of the song file
+func Path() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Load reads the song file, returning an empty store if it doesn't exist
+func Load() (*Store, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads a song file from a specific location
+func LoadFile(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	songs, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.songs = songs
+	return s, nil
+}
+
+// Decode reads a song file and checks that every song can be played
+func Decode(r io.Reader) ([]metronome.Song, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	var doc document
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Format != Format {
+		return nil, fmt.Errorf("not a song file: format is %q, expected %q", doc.Format, Format)
+	}
+	if doc.Version > Version {
+		return nil, fmt.Errorf("song file version %d is newer than this metrognome understands (%d); please upgrade", doc.Version, Version)
+	}
+	for i, song := range doc.Songs {
+		if err := metronome.ValidateSong(song); err != nil {
+			return nil, fmt.Errorf("song %d (%q): %w", i+1, song.Name, err)
+		}
+	}
+	return doc.Songs, nil
+}
+
+// Songs returns the stored songs in order
+func (s *Store) Songs() []metronome.Song {
+	songs := make([]metronome.Song, len(s.songs))
+	for i, song := range s.songs {
+		songs[i] = cloneSong(song)
+	}
+	return songs
+}
+
+// Len returns the number of stored songs
+func (s *Store) Len() int {
+	return len(s.songs)
+}
+
+// Add appends a new song
+func (s *Store) Add(song metronome.Song) error {
+	return s.change(func(songs []metronome.Song) ([]metronome.Song, error) {
+		if err := checkName(songs, song.Name, -1); err != nil {
+			return nil, err
+		}
+		return append(songs, cloneSong(song)), nil
+	})
+}
+
+// Update replaces the song at index i
+func (s *Store) Update(i int, song metronome.Song) error {
+	return s.change(func(songs []metronome.Song) ([]metronome.Song, error) {
+		if err := checkName(songs, song.Name, i); err != nil {
+			return nil, err
+		}
+		songs[i] = cloneSong(song)
+		return songs, nil
+	})
+}
+
+// Delete removes the song at index i
+func (s *Store) Delete(i int) error {
+	return s.change(func(songs []metronome.Song) ([]metronome.Song, error) {
+		return append(songs[:i], songs[i+1:]...), nil
+	})
+}
+
+// change applies an edit to a copy of the songs and saves it, keeping the
+// store as it was if either step fails
+func (s *Store) change(edit func([]metronome.Song) ([]metronome.Song, error)) error {
+	songs, err := edit(s.Songs())
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		if err := metronome.ValidateSong(song); err != nil {
+			return fmt.Errorf("song %q: %w", song.Name, err)
+		}
+	}
+	if err := save(s.path, songs); err != nil {
+		return err
+	}
+	s.songs = songs
+	return nil
+}
+
+// checkName checks that a name is usable for the song at index self
+func checkName(songs []metronome.Song, name string, self int) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("a song needs a name")
+	}
+	for i, song := range songs {
+		if i != self && strings.EqualFold(song.Name, name) {
+			return fmt.Errorf("there is already a song called %q", song.Name)
+		}
+	}
+	return nil
+}
+
+// cloneSong copies a song so its sections can be edited independently
+func cloneSong(song metronome.Song) metronome.Song {
+	song.Sections = append([]metronome.Section(nil), song.Sections...)
+	return song
+}
+
+// save writes the songs to path, replacing the file atomically
+func save(path string, songs []metronome.Song) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if songs == nil {
+		songs = []metronome.Song{}
+	}
+
+	data, err := json.MarshalIndent(document{Format: Format, Version: Version, Songs: songs}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,129 @@
+package songs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// song builds a one-section song
+func song(name string, bpm int) metronome.Song {
+	return metronome.Song{Name: name, Sections: []metronome.Section{
+		{Name: "Verse", Bars: 8, BPM: bpm, TimeSignature: metronome.CommonTimeSignatures[0]},
+	}}
+}
+
+// names lists the songs in a store
+func names(s *Store) []string {
+	var out []string
+	for _, song := range s.Songs() {
+		out = append(out, song.Name)
+	}
+	return out
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrognome", fileName)
+	s, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Fatalf("a missing file loaded %d songs", s.Len())
+	}
+
+	for _, song := range []metronome.Song{song("Mushroom Blues", 90), song("Toadstool Reel", 140), song("Moss", 60)} {
+		if err := s.Add(song); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(song("mushroom blues", 100)); err == nil || err.Error() != `there is already a song called "Mushroom Blues"` {
+		t.Errorf("adding a song by the same name gave %v", err)
+	}
+	if err := s.Add(song("  ", 100)); err == nil || err.Error() != "a song needs a name" {
+		t.Errorf("adding a nameless song gave %v", err)
+	}
+
+	// Renaming a song to its own name in other case is fine
+	renamed := song("MOSS", 66)
+	renamed.Sections = append(renamed.Sections, metronome.Section{Name: "Outro", Bars: 2, BPM: 66, EndBPM: 50, TimeSignature: metronome.CommonTimeSignatures[0]})
+	if err := s.Update(2, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Songs handed out can be edited without touching the store
+	s.Songs()[0].Sections[0].BPM = 300
+
+	reloaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded.Songs(), s.Songs()) {
+		t.Errorf("reloaded %+v, want %+v", reloaded.Songs(), s.Songs())
+	}
+	if want := []string{"Mushroom Blues", "MOSS"}; !reflect.DeepEqual(names(reloaded), want) {
+		t.Errorf("stored %q, want %q", names(reloaded), want)
+	}
+	if got := reloaded.Songs()[0].Sections[0].BPM; got != 90 {
+		t.Errorf("first song at %d BPM, want 90", got)
+	}
+}
+
+func TestStoreKeepsSongsWhenSavingFails(t *testing.T) {
+	dir := t.TempDir()
+	s, err := LoadFile(filepath.Join(dir, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(song("Mushroom Blues", 90)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A song that can't be played is never stored
+	bad := song("Runaway", 90)
+	bad.Sections[0].Bars = 0
+	if err := s.Add(bad); err == nil || !strings.HasPrefix(err.Error(), `song "Runaway": `) {
+		t.Errorf("adding an unplayable song gave %v", err)
+	}
+
+	// Nor is one that can't be saved
+	s.path = filepath.Join(dir, "missing", "dir", "in", "a", "file")
+	if err := os.WriteFile(filepath.Join(dir, "missing"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(song("Moss", 60)); err == nil {
+		t.Error("saving under a file succeeded")
+	}
+	if want := []string{"Mushroom Blues"}; !reflect.DeepEqual(names(s), want) {
+		t.Errorf("store holds %q after failed changes, want %q", names(s), want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "file is empty"},
+		{"unknown field", `{"format": "metrognome-songs", "version": 1, "songs": [], "albums": []}`, `json: unknown field "albums"`},
+		{"other format", `{"format": "metrognome-presets", "version": 1, "songs": []}`, `not a song file: format is "metrognome-presets", expected "metrognome-songs"`},
+		{"newer version", `{"format": "metrognome-songs", "version": 2, "songs": []}`, "song file version 2 is newer than this metrognome understands (1); please upgrade"},
+		{"unplayable song", `{"format": "metrognome-songs", "version": 1, "songs": [{"name": "Hollow", "sections": []}]}`, `song 1 ("Hollow"): a song needs at least one section`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.data))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
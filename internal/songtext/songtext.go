@@ -0,0 +1,460 @@
+// Package songtext reads songs written as text, one section per line or
+// separated by semicolons:
+//
+//	intro: 4 bars 4/4 @90
+//	verse: 16 bars 7/8(2+2+3) @120 swing 60%
+//	outro: 4 bars rit. to 80  # slowing down to the end
+//
+// A section starts with its name and a colon, followed by any of
+//
+//	N bars                 how long the section lasts (required)
+//	B/V                    the meter, optionally grouped like 7/8(2+2+3)
+//	@BPM                   the tempo
+//	rit. to BPM            a ritardando to BPM over the section, also
+//	accel. to BPM          ritardando, rall., accelerando
+//	swing P%               swing the eighths (or sixteenths), or straight to undo it
+//	eighths, triplets      clicks per beat, or quarters for one
+//	sixteenths
+//
+// Meter, tempo, grouping, swing and clicks carry over from the section
+// before, and a tempo ramp leaves the next section at the tempo it reached.
+// The first section needs a tempo; its meter defaults to 4/4.
+package songtext
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Error is a problem at a line and column of the text
+type Error struct {
+	Line, Column int
+	Msg          string
+}
+
+// Error formats the problem as line:column: message
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Load reads a song file, naming the song after the file
+func Load(path string) (metronome.Song, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metronome.Song{}, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	song, err := Parse(name, string(data))
+	if err != nil {
+		return metronome.Song{}, fmt.Errorf("%s:%w", path, err)
+	}
+	return song, nil
+}
+
+// Parse reads the sections of a song from text. Errors are *Error values
+// pointing at the problem.
+func Parse(name, text string) (metronome.Song, error) {
+	p := &parser{prev: metronome.Section{TimeSignature: metronome.CommonTimeSignatures[0]}}
+	song := metronome.Song{Name: name}
+	for _, stmt := range split(text) {
+		sec, err := p.section(stmt)
+		if err != nil {
+			return metronome.Song{}, err
+		}
+		song.Sections = append(song.Sections, sec)
+	}
+	if len(song.Sections) == 0 {
+		return metronome.Song{}, &Error{Line: 1, Column: 1, Msg: `no sections found; start one like "intro: 4 bars 4/4 @90"`}
+	}
+	return song, nil
+}
+
+// pos is a place in the text, counting from 1
+type pos struct {
+	line, col int
+}
+
+// errorf reports a problem at p
+func (p pos) errorf(format string, args ...any) error {
+	return &Error{Line: p.line, Column: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// statement is the text of one section, with the position of each rune
+type statement struct {
+	text []rune
+	at   []pos
+	end  pos // Just past the last character that isn't a space
+}
+
+// split breaks the text into sections at semicolons and line ends, dropping
+// comments and blank sections
+func split(text string) []statement {
+	var stmts []statement
+	var cur statement
+	here := pos{1, 1}
+	comment := false
+
+	flush := func() {
+		for i := len(cur.text) - 1; i >= 0; i-- {
+			if !unicode.IsSpace(cur.text[i]) {
+				cur.end = pos{cur.at[i].line, cur.at[i].col + 1}
+				stmts = append(stmts, cur)
+				break
+			}
+		}
+		cur = statement{}
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			flush()
+			comment = false
+			here = pos{here.line + 1, 1}
+			continue
+		case comment:
+		case r == '#':
+			comment = true
+		case r == ';':
+			flush()
+		default:
+			cur.text = append(cur.text, r)
+			cur.at = append(cur.at, here)
+		}
+		here.col++
+	}
+	flush()
+	return stmts
+}
+
+// token kinds
+const (
+	number = iota
+	word
+	symbol
+)
+
+// token is a number, a word such as "bars" or "rit.", or a single symbol
+type token struct {
+	kind int
+	text string
+	n    int
+	at   pos
+}
+
+// String quotes the token for error messages
+func (t token) String() string {
+	return strconv.Quote(t.text)
+}
+
+// tokenize splits the body of a section into tokens
+func tokenize(text []rune, at []pos) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(text); {
+		r := text[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case isDigit(r):
+			for i < len(text) && isDigit(text[i]) {
+				i++
+			}
+			n, err := strconv.Atoi(string(text[start:i]))
+			if err != nil {
+				return nil, at[start].errorf("%s is too big", string(text[start:i]))
+			}
+			toks = append(toks, token{kind: number, text: string(text[start:i]), n: n, at: at[start]})
+		case unicode.IsLetter(r):
+			for i < len(text) && (unicode.IsLetter(text[i]) || text[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{kind: word, text: strings.ToLower(string(text[start:i])), at: at[start]})
+		case strings.ContainsRune("/()+@%", r):
+			i++
+			toks = append(toks, token{kind: symbol, text: string(r), at: at[start]})
+		default:
+			return nil, at[start].errorf("unexpected %q", r)
+		}
+	}
+	return toks, nil
+}
+
+// isDigit reports whether r is an ASCII digit; other scripts' digits aren't
+// numbers here
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// parser turns statements into sections, remembering the previous section
+// for the settings that carry over
+type parser struct {
+	prev metronome.Section
+	toks []token
+	i    int
+	end  pos
+}
+
+// peek returns the next token, if any
+func (p *parser) peek() (token, bool) {
+	if p.i >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.i], true
+}
+
+// here returns the position of the next token, or the end of the section
+func (p *parser) here() pos {
+	if t, ok := p.peek(); ok {
+		return t.at
+	}
+	return p.end
+}
+
+// accept consumes the next token if it is the given word or symbol
+func (p *parser) accept(text string) bool {
+	if t, ok := p.peek(); ok && t.kind != number && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// expect consumes the given word or symbol, or reports what was found
+func (p *parser) expect(text, what string) error {
+	if p.accept(text) {
+		return nil
+	}
+	return p.unexpected(what)
+}
+
+// number consumes a number, or reports what was found
+func (p *parser) number(what string) (int, error) {
+	if t, ok := p.peek(); ok && t.kind == number {
+		p.i++
+		return t.n, nil
+	}
+	return 0, p.unexpected(what)
+}
+
+// unexpected reports that the next token isn't what was wanted
+func (p *parser) unexpected(want string) error {
+	t, ok := p.peek()
+	if !ok {
+		return p.end.errorf("expected %s at the end of the section", want)
+	}
+	return t.at.errorf("expected %s, found %s", want, t)
+}
+
+// section parses one statement
+func (p *parser) section(stmt statement) (metronome.Section, error) {
+	colon := -1
+	for i, r := range stmt.text {
+		if r == ':' {
+			colon = i
+			break
+		}
+	}
+	start := 0
+	for start < len(stmt.text) && unicode.IsSpace(stmt.text[start]) {
+		start++
+	}
+	if colon < 0 {
+		return metronome.Section{}, stmt.at[start].errorf(`expected a section name followed by ":", like "verse: 16 bars"`)
+	}
+	name := strings.TrimSpace(string(stmt.text[:colon]))
+	if name == "" {
+		return metronome.Section{}, stmt.at[colon].errorf("missing section name before %q", ':')
+	}
+
+	toks, err := tokenize(stmt.text[colon+1:], stmt.at[colon+1:])
+	if err != nil {
+		return metronome.Section{}, err
+	}
+	p.toks, p.i, p.end = toks, 0, stmt.end
+
+	sec := p.prev
+	sec.Name = name
+	sec.Bars = 0
+	if sec.EndBPM != 0 {
+		// Carry on at the tempo the last ramp reached
+		sec.BPM = sec.EndBPM
+		sec.EndBPM = 0
+	}
+	var tempoAt, barsAt, rampAt, swingAt *pos
+	var clicks *token // The clicks per beat given in this section
+	slower := false
+
+	for {
+		t, ok := p.peek()
+		if !ok {
+			break
+		}
+		switch {
+		case t.kind == number:
+			p.i++
+			if p.accept("/") {
+				value, err := p.number("the beat value of the meter, like the 8 in 7/8")
+				if err != nil {
+					return metronome.Section{}, err
+				}
+				if err := p.meter(&sec, t, value); err != nil {
+					return metronome.Section{}, err
+				}
+				continue
+			}
+			if !p.accept("bars") && !p.accept("bar") {
+				return metronome.Section{}, p.unexpected(fmt.Sprintf(`"bars" after %d, or a meter like %d/4`, t.n, t.n))
+			}
+			if barsAt != nil {
+				return metronome.Section{}, t.at.errorf("the length is already given at %d:%d", barsAt.line, barsAt.col)
+			}
+			sec.Bars = t.n
+			barsAt = &t.at
+
+		case t.text == "@":
+			p.i++
+			bpm, err := p.number("a tempo after @, like @120")
+			if err != nil {
+				return metronome.Section{}, err
+			}
+			p.accept("bpm")
+			sec.BPM = bpm
+			tempoAt = &t.at
+
+		case t.text == "rit." || t.text == "ritardando" || t.text == "rall." || t.text == "rallentando" ||
+			t.text == "accel." || t.text == "accelerando":
+			p.i++
+			if err := p.expect("to", fmt.Sprintf(`"to" after %s, like "%s to 80"`, t, t.text)); err != nil {
+				return metronome.Section{}, err
+			}
+			p.accept("@")
+			at := p.here()
+			end, err := p.number("the tempo to ramp to")
+			if err != nil {
+				return metronome.Section{}, err
+			}
+			p.accept("bpm")
+			sec.EndBPM = end
+			slower = !strings.HasPrefix(t.text, "accel")
+			rampAt = &at
+
+		case t.text == "swing":
+			p.i++
+			percent, err := p.number("a swing percentage, like swing 60%")
+			if err != nil {
+				return metronome.Section{}, err
+			}
+			p.accept("%")
+			if err := metronome.ValidateSwing(percent); err != nil {
+				return metronome.Section{}, t.at.errorf("%v", err)
+			}
+			sec.Swing = percent
+			swingAt = &t.at
+
+		case t.text == "straight":
+			p.i++
+			sec.Swing = 0
+
+		case t.text == "quarters" || t.text == "eighths" || t.text == "triplets" || t.text == "sixteenths":
+			p.i++
+			sec.Subdivision = map[string]int{"quarters": 1, "eighths": 2, "triplets": 3, "sixteenths": 4}[t.text]
+			clicks = &t
+
+		default:
+			return metronome.Section{}, t.at.errorf("unexpected %s; expected bars, a meter, @tempo, rit. or accel., swing or eighths", t)
+		}
+	}
+
+	if sec.Swing > 0 && (sec.Subdivision == 0 || sec.Subdivision%2 != 0) {
+		// Swing needs pairs of clicks to push apart
+		switch {
+		case clicks != nil && swingAt != nil:
+			return metronome.Section{}, clicks.at.errorf("swing needs eighths or sixteenths, but %s are given with swing at %d:%d",
+				clicks.text, swingAt.line, swingAt.col)
+		case clicks != nil:
+			return metronome.Section{}, clicks.at.errorf("swing carried over from the section before needs eighths or sixteenths, but %s are given; add \"straight\" to play them straight",
+				clicks.text)
+		default:
+			sec.Subdivision = 2
+		}
+	}
+
+	if sec.Bars == 0 {
+		return metronome.Section{}, p.end.errorf("section %q needs a length, like \"%s: 8 bars\"", name, name)
+	}
+	if sec.BPM == 0 {
+		return metronome.Section{}, p.end.errorf("section %q needs a tempo, like @120", name)
+	}
+	if err := metronome.ValidateBPM(sec.BPM); err != nil {
+		at := p.end
+		if tempoAt != nil {
+			at = *tempoAt
+		}
+		return metronome.Section{}, at.errorf("%v", err)
+	}
+	if rampAt != nil {
+		if err := metronome.ValidateBPM(sec.EndBPM); err != nil {
+			return metronome.Section{}, rampAt.errorf("%v", err)
+		}
+		if slower && sec.EndBPM >= sec.BPM {
+			return metronome.Section{}, rampAt.errorf("a ritardando slows down, but %d is not below %d BPM", sec.EndBPM, sec.BPM)
+		}
+		if !slower && sec.EndBPM <= sec.BPM {
+			return metronome.Section{}, rampAt.errorf("an accelerando speeds up, but %d is not above %d BPM", sec.EndBPM, sec.BPM)
+		}
+	}
+	if err := metronome.ValidateSection(sec); err != nil {
+		return metronome.Section{}, stmt.at[start].errorf("%v", err)
+	}
+
+	p.prev = sec
+	return sec, nil
+}
+
+// meter applies a meter such as 7/8, and the grouping after it if there is
+// one, which accents the first beat of each group
+func (p *parser) meter(sec *metronome.Section, beats token, value int) error {
+	ts, err := metronome.ParseTimeSignature(fmt.Sprintf("%d/%d", beats.n, value))
+	if err != nil {
+		return beats.at.errorf("%v", err)
+	}
+	sec.TimeSignature = ts
+	sec.Accents = nil
+
+	open, ok := p.peek()
+	if !ok || !p.accept("(") {
+		return nil
+	}
+	accents := []int{}
+	next := 1
+	for {
+		group, err := p.number("a group size, like the 2 in (2+2+3)")
+		if err != nil {
+			return err
+		}
+		if group < 1 {
+			return p.toks[p.i-1].at.errorf("groups must have at least one beat")
+		}
+		accents = append(accents, next)
+		next += group
+		if p.accept(")") {
+			break
+		}
+		if err := p.expect("+", `"+" or ")" in the grouping`); err != nil {
+			return err
+		}
+	}
+	if next-1 != beats.n {
+		return open.at.errorf("the groups add up to %d beats, but %s has %d", next-1, ts, beats.n)
+	}
+	sec.Accents = accents
+	return nil
+}
@@ -0,0 +1,146 @@
+package songtext
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// sig parses a time signature, panicking on a typo in the test table
+func sig(s string) metronome.TimeSignature {
+	ts, err := metronome.ParseTimeSignature(s)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []metronome.Section
+	}{
+		{
+			name: "defaults to 4/4",
+			text: "intro: 4 bars @90",
+			want: []metronome.Section{
+				{Name: "intro", Bars: 4, BPM: 90, TimeSignature: sig("4/4")},
+			},
+		},
+		{
+			name: "meter and tempo carry over",
+			text: "intro: 4 bars 3/4 @90\nverse: 8 bars\nchorus: 8 bars 6/8 @100",
+			want: []metronome.Section{
+				{Name: "intro", Bars: 4, BPM: 90, TimeSignature: sig("3/4")},
+				{Name: "verse", Bars: 8, BPM: 90, TimeSignature: sig("3/4")},
+				{Name: "chorus", Bars: 8, BPM: 100, TimeSignature: sig("6/8")},
+			},
+		},
+		{
+			name: "a ramp leaves the next section at its end tempo",
+			text: "verse: 8 bars @120 rit. to 100; bridge: 4 bars; outro: 2 bars accel. to 110 bpm",
+			want: []metronome.Section{
+				{Name: "verse", Bars: 8, BPM: 120, EndBPM: 100, TimeSignature: sig("4/4")},
+				{Name: "bridge", Bars: 4, BPM: 100, TimeSignature: sig("4/4")},
+				{Name: "outro", Bars: 2, BPM: 100, EndBPM: 110, TimeSignature: sig("4/4")},
+			},
+		},
+		{
+			name: "grouping accents the start of each group and carries over",
+			text: "verse: 16 bars 7/8(2+2+3) @120\nfill: 1 bar\nchorus: 8 bars 4/4",
+			want: []metronome.Section{
+				{Name: "verse", Bars: 16, BPM: 120, TimeSignature: sig("7/8"), Accents: []int{1, 3, 5}},
+				{Name: "fill", Bars: 1, BPM: 120, TimeSignature: sig("7/8"), Accents: []int{1, 3, 5}},
+				{Name: "chorus", Bars: 8, BPM: 120, TimeSignature: sig("4/4")},
+			},
+		},
+		{
+			name: "swing brings in eighths, and straight undoes it",
+			text: "a: 2 bars @100 swing 60%\nb: 2 bars straight",
+			want: []metronome.Section{
+				{Name: "a", Bars: 2, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 2, Swing: 60},
+				{Name: "b", Bars: 2, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 2},
+			},
+		},
+		{
+			name: "straight lets a later section change the clicks",
+			text: "a: 2 bars @100 swing 60%\nb: 2 bars triplets straight\nc: 1 bar",
+			want: []metronome.Section{
+				{Name: "a", Bars: 2, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 2, Swing: 60},
+				{Name: "b", Bars: 2, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 3},
+				{Name: "c", Bars: 1, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 3},
+			},
+		},
+		{
+			name: "swing with sixteenths keeps them",
+			text: "a: 2 bars @100 sixteenths swing 55",
+			want: []metronome.Section{
+				{Name: "a", Bars: 2, BPM: 100, TimeSignature: sig("4/4"), Subdivision: 4, Swing: 55},
+			},
+		},
+		{
+			name: "comments, blank lines and case",
+			text: "# a song\n\n  Intro: 1 BAR @60  # just one\n",
+			want: []metronome.Section{
+				{Name: "Intro", Bars: 1, BPM: 60, TimeSignature: sig("4/4")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			song, err := Parse("song", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(song.Sections, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", song.Sections, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "# nothing here", `1:1: no sections found; start one like "intro: 4 bars 4/4 @90"`},
+		{"no colon", "intro 4 bars", `1:1: expected a section name followed by ":", like "verse: 16 bars"`},
+		{"no name", "  : 4 bars @90", `1:3: missing section name before ':'`},
+		{"no length", "intro: @90", `1:11: section "intro" needs a length, like "intro: 8 bars"`},
+		{"no tempo", "intro: 4 bars", `1:14: section "intro" needs a tempo, like @120`},
+		{"length twice", "intro: 4 bars 2 bars @90", `1:15: the length is already given at 1:8`},
+		{"number without bars", "intro: 4 @90", `1:10: expected "bars" after 4, or a meter like 4/4, found "@"`},
+		{"bad meter", "intro: 4 bars 4/3 @90", `1:15: beat value must be 1, 2, 4, 8, 16 or 32, got 3`},
+		{"groups don't add up", "verse: 4 bars 7/8(2+2) @90", `1:18: the groups add up to 4 beats, but 7/8 has 7`},
+		{"unclosed grouping", "verse: 4 bars 7/8(2+2+3 @90", `1:25: expected "+" or ")" in the grouping, found "@"`},
+		{"empty group", "verse: 4 bars 4/4(0+4) @90", `1:19: groups must have at least one beat`},
+		{"tempo out of range", "intro: 4 bars @9000", `1:15: BPM must be between 20 and 300, got 9000`},
+		{"rit. that speeds up", "intro: 4 bars @90 rit. to 100", `1:27: a ritardando slows down, but 100 is not below 90 BPM`},
+		{"accel. without to", "intro: 4 bars @90 accel. 100", `1:26: expected "to" after "accel.", like "accel. to 80", found "100"`},
+		{"swing with triplets", "intro: 4 bars @90 triplets swing 60%", `1:19: swing needs eighths or sixteenths, but triplets are given with swing at 1:28`},
+		{"swing with quarters after", "intro: 4 bars @90 swing 60% quarters", `1:29: swing needs eighths or sixteenths, but quarters are given with swing at 1:19`},
+		{"carried swing with triplets", "a: 2 bars @100 swing 60%\nb: 2 bars triplets", `2:11: swing carried over from the section before needs eighths or sixteenths, but triplets are given; add "straight" to play them straight`},
+		{"carried swing with quarters", "a: 2 bars @100 sixteenths swing 55; b: 1 bar quarters", `1:46: swing carried over from the section before needs eighths or sixteenths, but quarters are given; add "straight" to play them straight`},
+		{"unknown word", "intro: 4 bars @90 loudly", `1:19: unexpected "loudly"; expected bars, a meter, @tempo, rit. or accel., swing or eighths`},
+		{"non-ASCII digits", "intro: ٣ bars @90", `1:8: unexpected '٣'`},
+		{"number too big", "intro: 99999999999999999999 bars @90", `1:8: 99999999999999999999 is too big`},
+		{"position on a later line", "intro: 4 bars @90\n\nverse: 8 bars; bridge: 4 barz", `3:26: expected "bars" after 4, or a meter like 4/4, found "barz"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("song", tt.text)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got  %s\nwant %s", err, tt.want)
+			}
+		})
+	}
+}
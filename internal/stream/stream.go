@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Format is how beats are written to the stream
+type Format int
+
+const (
+	FormatJSON Format = iota // One JSON object per line
+	FormatText               // One line of space-separated fields
+	FormatBars               // One line per bar for people, like | 1 . > . |
+)
+
+// Formats lists the format names accepted by ParseFormat
+var Formats = []string{"json", "text", "bars"}
+
+// String returns the format's name
+func (f Format) String() string {
+	return Formats[f]
+}
+
+// ParseFormat parses a format name such as "json"
+func ParseFormat(s string) (Format, error) {
+	for i, name := range Formats {
+		if strings.EqualFold(s, name) {
+			return Format(i), nil
+		}
+	}
+	last := len(Formats) - 1
+	return 0, fmt.Errorf("unknown stream format %q, expected %s or %s", s, strings.Join(Formats[:last], ", "), Formats[last])
+}
+
+// Event is a beat as written to the stream
+type Event struct {
+	Time        time.Time `json:"time"`
+	Bar         int       `json:"bar"`
+	Beat        int       `json:"beat"`
+	Subdivision int       `json:"subdivision"`
+	Accent      bool      `json:"accent"`
+	BPM         int       `json:"bpm"`
+}
+
+// EventFor describes a beat
+func EventFor(b metronome.Beat) Event {
+	return Event{
+		Time:        b.Time.UTC(),
+		Bar:         b.Bar,
+		Beat:        b.Beat,
+		Subdivision: b.Subdivision,
+		Accent:      b.Accent,
+		BPM:         b.BPM,
+	}
+}
+
+// Writer writes beats to an io.Writer
+type Writer struct {
+	w      io.Writer
+	format Format
+	inBar  bool // A bars line has been started but not finished
+}
+
+// NewWriter creates a writer for the given format
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{w: w, format: format}
+}
+
+// Write writes a single beat. Text lines hold the time, bar, beat,
+// subdivision, accent (1 or 0) and BPM, so they split easily in a shell:
+//
+//	2024-05-04T12:00:00.1Z 1 1 0 1 120
+func (s *Writer) Write(b metronome.Beat) error {
+	e := EventFor(b)
+	var err error
+	switch s.format {
+	case FormatBars:
+		err = s.writeBar(b)
+	case FormatText:
+		accent := 0
+		if e.Accent {
+			accent = 1
+		}
+		_, err = fmt.Fprintf(s.w, "%s %d %d %d %d %d\n",
+			e.Time.Format(time.RFC3339Nano), e.Bar, e.Beat, e.Subdivision, accent, e.BPM)
+	default:
+		var line []byte
+		if line, err = json.Marshal(e); err == nil {
+			_, err = s.w.Write(append(line, '\n'))
+		}
+	}
+	return err
+}
+
+// writeBar adds a beat to the current bars line, starting a new line on each
+// downbeat. Subdivisions are left out to keep the line readable.
+func (s *Writer) writeBar(b metronome.Beat) error {
+	if b.Subdivision > 0 {
+		return nil
+	}
+	var err error
+	switch {
+	case b.IsDownbeat() && s.inBar:
+		_, err = io.WriteString(s.w, " |\n| 1")
+	case b.IsDownbeat():
+		_, err = io.WriteString(s.w, "| 1")
+	case s.inBar && b.Accent:
+		_, err = io.WriteString(s.w, " >")
+	case s.inBar:
+		_, err = io.WriteString(s.w, " .")
+	default:
+		// Joined partway through a bar
+		_, err = fmt.Fprintf(s.w, "|%s .", strings.Repeat("  ", b.Beat-1))
+	}
+	s.inBar = true
+	return err
+}
+
+// Flush finishes any partly written line
+func (s *Writer) Flush() error {
+	if !s.inBar {
+		return nil
+	}
+	s.inBar = false
+	_, err := io.WriteString(s.w, " |\n")
+	return err
+}
+
+// Follow writes beats from a subscription to the metronome as they sound,
+// until stop is closed or a write fails. Beats arrive from the engine a
+// look-ahead window early, so each one is held back until it is due.
+func (s *Writer) Follow(metro *metronome.Metronome, beats <-chan metronome.Beat, stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case b := <-beats:
+			wait := time.NewTimer(time.Until(b.Time))
+			select {
+			case <-stop:
+				wait.Stop()
+				return nil
+			case <-wait.C:
+			}
+
+			// Skip beats queued before a stop or restart
+			if !metro.IsScheduled(b) {
+				continue
+			}
+			if err := s.Write(b); err != nil {
+				return err
+			}
+		}
+	}
+}
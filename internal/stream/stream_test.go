@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+func TestParseFormat(t *testing.T) {
+	for i, name := range []string{"json", "TEXT", "Bars"} {
+		f, err := ParseFormat(name)
+		if err != nil || f != Format(i) {
+			t.Errorf("ParseFormat(%q) = %v, %v", name, f, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil || err.Error() != `unknown stream format "xml", expected json, text or bars` {
+		t.Errorf("ParseFormat(xml) gave %v", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	at := time.Date(2024, 5, 4, 12, 0, 0, 100_000_000, time.FixedZone("CEST", 2*60*60))
+	beat := func(bar, beat, sub int, accent bool) metronome.Beat {
+		return metronome.Beat{Bar: bar, Beat: beat, Subdivision: sub, Subdivisions: 2, Beats: 3, BeatValue: 4, Accent: accent, BPM: 120, Time: at}
+	}
+	// Joined on the second beat of a bar with the third accented
+	beats := []metronome.Beat{
+		beat(1, 2, 0, false), beat(1, 2, 1, false), beat(1, 3, 0, true),
+		beat(2, 1, 0, true), beat(2, 2, 0, false),
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatJSON, `{"time":"2024-05-04T10:00:00.1Z","bar":1,"beat":2,"subdivision":0,"accent":false,"bpm":120}` + "\n" +
+			`{"time":"2024-05-04T10:00:00.1Z","bar":1,"beat":2,"subdivision":1,"accent":false,"bpm":120}` + "\n" +
+			`{"time":"2024-05-04T10:00:00.1Z","bar":1,"beat":3,"subdivision":0,"accent":true,"bpm":120}` + "\n" +
+			`{"time":"2024-05-04T10:00:00.1Z","bar":2,"beat":1,"subdivision":0,"accent":true,"bpm":120}` + "\n" +
+			`{"time":"2024-05-04T10:00:00.1Z","bar":2,"beat":2,"subdivision":0,"accent":false,"bpm":120}` + "\n"},
+		{FormatText, "2024-05-04T10:00:00.1Z 1 2 0 0 120\n" +
+			"2024-05-04T10:00:00.1Z 1 2 1 0 120\n" +
+			"2024-05-04T10:00:00.1Z 1 3 0 1 120\n" +
+			"2024-05-04T10:00:00.1Z 2 1 0 1 120\n" +
+			"2024-05-04T10:00:00.1Z 2 2 0 0 120\n"},
+		{FormatBars, "|   . > |\n| 1 . |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, tt.format)
+			for _, b := range beats {
+				if err := w.Write(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("wrote\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+
+			// Nothing is left to finish
+			if err := w.Flush(); err != nil || buf.String() != tt.want {
+				t.Errorf("a second flush wrote %q", strings.TrimPrefix(buf.String(), tt.want))
+			}
+		})
+	}
+}
+
+// syncBuffer is a buffer that can be read while another goroutine writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollow(t *testing.T) {
+	metro := metronome.New(120, metronome.CommonTimeSignatures[0])
+	metro.SetExternal(true)
+	beats, unsubscribe := metro.Subscribe()
+	defer unsubscribe()
+
+	var buf syncBuffer
+	w := NewWriter(&buf, FormatText)
+	stop, done := make(chan struct{}), make(chan error)
+
+	// A beat left over from a run that has since ended
+	metro.SyncStart()
+	metro.SyncBeat(0, time.Now(), 120)
+	metro.SyncStop()
+
+	metro.SyncStart()
+	defer metro.SyncStop()
+	due := time.Now().Add(50 * time.Millisecond)
+	metro.SyncBeat(5, due, 120)
+	go func() { done <- w.Follow(metro, beats, stop) }()
+
+	time.Sleep(20 * time.Millisecond)
+	if buf.String() != "" {
+		t.Errorf("wrote %q before the beat was due", buf.String())
+	}
+	time.Sleep(60 * time.Millisecond)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if want := due.UTC().Format(time.RFC3339Nano) + " 2 2 0 0 120\n"; buf.String() != want {
+		t.Errorf("wrote %q, want only the current run's beat %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,251 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/audio"
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// Calibration settings
+const (
+	calibrationBPM    = 100
+	calibrationWarmup = 4  // Taps ignored while the user finds the groove
+	calibrationTaps   = 16 // Taps needed in total
+)
+
+// calibration is the state of the latency calibration wizard
+type calibration struct {
+	metro       *metronome.Metronome
+	scheduler   *audio.Scheduler
+	beats       <-chan metronome.Beat
+	unsubscribe func()
+	stopped     chan struct{}   // Closed when the click track stops
+	clicks      []time.Time     // When each click was triggered
+	offsets     []time.Duration // How far each tap landed from its click
+	result      time.Duration
+	spread      time.Duration
+	done        bool
+	err         error
+}
+
+// calibrationBeatMsg is sent for each click of the calibration metronome
+type calibrationBeatMsg struct {
+	calibration *calibration
+	beat        metronome.Beat
+}
+
+// calibrationSavedMsg reports the result of saving the measured offset
+type calibrationSavedMsg struct {
+	offset time.Duration
+	err    error
+}
+
+// newCalibration starts the calibration click track. Clicks are played
+// without any latency compensation so the full offset can be measured.
+func newCalibration(player *audio.Player) *calibration {
+	metro := metronome.New(calibrationBPM, metronome.CommonTimeSignatures[0])
+	beats, unsubscribe := metro.Subscribe()
+	c := &calibration{
+		metro:       metro,
+		scheduler:   audio.NewScheduler(player, metro),
+		beats:       beats,
+		unsubscribe: unsubscribe,
+		stopped:     make(chan struct{}),
+	}
+	metro.Start()
+	return c
+}
+
+// stop halts the calibration click track and ends its listener. Stopping
+// a finished calibration again is fine.
+func (c *calibration) stop() {
+	select {
+	case <-c.stopped:
+		return
+	default:
+	}
+	close(c.stopped)
+	c.unsubscribe()
+	c.metro.Stop()
+	c.scheduler.Close()
+}
+
+// tap records a tap against the nearest click
+func (c *calibration) tap(at time.Time) {
+	if c.done || len(c.clicks) == 0 {
+		return
+	}
+
+	nearest := c.clicks[0]
+	for _, b := range c.clicks {
+		if math.Abs(float64(at.Sub(b))) < math.Abs(float64(at.Sub(nearest))) {
+			nearest = b
+		}
+	}
+	c.offsets = append(c.offsets, at.Sub(nearest))
+
+	if len(c.offsets) >= calibrationTaps {
+		c.finish()
+	}
+}
+
+// finish computes the average offset, ignoring the warm-up taps and any
+// taps far from the rest
+func (c *calibration) finish() {
+	c.done = true
+	c.stop()
+
+	taps := append([]time.Duration(nil), c.offsets[calibrationWarmup:]...)
+	sort.Slice(taps, func(i, j int) bool { return taps[i] < taps[j] })
+	median := taps[len(taps)/2]
+
+	var sum time.Duration
+	var kept []time.Duration
+	for _, t := range taps {
+		if (t - median).Abs() < 80*time.Millisecond {
+			kept = append(kept, t)
+			sum += t
+		}
+	}
+	c.result = (sum / time.Duration(len(kept))).Round(time.Millisecond)
+
+	// Spread tells the user how consistent their tapping was
+	var variance float64
+	for _, t := range kept {
+		d := float64(t - c.result)
+		variance += d * d
+	}
+	c.spread = time.Duration(math.Sqrt(variance / float64(len(kept)))).Round(time.Millisecond)
+}
+
+// listenForCalibration creates a command that listens for a calibration's
+// clicks until it stops
+func listenForCalibration(c *calibration) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case b := <-c.beats:
+			return calibrationBeatMsg{calibration: c, beat: b}
+		case <-c.stopped:
+			return nil
+		}
+	}
+}
+
+// saveLatency persists the measured offset to the config file. The file is
+// read afresh so changes made since startup aren't overwritten, and left
+// alone if it can't be read.
+func saveLatency(offset time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		cfg, err := config.Load()
+		if err != nil {
+			return calibrationSavedMsg{offset: offset, err: err}
+		}
+		cfg.LatencyOffsetMs = int(offset / time.Millisecond)
+		return calibrationSavedMsg{offset: offset, err: config.Save(cfg)}
+	}
+}
+
+// updateCalibration handles keys while the wizard is open. It reports
+// whether the key was consumed.
+func (m Model) updateCalibration(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	c := m.calibration
+	switch {
+	case key.Matches(msg, m.keys.Space):
+		c.tap(time.Now())
+	case msg.String() == "r":
+		c.stop()
+		m.calibration = newCalibration(m.player)
+		return m, listenForCalibration(m.calibration), true
+	case msg.Type == tea.KeyEnter && c.done:
+		return m, saveLatency(c.result), true
+	case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.Align):
+		c.stop()
+		m.calibration = nil
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// renderCalibration renders the latency calibration wizard
+func (m Model) renderCalibration() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(2)
+
+	textStyle := lipgloss.NewStyle().
+		Foreground(m.colors.text)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(m.colors.dim)
+
+	title := titleStyle.Render("⏱️  Gnome Ear Calibration ⏱️")
+
+	c := m.calibration
+	var body, instructions string
+	if c.done {
+		body = textStyle.Render(fmt.Sprintf(
+			"Your clicks arrive %s after they are triggered (±%s).\n\nCurrently saved: %s",
+			c.result, c.spread, m.config.LatencyOffset()))
+		if c.err != nil {
+			body += "\n\n" + lipgloss.NewStyle().
+				Foreground(m.colors.err).
+				Render("Could not save: "+c.err.Error())
+		}
+		instructions = "ENTER to save, R to try again, ESC to cancel"
+	} else {
+		progress := ""
+		for i := 0; i < calibrationTaps; i++ {
+			switch {
+			case i < len(c.offsets):
+				progress += "●"
+			case i < calibrationWarmup:
+				progress += "◌"
+			default:
+				progress += "○"
+			}
+		}
+		body = lipgloss.JoinVertical(
+			lipgloss.Center,
+			textStyle.Render("Tap SPACE exactly when you hear each click."),
+			dimStyle.Render("The first few taps are a warm-up and are not counted."),
+			"",
+			progress,
+		)
+		instructions = "R to restart, ESC to cancel"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Center,
+		title,
+		body,
+		lipgloss.NewStyle().MarginTop(2).Render(dimStyle.Render(instructions)),
+	)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
+
+// handleCalibrationBeat remembers when a calibration click is due. The
+// scheduler triggers the sound at exactly that time, which is also when the
+// gnomes light up.
+func (m Model) handleCalibrationBeat(msg calibrationBeatMsg) (Model, tea.Cmd) {
+	if msg.calibration != m.calibration || m.calibration.done {
+		// Left over from a calibration that was restarted or has ended
+		return m, nil
+	}
+	m.calibration.clicks = append(m.calibration.clicks, msg.beat.Time)
+	return m, listenForCalibration(m.calibration)
+}
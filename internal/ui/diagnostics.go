@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/metrics"
+)
+
+// histogramWidth is the number of cells in the longest histogram bar
+const histogramWidth = 20
+
+// renderDiagnostics renders the timing diagnostics screen
+func (m Model) renderDiagnostics() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(1)
+
+	headingStyle := lipgloss.NewStyle().
+		Foreground(m.colors.highlight).
+		Bold(true)
+
+	title := titleStyle.Render("🔬 Gnome Timing Diagnostics 🔬")
+
+	var blocks []string
+	for _, h := range []struct {
+		name string
+		hist *metrics.Histogram
+	}{
+		{"Scheduler jitter", metrics.SchedulerJitter},
+		{"Audio queue latency", metrics.AudioLatency},
+		{"Frame render time", metrics.FrameRender},
+	} {
+		s := h.hist.Snapshot()
+		stats := "no samples yet"
+		if s.Count > 0 {
+			stats = fmt.Sprintf("%d samples, p50 %s, p99 %s",
+				s.Count, formatQuantile(s, 0.5), formatQuantile(s, 0.99))
+		}
+		blocks = append(blocks, lipgloss.JoinVertical(lipgloss.Left,
+			headingStyle.Render(h.name), stats, renderHistogram(s)))
+	}
+
+	// Side by side when they fit, otherwise one under another
+	gap := lipgloss.NewStyle().PaddingRight(4)
+	spaced := make([]string, len(blocks))
+	for i, block := range blocks {
+		spaced[i] = gap.Render(block)
+	}
+	histograms := lipgloss.JoinHorizontal(lipgloss.Top, spaced...)
+	if lipgloss.Width(histograms) > m.width {
+		histograms = lipgloss.JoinVertical(lipgloss.Left, blocks...)
+	}
+	sections := []string{title, histograms}
+
+	dropped := metrics.DroppedBeats.Value()
+	droppedStyle := lipgloss.NewStyle().Foreground(m.colors.dim)
+	if dropped > 0 {
+		droppedStyle = droppedStyle.Foreground(m.colors.err)
+	}
+	sections = append(sections, droppedStyle.Render(fmt.Sprintf("Dropped subscriber beats: %d", dropped)))
+
+	instructions := lipgloss.NewStyle().
+		Foreground(m.colors.dim).
+		MarginTop(1).
+		Render("Also served at /metrics by the remote control · D to go back")
+	sections = append(sections, instructions)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(lipgloss.JoinVertical(lipgloss.Left, sections...))
+}
+
+// renderHistogram draws one bar per bucket, scaled to the fullest one
+func renderHistogram(s metrics.Snapshot) string {
+	var most uint64
+	for _, n := range s.Counts {
+		if n > most {
+			most = n
+		}
+	}
+
+	var b strings.Builder
+	for i, n := range s.Counts {
+		label := "> " + formatSeconds(s.Bounds[len(s.Bounds)-1])
+		if i < len(s.Bounds) {
+			label = "≤ " + formatSeconds(s.Bounds[i])
+		}
+		cells := 0
+		if most > 0 {
+			cells = int(math.Ceil(float64(n) / float64(most) * histogramWidth))
+		}
+		fmt.Fprintf(&b, "%9s │%-*s %d\n", label, histogramWidth, strings.Repeat("█", cells), n)
+	}
+	return b.String()
+}
+
+// formatQuantile shows the bucket a quantile falls in
+func formatQuantile(s metrics.Snapshot, q float64) string {
+	v := s.Quantile(q)
+	if math.IsInf(v, 1) {
+		return "> " + formatSeconds(s.Bounds[len(s.Bounds)-1])
+	}
+	return "≤ " + formatSeconds(v)
+}
+
+// formatSeconds shows a time in seconds as milliseconds
+func formatSeconds(v float64) string {
+	return fmt.Sprintf("%gms", v*1000)
+}
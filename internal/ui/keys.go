@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyActions names each rebindable binding for the config file
+var keyActions = map[string]func(k *keyMap) *key.Binding{
+	"start_stop":  func(k *keyMap) *key.Binding { return &k.Space },
+	"bpm_up":      func(k *keyMap) *key.Binding { return &k.Up },
+	"bpm_down":    func(k *keyMap) *key.Binding { return &k.Down },
+	"prev_meter":  func(k *keyMap) *key.Binding { return &k.Left },
+	"next_meter":  func(k *keyMap) *key.Binding { return &k.Right },
+	"cycle_meter": func(k *keyMap) *key.Binding { return &k.Tab },
+	"presets":     func(k *keyMap) *key.Binding { return &k.Preset },
+	"setlist":     func(k *keyMap) *key.Binding { return &k.Songs },
+	"prev_song":   func(k *keyMap) *key.Binding { return &k.Prev },
+	"next_song":   func(k *keyMap) *key.Binding { return &k.Next },
+	"songs":       func(k *keyMap) *key.Binding { return &k.Editor },
+	"kits":        func(k *keyMap) *key.Binding { return &k.Kit },
+	"mixer":       func(k *keyMap) *key.Binding { return &k.Mixer },
+	"diagnostics": func(k *keyMap) *key.Binding { return &k.Diag },
+	"sound":       func(k *keyMap) *key.Binding { return &k.Sound },
+	"voice":       func(k *keyMap) *key.Binding { return &k.Voice },
+	"subdivision": func(k *keyMap) *key.Binding { return &k.Subdiv },
+	"accents":     func(k *keyMap) *key.Binding { return &k.Accent },
+	"swing":       func(k *keyMap) *key.Binding { return &k.Swing },
+	"calibrate":   func(k *keyMap) *key.Binding { return &k.Align },
+	"help":        func(k *keyMap) *key.Binding { return &k.Help },
+	"quit":        func(k *keyMap) *key.Binding { return &k.Quit },
+}
+
+// rebind returns a copy of the key map with the given actions bound to new
+// keys. Keys are named as bubbletea names them, e.g. "a", "ctrl+s", "enter",
+// with "space" for the space bar.
+func (k keyMap) rebind(overrides map[string][]string) (keyMap, error) {
+	actions := make([]string, 0, len(overrides))
+	for action := range overrides {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	for _, action := range actions {
+		binding, ok := keyActions[action]
+		if !ok {
+			return k, fmt.Errorf("keys: unknown action %q", action)
+		}
+		names := overrides[action]
+		if len(names) == 0 {
+			return k, fmt.Errorf("keys: %s has no keys", action)
+		}
+
+		b := binding(&k)
+		pressed := make([]string, len(names))
+		for i, name := range names {
+			pressed[i] = name
+			if name == "space" {
+				pressed[i] = " "
+			}
+		}
+		b.SetKeys(pressed...)
+		b.SetHelp(strings.Join(names, "/"), b.Help().Desc)
+	}
+
+	// A key can only do one thing
+	owner := make(map[string]string)
+	for _, action := range sortedActions() {
+		for _, pressed := range keyActions[action](&k).Keys() {
+			if other, ok := owner[pressed]; ok {
+				return k, fmt.Errorf("keys: %q is bound to both %s and %s", pressed, other, action)
+			}
+			owner[pressed] = action
+		}
+	}
+	return k, nil
+}
+
+// sortedActions lists the action names in alphabetical order
+func sortedActions() []string {
+	actions := make([]string, 0, len(keyActions))
+	for action := range keyActions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/audio"
+)
+
+// sliderWidth is the number of cells in a mixer slider
+const sliderWidth = 20
+
+// mixerControl is one adjustable row on the mixer screen
+type mixerControl struct {
+	label    string
+	min, max float64
+	step     float64
+	value    func(audio.Mixer) float64
+	set      func(*audio.Mixer, float64)
+	format   func(float64) string
+}
+
+// mixerControls builds the rows shown on the mixer screen
+func mixerControls() []mixerControl {
+	percent := func(v float64) string { return fmt.Sprintf("%3.0f%%", v*100) }
+	semitones := func(v float64) string { return fmt.Sprintf("%+3.0f st", v) }
+	pan := func(v float64) string {
+		switch {
+		case v < 0:
+			return fmt.Sprintf("L%3.0f", -v*100)
+		case v > 0:
+			return fmt.Sprintf("R%3.0f", v*100)
+		default:
+			return " C  "
+		}
+	}
+
+	controls := []mixerControl{{
+		label: "Master volume",
+		min:   0, max: 1, step: 0.05,
+		value:  func(m audio.Mixer) float64 { return m.Master },
+		set:    func(m *audio.Mixer, v float64) { m.Master = v },
+		format: percent,
+	}}
+
+	for _, slot := range audio.AllSlots {
+		slot := slot
+		name := strings.ToUpper(slot.String()[:1]) + slot.String()[1:]
+		controls = append(controls,
+			mixerControl{
+				label: name + " volume",
+				min:   0, max: 1, step: 0.05,
+				value:  func(m audio.Mixer) float64 { return m.Volume[slot] },
+				set:    func(m *audio.Mixer, v float64) { m.Volume[slot] = v },
+				format: percent,
+			},
+			mixerControl{
+				label: name + " pitch",
+				min:   -audio.MaxPitch, max: audio.MaxPitch, step: 1,
+				value:  func(m audio.Mixer) float64 { return m.Pitch[slot] },
+				set:    func(m *audio.Mixer, v float64) { m.Pitch[slot] = v },
+				format: semitones,
+			},
+		)
+	}
+
+	for _, layer := range audio.AllLayers {
+		layer := layer
+		name := strings.ToUpper(layer.String()[:1]) + layer.String()[1:]
+		controls = append(controls, mixerControl{
+			label: name + " pan",
+			min:   -audio.MaxPan, max: audio.MaxPan, step: 0.1,
+			value:  func(m audio.Mixer) float64 { return m.Pan[layer] },
+			set:    func(m *audio.Mixer, v float64) { m.Pan[layer] = v },
+			format: pan,
+		})
+	}
+
+	return controls
+}
+
+// updateMixer handles keys while the mixer screen is open. It reports
+// whether the key was consumed.
+func (m Model) updateMixer(msg tea.KeyMsg) (Model, bool) {
+	controls := mixerControls()
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.mixerRow > 0 {
+			m.mixerRow--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.mixerRow < len(controls)-1 {
+			m.mixerRow++
+		}
+	case key.Matches(msg, m.keys.Left), key.Matches(msg, m.keys.Right):
+		c := controls[m.mixerRow]
+		step := c.step
+		if key.Matches(msg, m.keys.Left) {
+			step = -step
+		}
+		mixer := m.player.Mixer()
+		// Round to the step so repeated presses don't drift
+		v := math.Round((c.value(mixer)+step)/c.step) * c.step
+		c.set(&mixer, math.Max(c.min, math.Min(c.max, v)))
+		m.mixerErr = m.player.SetMixer(mixer)
+	case msg.String() == "0":
+		// Reset the selected control to its default
+		c := controls[m.mixerRow]
+		mixer := m.player.Mixer()
+		c.set(&mixer, c.value(audio.DefaultMixer()))
+		m.mixerErr = m.player.SetMixer(mixer)
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// renderSlider draws a horizontal slider for value within [min, max]
+func renderSlider(value, min, max float64) string {
+	filled := int(math.Round((value - min) / (max - min) * sliderWidth))
+	if min < 0 {
+		// Bipolar controls show a marker instead of a fill
+		cells := []rune(strings.Repeat("─", sliderWidth+1))
+		cells[sliderWidth/2] = '┼'
+		cells[filled] = '●'
+		return string(cells)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", sliderWidth-filled)
+}
+
+// renderMixer renders the mixer screen
+func (m Model) renderMixer() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(2)
+
+	rowStyle := lipgloss.NewStyle().
+		PaddingLeft(2).
+		PaddingRight(2)
+
+	selectedStyle := rowStyle.Copy().
+		Foreground(m.colors.highlight).
+		Background(m.colors.selected).
+		Bold(true)
+
+	title := titleStyle.Render("🎚️  Gnome Mixing Desk 🎚️")
+
+	mixer := m.player.Mixer()
+	rows := ""
+	for i, c := range mixerControls() {
+		style := rowStyle
+		if i == m.mixerRow {
+			style = selectedStyle
+		}
+		v := c.value(mixer)
+		line := fmt.Sprintf("%-20s %s %s", c.label, renderSlider(v, c.min, c.max), c.format(v))
+		rows += style.Render(line) + "\n"
+	}
+
+	status := ""
+	if m.mixerErr != nil {
+		status = lipgloss.NewStyle().
+			Foreground(m.colors.err).
+			Render("Could not apply mix: " + m.mixerErr.Error())
+	}
+
+	instructions := lipgloss.NewStyle().
+		Foreground(m.colors.dim).
+		MarginTop(2).
+		Render("Use ↑/↓ to select, ←/→ to adjust, 0 to reset, M to go back")
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Center,
+		title,
+		rows,
+		status,
+		instructions,
+	)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
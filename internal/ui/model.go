@@ -4,27 +4,68 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"os/exec"
-	"runtime"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/audio"
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metrics"
 	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/presets"
+	"github.com/drj613/metrognome/internal/setlist"
+	"github.com/drj613/metrognome/internal/songs"
 )
 
 // Model represents the UI state
 type Model struct {
 	metronome      *metronome.Metronome
+	beats          <-chan metronome.Beat
+	scheduler      *audio.Scheduler
 	currentBeat    int
 	lastBeatTime   time.Time
 	selectedPreset int
 	showPresets    bool
+	store          *presets.Store
+	presetEdit     presetMode
+	presetInput    textinput.Model
+	presetErr      error
+	setlist        *setlist.Setlist
+	song           int // Current song of the setlist
+	songBar        int // Bar of the current song being played
+	showSetlist    bool
+	setlistSel     int
+	setlistErr     error
+	showSongs      bool
+	songStore      *songs.Store
+	songSel        int // Song open in the song editor
+	sectionSel     int
+	songField      int
+	songEdit       songMode
+	songInput      textinput.Model
+	songErr        error
+	section        int    // Section of the song being played
+	sectionBar     int    // Bar within that section
+	beatBPM        int    // Tempo of the last beat, which changes during ramps
+	remoteNote     string // Last change made over the remote control
 	showHelp       bool
+	showKits       bool
+	kits           []kitEntry
+	selectedKit    int
+	kitErr         error
+	showMixer      bool
+	mixerRow       int
+	mixerErr       error
+	showDiag       bool
+	player         *audio.Player
+	config         config.Config
+	calibration    *calibration
 	help           help.Model
 	commandsTable  table.Model
 	keys           keyMap
@@ -33,17 +74,27 @@ type Model struct {
 	beatAnimation  int
 	gnomeFrame     int
 	soundEnabled   bool
-	starColors     []string
+	colors         palette
 	starPositions  [][]int
 	pendulumAngle  float64
 }
 
 // beatMsg is sent when a beat occurs
-type beatMsg int
+type beatMsg metronome.Beat
 
 // tickMsg is for animations
 type tickMsg time.Time
 
+// kitEntry is a kit shown in the kit picker, with its load error if malformed
+type kitEntry struct {
+	name string
+	kit  *audio.Kit
+	err  error
+}
+
+// kitsScannedMsg carries the kits found on disk
+type kitsScannedMsg []kitEntry
+
 // keyMap defines our key bindings
 type keyMap struct {
 	Up     key.Binding
@@ -53,7 +104,19 @@ type keyMap struct {
 	Space  key.Binding
 	Tab    key.Binding
 	Preset key.Binding
+	Songs  key.Binding
+	Prev   key.Binding
+	Next   key.Binding
+	Editor key.Binding
+	Kit    key.Binding
+	Mixer  key.Binding
+	Diag   key.Binding
 	Sound  key.Binding
+	Voice  key.Binding
+	Subdiv key.Binding
+	Accent key.Binding
+	Swing  key.Binding
+	Align  key.Binding
 	Help   key.Binding
 	Quit   key.Binding
 }
@@ -66,7 +129,8 @@ func (k keyMap) ShortHelp() []key.Binding {
 // FullHelp returns keybindings for the expanded help view
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Space, k.Tab, k.Sound, k.Preset},
+		{k.Space, k.Tab, k.Sound, k.Voice, k.Subdiv, k.Accent, k.Swing},
+		{k.Preset, k.Songs, k.Prev, k.Next, k.Editor, k.Kit, k.Mixer, k.Align, k.Diag},
 		{k.Up, k.Down, k.Left, k.Right},
 		{k.Help, k.Quit},
 	}
@@ -91,32 +155,80 @@ var keys = keyMap{
 	),
 	Space: key.NewBinding(
 		key.WithKeys(" "),
-		key.WithHelp("space", "start/stop"),
+		key.WithHelp("Space", "start/stop"),
 	),
 	Tab: key.NewBinding(
 		key.WithKeys("tab"),
-		key.WithHelp("tab", "toggle time signatures"),
+		key.WithHelp("Tab", "toggle time signatures"),
 	),
 	Preset: key.NewBinding(
 		key.WithKeys("p"),
 		key.WithHelp("p", "toggle presets"),
 	),
+	Songs: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "toggle setlist"),
+	),
+	Prev: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "previous song"),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next song"),
+	),
+	Editor: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "song builder"),
+	),
+	Kit: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "choose click kit"),
+	),
+	Mixer: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "mixer"),
+	),
+	Diag: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "timing diagnostics"),
+	),
 	Sound: key.NewBinding(
 		key.WithKeys("s"),
 		key.WithHelp("s", "toggle sound"),
 	),
+	Voice: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "toggle spoken count"),
+	),
+	Subdiv: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "cycle subdivision"),
+	),
+	Accent: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "cycle accents"),
+	),
+	Swing: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "cycle swing"),
+	),
+	Align: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "calibrate latency"),
+	),
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
 	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
-		key.WithHelp("q", "quit"),
+		key.WithHelp("q/Ctrl+C", "quit"),
 	),
 }
 
 // createCommandsTable creates a styled table with commands
-func createCommandsTable() table.Model {
+func createCommandsTable(k keyMap, colors palette) table.Model {
 	columns := []table.Column{
 		{Title: "Key", Width: 12},
 		{Title: "Action", Width: 30},
@@ -124,16 +236,28 @@ func createCommandsTable() table.Model {
 	}
 
 	rows := []table.Row{
-		{"Space", "Start/Stop metronome", "Every gnome needs their rhythm!"},
-		{"↑/k", "Increase BPM (+5)", "Faster steps through the garden"},
-		{"↓/j", "Decrease BPM (-5)", "Slower pace for flower sniffing"},
-		{"←/h", "Previous time signature", "Try different garden dances"},
-		{"→/l", "Next time signature", "Explore more rhythmic patterns"},
-		{"Tab", "Cycle time signatures", "Quick tempo style changes"},
-		{"p", "Toggle presets menu", "Choose pre-made garden rhythms"},
-		{"s", "Toggle sound on/off", "Gnomes prefer quiet sometimes"},
-		{"?", "Toggle this help", "Wisdom from the garden gnome"},
-		{"q/Ctrl+C", "Quit application", "Return to the mushroom house"},
+		{k.Space.Help().Key, "Start/Stop metronome", "Every gnome needs their rhythm!"},
+		{k.Up.Help().Key, "Increase BPM (+5)", "Faster steps through the garden"},
+		{k.Down.Help().Key, "Decrease BPM (-5)", "Slower pace for flower sniffing"},
+		{k.Left.Help().Key, "Previous time signature", "Try different garden dances"},
+		{k.Right.Help().Key, "Next time signature", "Explore more rhythmic patterns"},
+		{k.Tab.Help().Key, "Cycle time signatures", "Quick tempo style changes"},
+		{k.Preset.Help().Key, "Toggle presets menu", "Choose pre-made garden rhythms"},
+		{k.Songs.Help().Key, "Toggle setlist", "The whole evening's dances in order"},
+		{k.Prev.Help().Key, "Previous song", "Back to the last toadstool"},
+		{k.Next.Help().Key, "Next song", "On to the next toadstool"},
+		{k.Editor.Help().Key, "Build songs from sections", "Verses, bridges and slow endings"},
+		{k.Kit.Help().Key, "Choose click kit", "Every gnome has a favorite pebble"},
+		{k.Mixer.Help().Key, "Open the mixer", "Even gnomes need a sound check"},
+		{k.Diag.Help().Key, "Show timing diagnostics", "Is the gnome keeping time?"},
+		{k.Sound.Help().Key, "Toggle sound on/off", "Gnomes prefer quiet sometimes"},
+		{k.Voice.Help().Key, "Toggle spoken count", "A gnome counting out loud"},
+		{k.Subdiv.Help().Key, "Cycle subdivisions", "Little steps between big ones"},
+		{k.Accent.Help().Key, "Cycle accents", "Some steps land harder than others"},
+		{k.Swing.Help().Key, "Cycle swing", "Gnomes have a lilt in their walk"},
+		{k.Align.Help().Key, "Align audio latency", "Teach the gnomes to listen"},
+		{k.Help.Help().Key, "Toggle this help", "Wisdom from the garden gnome"},
+		{k.Quit.Help().Key, "Quit application", "Return to the mushroom house"},
 	}
 
 	t := table.New(
@@ -146,43 +270,126 @@ func createCommandsTable() table.Model {
 	tableStyle := table.DefaultStyles()
 	tableStyle.Header = tableStyle.Header.
 		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("86")).
+		BorderForeground(colors.title).
 		BorderBottom(true).
 		Bold(true).
-		Foreground(lipgloss.Color("86"))
+		Foreground(colors.title)
 
 	tableStyle.Selected = tableStyle.Selected.
-		Foreground(lipgloss.Color("212")).
-		Background(lipgloss.Color("236")).
+		Foreground(colors.highlight).
+		Background(colors.selected).
 		Bold(false)
 
 	t.SetStyles(tableStyle)
 	return t
 }
 
-// NewModel creates a new UI model
-func NewModel() Model {
+// NewModel creates a new UI model starting from the configured settings
+func NewModel(cfg config.Config) (Model, error) {
+	if err := cfg.Validate(); err != nil {
+		return Model{}, err
+	}
+	colors, err := lookupTheme(cfg.Theme)
+	if err != nil {
+		return Model{}, fmt.Errorf("theme: %w", err)
+	}
+	bindings, err := keys.rebind(cfg.Keys)
+	if err != nil {
+		return Model{}, err
+	}
+
+	kit := audio.DefaultKit()
+	if cfg.Kit != "" {
+		root, err := audio.KitsDir()
+		if err != nil {
+			return Model{}, err
+		}
+		if kit, err = audio.LoadKit(filepath.Join(root, cfg.Kit)); err != nil {
+			return Model{}, fmt.Errorf("kit: %w", err)
+		}
+	}
+
+	metro := metronome.New(cfg.BPM, cfg.Meter())
+	metro.SetSubdivision(cfg.Subdivision)
+	metro.SetCountIn(cfg.CountIn)
+	player := audio.NewPlayer(kit)
+	if cfg.Voice {
+		if err := player.SetSoundSet(audio.SoundVoice); err != nil {
+			player.Close()
+			return Model{}, err
+		}
+	}
+	store, err := presets.Load()
+	if err != nil {
+		player.Close()
+		return Model{}, err
+	}
+	songStore, err := songs.Load()
+	if err != nil {
+		player.Close()
+		return Model{}, err
+	}
+	beats, _ := metro.Subscribe()
+
 	m := Model{
-		metronome:      metronome.New(120, metronome.CommonTimeSignatures[0]),
+		metronome:      metro,
+		beats:          beats,
+		scheduler:      audio.NewScheduler(player, metro),
 		selectedPreset: 0,
 		showPresets:    false,
+		store:          store,
+		presetInput:    newPresetInput(),
+		songStore:      songStore,
+		songInput:      newSongInput(),
 		showHelp:       false,
 		help:           help.New(),
-		commandsTable:  createCommandsTable(),
-		keys:           keys,
+		commandsTable:  createCommandsTable(bindings, colors),
+		keys:           bindings,
 		gnomeFrame:     0,
-		soundEnabled:   true,
-		starColors:     []string{"240", "244", "250", "254", "230", "226", "222", "86", "212", "231"},
+		soundEnabled:   cfg.Sound,
+		player:         player,
+		config:         cfg,
+		colors:         colors,
 	}
 	m.help.ShowAll = false
+	m.scheduler.SetEnabled(m.soundEnabled)
+	m.applyLatency()
 	m.initializeStars()
-	return m
+	return m, nil
+}
+
+// Config returns the config with the settings currently in use, for saving
+// when the TUI quits
+func (m Model) Config() config.Config {
+	c := m.config
+	c.BPM = m.metronome.BPM()
+	c.TimeSignature = m.metronome.TimeSignature().String()
+	c.Subdivision = m.metronome.Subdivision()
+	c.CountIn = m.metronome.CountIn()
+	c.Sound = m.soundEnabled
+	c.Voice = m.player.SoundSet() == audio.SoundVoice
+	c.Kit = ""
+	if dir := m.player.Kit().Dir; dir != "" {
+		c.Kit = filepath.Base(dir)
+	}
+	return c
+}
+
+// applyLatency plays clicks early by the calibrated output latency, widening
+// the look-ahead window so there is always time to do so
+func (m Model) applyLatency() {
+	offset := m.config.LatencyOffset()
+	if offset < 0 {
+		offset = 0
+	}
+	m.metronome.SetLookAhead(m.config.LookAhead() + offset)
+	m.scheduler.SetOffset(offset)
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
-		listenForBeats(m.metronome),
+		listenForBeats(m.beats),
 		tickAnimation(),
 	)
 }
@@ -198,18 +405,37 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.initializeStars() // Reinitialize stars when window size changes
 
 	case beatMsg:
-		m.currentBeat = int(msg)
-		m.lastBeatTime = time.Now()
-		m.beatAnimation = 5 // Start beat animation
-
-		// Play sound if enabled
-		if m.soundEnabled {
-			// Play different sound for first beat
-			isFirstBeat := m.currentBeat == 1
-			go playSound(isFirstBeat)
+		beat := metronome.Beat(msg)
+
+		// Sound is handled by the scheduler; only whole beats from the
+		// current run light up the gnomes
+		if beat.Subdivision == 0 && m.metronome.IsScheduled(beat) {
+			m.currentBeat = beat.Beat
+			m.songBar = beat.Bar
+			m.section = beat.Section
+			m.sectionBar = beat.SectionBar
+			m.beatBPM = beat.BPM
+			m.lastBeatTime = time.Now()
+			m.beatAnimation = 5 // Start beat animation
 		}
 
-		return m, listenForBeats(m.metronome)
+		return m, listenForBeats(m.beats)
+
+	case calibrationBeatMsg:
+		return m.handleCalibrationBeat(msg)
+
+	case RemoteMsg:
+		m = m.handleRemote(msg)
+
+	case calibrationSavedMsg:
+		if m.calibration != nil {
+			m.calibration.err = msg.err
+		}
+		if msg.err == nil {
+			m.config.LatencyOffsetMs = int(msg.offset / time.Millisecond)
+			m.calibration = nil
+			m.applyLatency()
+		}
 
 	case tickMsg:
 		// Update animations
@@ -219,54 +445,91 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.gnomeFrame = (m.gnomeFrame + 1) % 4
 		
 		// Update pendulum swing
-		if m.metronome.IsPlaying {
+		if m.metronome.IsPlaying() {
 			// Swing based on BPM - faster BPM = faster swing
-			swingSpeed := float64(m.metronome.BPM) / 60.0 * 3.14159 / 10.0
+			swingSpeed := float64(m.metronome.BPM()) / 60.0 * 3.14159 / 10.0
 			m.pendulumAngle += swingSpeed
 		}
 		
+		m = m.advanceSetlist()
 		return m, tickAnimation()
 
+	case kitsScannedMsg:
+		m.kits = msg
+		m.selectedKit = 0
+		for i, entry := range m.kits {
+			if entry.kit != nil && entry.kit.Dir == m.player.Kit().Dir {
+				m.selectedKit = i
+			}
+		}
+
 	case tea.KeyMsg:
+		if m.calibration != nil {
+			mm, cmd, handled := m.updateCalibration(msg)
+			if handled || !key.Matches(msg, m.keys.Quit) {
+				// The wizard is modal; other keys are ignored
+				return mm, cmd
+			}
+			m.calibration.stop()
+		}
+
+		if m.showMixer {
+			if mm, handled := m.updateMixer(msg); handled {
+				return mm, nil
+			}
+		}
+
+		if m.showPresets {
+			if mm, cmd, handled := m.updatePresets(msg); handled {
+				return mm, cmd
+			}
+		}
+
+		if m.showSetlist {
+			if mm, handled := m.updateSetlist(msg); handled {
+				return mm, nil
+			}
+		}
+
+		if m.showSongs {
+			if mm, cmd, handled := m.updateSongs(msg); handled {
+				return mm, cmd
+			}
+		}
+
 		switch {
 		case key.Matches(msg, m.keys.Quit):
 			m.metronome.Stop()
+			m.scheduler.Close()
+			m.player.Close()
 			return m, tea.Quit
 
 		case key.Matches(msg, m.keys.Space):
-			if m.metronome.IsPlaying {
+			if m.metronome.IsPlaying() {
 				m.metronome.Stop()
 			} else {
 				m.metronome.Start()
 			}
-			return m, listenForBeats(m.metronome)
 
 		case key.Matches(msg, m.keys.Up):
-			m.metronome.SetBPM(m.metronome.BPM + 5)
+			m.metronome.SetBPM(m.metronome.BPM() + 5)
 			// Reset beat animation state when BPM changes
 			m.beatAnimation = 0
 			m.currentBeat = 1
-			// Restart beat listening if metronome was playing
-			if m.metronome.IsPlaying {
-				return m, listenForBeats(m.metronome)
-			}
 
 		case key.Matches(msg, m.keys.Down):
-			m.metronome.SetBPM(m.metronome.BPM - 5)
+			m.metronome.SetBPM(m.metronome.BPM() - 5)
 			// Reset beat animation state when BPM changes
 			m.beatAnimation = 0
 			m.currentBeat = 1
-			// Restart beat listening if metronome was playing
-			if m.metronome.IsPlaying {
-				return m, listenForBeats(m.metronome)
-			}
 
 		case key.Matches(msg, m.keys.Tab):
 			// Cycle through time signatures
 			currentIndex := 0
+			current := m.metronome.TimeSignature()
 			for i, ts := range metronome.CommonTimeSignatures {
-				if ts.Beats == m.metronome.TimeSignature.Beats &&
-					ts.BeatValue == m.metronome.TimeSignature.BeatValue {
+				if ts.Beats == current.Beats &&
+					ts.BeatValue == current.BeatValue {
 					currentIndex = i
 					break
 				}
@@ -276,44 +539,145 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Reset beat animation state when time signature changes
 			m.beatAnimation = 0
 			m.currentBeat = 1
-			// Restart beat listening if metronome was playing
-			if m.metronome.IsPlaying {
-				return m, listenForBeats(m.metronome)
-			}
 
 		case key.Matches(msg, m.keys.Preset):
 			m.showPresets = !m.showPresets
+			m.presetEdit = presetBrowse
+			m.presetErr = nil
+			m.showSetlist = false
+			m.showSongs = false
+			m.showHelp = false
+			m.showKits = false
+			m.showMixer = false
+			m.showDiag = false
+
+		case key.Matches(msg, m.keys.Songs):
+			m.showSetlist = !m.showSetlist
+			m.setlistSel = m.song
+			m.setlistErr = nil
+			m.showSongs = false
+			m.showPresets = false
+			m.showHelp = false
+			m.showKits = false
+			m.showMixer = false
+			m.showDiag = false
+
+		case key.Matches(msg, m.keys.Editor):
+			m.showSongs = !m.showSongs
+			m.songEdit = songBrowse
+			m.songErr = nil
+			m.showSetlist = false
+			m.showPresets = false
+			m.showHelp = false
+			m.showKits = false
+			m.showMixer = false
+			m.showDiag = false
+
+		case key.Matches(msg, m.keys.Prev):
+			m = m.stepSong(-1)
+
+		case key.Matches(msg, m.keys.Next):
+			m = m.stepSong(1)
+
+		case key.Matches(msg, m.keys.Kit):
+			m.showKits = !m.showKits
 			m.showHelp = false
+			m.showPresets = false
+			m.showSetlist = false
+			m.showSongs = false
+			m.showMixer = false
+			m.showDiag = false
+			m.kitErr = nil
+			if m.showKits {
+				return m, scanKits
+			}
 
 		case key.Matches(msg, m.keys.Sound):
 			m.soundEnabled = !m.soundEnabled
+			m.scheduler.SetEnabled(m.soundEnabled)
+
+		case key.Matches(msg, m.keys.Voice):
+			set := audio.SoundVoice
+			if m.player.SoundSet() == audio.SoundVoice {
+				set = audio.SoundClicks
+			}
+			m.player.SetSoundSet(set)
+
+		case key.Matches(msg, m.keys.Subdiv):
+			// Cycle through none, eighths, triplets and sixteenths
+			m.metronome.SetSubdivision(m.metronome.Subdivision()%metronome.MaxSubdivision + 1)
+			// Reset beat animation state when subdivision changes
+			m.beatAnimation = 0
+			m.currentBeat = 1
+
+		case key.Matches(msg, m.keys.Accent):
+			m.metronome.SetAccents(nextAccents(m.metronome.Accents(), m.metronome.TimeSignature().Beats))
+
+		case key.Matches(msg, m.keys.Swing):
+			m.metronome.SetSwing(nextSwing(m.metronome.Swing()))
 
 		case key.Matches(msg, m.keys.Help):
 			m.showHelp = !m.showHelp
 			m.showPresets = false
+			m.showSetlist = false
+			m.showSongs = false
+			m.showKits = false
+			m.showMixer = false
+			m.showDiag = false
+
+		case key.Matches(msg, m.keys.Align):
+			// The main clicks would confuse the measurement
+			m.metronome.Stop()
+			m.showHelp = false
+			m.showPresets = false
+			m.showSetlist = false
+			m.showSongs = false
+			m.showKits = false
+			m.showMixer = false
+			m.showDiag = false
+			m.calibration = newCalibration(m.player)
+			return m, listenForCalibration(m.calibration)
+
+		case key.Matches(msg, m.keys.Mixer):
+			m.showMixer = !m.showMixer
+			m.showDiag = false
+			m.showHelp = false
+			m.showPresets = false
+			m.showSetlist = false
+			m.showSongs = false
+			m.showKits = false
+			m.mixerErr = nil
+
+		case key.Matches(msg, m.keys.Diag):
+			m.showDiag = !m.showDiag
+			m.showHelp = false
+			m.showPresets = false
+			m.showSetlist = false
+			m.showSongs = false
+			m.showKits = false
+			m.showMixer = false
 
 		case key.Matches(msg, m.keys.Left):
-			if m.showPresets && m.selectedPreset > 0 {
-				m.selectedPreset--
+			if m.showKits && m.selectedKit > 0 {
+				m.selectedKit--
+				m.kitErr = nil
 			}
 
 		case key.Matches(msg, m.keys.Right):
-			if m.showPresets && m.selectedPreset < len(metronome.CommonPresets)-1 {
-				m.selectedPreset++
+			if m.showKits && m.selectedKit < len(m.kits)-1 {
+				m.selectedKit++
+				m.kitErr = nil
 			}
 
 		case msg.Type == tea.KeyEnter:
-			if m.showPresets {
-				preset := metronome.CommonPresets[m.selectedPreset]
-				m.metronome.SetBPM(preset.BPM)
-				m.metronome.SetTimeSignature(preset.TimeSignature)
-				m.showPresets = false
-				// Reset beat animation state when preset changes
-				m.beatAnimation = 0
-				m.currentBeat = 1
-				// Restart beat listening if metronome was playing
-				if m.metronome.IsPlaying {
-					return m, listenForBeats(m.metronome)
+			if m.showKits && m.selectedKit < len(m.kits) {
+				entry := m.kits[m.selectedKit]
+				if entry.err != nil {
+					m.kitErr = entry.err
+				} else if err := m.player.SetKit(entry.kit); err != nil {
+					m.kitErr = err
+				} else {
+					m.showKits = false
 				}
 			}
 		}
@@ -324,6 +688,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View renders the UI
 func (m Model) View() string {
+	start := time.Now()
+	defer func() { metrics.FrameRender.ObserveDuration(time.Since(start)) }()
+
+	if m.calibration != nil {
+		return m.renderCalibration()
+	}
+
 	if m.showHelp {
 		return m.renderHelp()
 	}
@@ -332,13 +703,61 @@ func (m Model) View() string {
 		return m.renderPresets()
 	}
 
+	if m.showSetlist {
+		return m.renderSetlist()
+	}
+
+	if m.showSongs {
+		return m.renderSongs()
+	}
+
+	if m.showKits {
+		return m.renderKits()
+	}
+
+	if m.showMixer {
+		return m.renderMixer()
+	}
+
+	if m.showDiag {
+		return m.renderDiagnostics()
+	}
+
 	return m.renderMainWithBorder()
 }
 
-// listenForBeats creates a command that listens for metronome beats
-func listenForBeats(metro *metronome.Metronome) tea.Cmd {
+// scanKits loads the built-in kit and every kit found in the kits directory
+func scanKits() tea.Msg {
+	kits := []kitEntry{{name: audio.DefaultKitName + " (built-in)", kit: audio.DefaultKit()}}
+
+	root, err := audio.KitsDir()
+	if err != nil {
+		return kitsScannedMsg(kits)
+	}
+	dirs, err := audio.ListKits(root)
+	if err != nil {
+		return kitsScannedMsg(append(kits, kitEntry{name: root, err: err}))
+	}
+	for _, dir := range dirs {
+		kit, err := audio.LoadKit(dir)
+		kits = append(kits, kitEntry{name: filepath.Base(dir), kit: kit, err: err})
+	}
+	return kitsScannedMsg(kits)
+}
+
+// subdivisionNames describes each subdivision setting
+var subdivisionNames = map[int]string{
+	2: "Subdivided: 1 & 2 &",
+	3: "Subdivided: triplets",
+	4: "Subdivided: 1 e & a",
+}
+
+// listenForBeats creates a command that listens for metronome beats. Beats
+// arrive a look-ahead window early, so it waits until each one is due.
+func listenForBeats(beats <-chan metronome.Beat) tea.Cmd {
 	return func() tea.Msg {
-		beat := <-metro.BeatChannel()
+		beat := <-beats
+		time.Sleep(time.Until(beat.Time))
 		return beatMsg(beat)
 	}
 }
@@ -350,50 +769,70 @@ func tickAnimation() tea.Cmd {
 	})
 }
 
-// renderPresets renders the preset selection view
-func (m Model) renderPresets() string {
+// renderKits renders the click kit picker
+func (m Model) renderKits() string {
 	titleStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("86")).
+		Foreground(m.colors.title).
 		Bold(true).
 		MarginBottom(2)
 
-	presetStyle := lipgloss.NewStyle().
+	kitStyle := lipgloss.NewStyle().
 		PaddingLeft(2).
 		PaddingRight(2).
 		MarginBottom(1)
 
-	selectedStyle := presetStyle.Copy().
-		Foreground(lipgloss.Color("212")).
-		Background(lipgloss.Color("236")).
+	selectedStyle := kitStyle.Copy().
+		Foreground(m.colors.highlight).
+		Background(m.colors.selected).
 		Bold(true)
 
-	title := titleStyle.Render("🎵 Choose Your Garden Rhythm 🎵")
+	errorStyle := lipgloss.NewStyle().
+		Foreground(m.colors.err)
 
-	presets := ""
-	for i, preset := range metronome.CommonPresets {
-		style := presetStyle
-		if i == m.selectedPreset {
+	title := titleStyle.Render("🥁 Choose Your Click Kit 🥁")
+
+	kits := ""
+	for i, entry := range m.kits {
+		style := kitStyle
+		if i == m.selectedKit {
 			style = selectedStyle
 		}
 
-		line := fmt.Sprintf("%s - %d BPM (%s)\n  %s",
-			preset.Name,
-			preset.BPM,
-			preset.TimeSignature.Name,
-			preset.Description)
+		line := entry.name
+		if entry.err != nil {
+			line += "\n  " + errorStyle.Render("✗ "+entry.err.Error())
+		} else if entry.kit.Dir == m.player.Kit().Dir {
+			line += " ✓"
+		}
 
-		presets += style.Render(line) + "\n"
+		kits += style.Render(line) + "\n"
+	}
+
+	if len(m.kits) == 0 {
+		kits = "Searching the garden shed for kits..."
+	}
+
+	root, _ := audio.KitsDir()
+	hint := lipgloss.NewStyle().
+		Foreground(m.colors.dim).
+		Render(fmt.Sprintf("Kits live in %s (accent.wav, normal.wav, subdivision.wav, countin.wav)", root))
+
+	status := ""
+	if m.kitErr != nil {
+		status = errorStyle.Render("Could not load kit: " + m.kitErr.Error())
 	}
 
 	instructions := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("241")).
+		Foreground(m.colors.dim).
 		MarginTop(2).
-		Render("Use ←/→ to select, ENTER to confirm, P to go back")
+		Render("Use ←/→ to select, ENTER to confirm, C to go back")
 
 	content := lipgloss.JoinVertical(
 		lipgloss.Center,
 		title,
-		presets,
+		kits,
+		hint,
+		status,
 		instructions,
 	)
 
@@ -407,7 +846,7 @@ func (m Model) renderPresets() string {
 // renderHelp renders the help view
 func (m Model) renderHelp() string {
 	titleStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("86")).
+		Foreground(m.colors.title).
 		Bold(true).
 		MarginBottom(2)
 
@@ -417,13 +856,13 @@ func (m Model) renderHelp() string {
 	tableView := m.commandsTable.View()
 
 	gnomeWisdom := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("241")).
+		Foreground(m.colors.dim).
 		Italic(true).
 		MarginTop(2).
 		Render("\"A gnome without rhythm is like a garden without flowers!\"")
 
 	backInstruction := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("244")).
+		Foreground(m.colors.faint).
 		MarginTop(1).
 		Render("Press '?' again to return to the garden")
 
@@ -456,31 +895,31 @@ func (m Model) getGnome(beatPosition int) string {
 	}
 	
 	// Check if this gnome should be lit up for the current beat
-	if m.metronome.IsPlaying && m.currentBeat == beatPosition && m.beatAnimation > 0 {
+	if m.metronome.IsPlaying() && m.currentBeat == beatPosition && m.beatAnimation > 0 {
 		// This gnome is lit up
-		var color string
+		var color lipgloss.Color
 		if beatPosition == 1 {
 			// First beat (downbeat) - special teal color
-			color = "51" // Bright teal
+			color = m.colors.downbeat
 		} else {
 			// Other beats - bright yellow
-			color = "226" // Bright yellow
+			color = m.colors.beat
 		}
 		
 		return lipgloss.NewStyle().
-			Foreground(lipgloss.Color(color)).
+			Foreground(color).
 			Render(gnome)
 	} else {
 		// This gnome is dim - dark gray
 		return lipgloss.NewStyle().
-			Foreground(lipgloss.Color("240")). // Dim gray
+			Foreground(m.colors.idle). // Dim gray
 			Render(gnome)
 	}
 }
 
 // getBeatGnomes returns gnomes for each beat of the time signature
 func (m Model) getBeatGnomes() string {
-	numBeats := m.metronome.TimeSignature.Beats
+	numBeats := m.meter().Beats
 	gnomes := make([]string, numBeats)
 	
 	// Create a gnome for each beat position
@@ -586,7 +1025,7 @@ func (m Model) generateStarBackground(content string) string {
 					// Check if this position is empty space
 					if x >= len(lineRunes) || lineRunes[x] == ' ' {
 						// Choose star color based on beat animation and star index
-						colorIndex := i % len(m.starColors)
+						colorIndex := i % len(m.colors.stars)
 
 						// Make ALL stars flash together with the beat
 						if m.beatAnimation > 0 {
@@ -595,19 +1034,19 @@ func (m Model) generateStarBackground(content string) string {
 								// Peak brightness - ALL stars use brightest color
 								if m.currentBeat == 1 {
 									// First beat: ALL stars flash the brightest white
-									colorIndex = len(m.starColors) - 1
+									colorIndex = len(m.colors.stars) - 1
 								} else {
 									// Other beats: ALL stars flash bright yellow/green
-									colorIndex = len(m.starColors) - 2
+									colorIndex = len(m.colors.stars) - 2
 								}
 							} else if m.beatAnimation >= 2 {
 								// Medium fade - ALL stars dim together
-								colorIndex = len(m.starColors) - 3
+								colorIndex = len(m.colors.stars) - 3
 							} else {
 								// Final fade - ALL stars dim further together
-								colorIndex = len(m.starColors) - 4
+								colorIndex = len(m.colors.stars) - 4
 							}
-						} else if m.metronome.IsPlaying {
+						} else if m.metronome.IsPlaying() {
 							// Between beats - ALL stars stay dim but visible
 							colorIndex = 2 // Same dim color for all
 						} else {
@@ -616,9 +1055,9 @@ func (m Model) generateStarBackground(content string) string {
 						}
 
 						// Create twinkling star
-						starColor := m.starColors[colorIndex]
+						starColor := m.colors.stars[colorIndex]
 						starChar = lipgloss.NewStyle().
-							Foreground(lipgloss.Color(starColor)).
+							Foreground(starColor).
 							Render("✦")
 						starAtPosition = true
 						break
@@ -649,7 +1088,7 @@ func (m Model) renderMainWithBorder() string {
 	// Static yellow border
 	borderStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("226")).
+		BorderForeground(m.colors.border).
 		Padding(1, 2).
 		Width(m.width - 10).
 		Align(lipgloss.Center)
@@ -672,12 +1111,12 @@ func (m Model) renderMainWithBorder() string {
 func (m Model) renderMainContent() string {
 	// Styles
 	titleStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("86")).
+		Foreground(m.colors.title).
 		Bold(true).
 		MarginBottom(1)
 
 	bpmStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("212")).
+		Foreground(m.colors.highlight).
 		Bold(true)
 
 	beatStyle := lipgloss.NewStyle().
@@ -687,38 +1126,45 @@ func (m Model) renderMainContent() string {
 		MarginRight(1)
 
 	statusStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("241")).
+		Foreground(m.colors.dim).
 		MarginTop(1)
 
 	// Title
 	title := titleStyle.Render("🍄 Metrognome 🍄")
 
 	// BPM display
-	bpmDisplay := fmt.Sprintf("%d BPM", m.metronome.BPM)
+	bpm := m.metronome.BPM()
+	song := m.metronome.Song()
+	if song != nil && m.metronome.IsPlaying() && m.beatBPM > 0 {
+		// Show the tempo of the section being played
+		bpm = m.beatBPM
+	}
+	bpmDisplay := fmt.Sprintf("%d BPM", bpm)
 	bpmLine := bpmStyle.Render(bpmDisplay)
 
 	// Time signature
-	tsDisplay := fmt.Sprintf("%s", m.metronome.TimeSignature.Name)
+	meter := m.meter()
+	tsDisplay := fmt.Sprintf("%s", meter.Name)
 
 	// Beat visualization
 	beats := ""
-	for i := 1; i <= m.metronome.TimeSignature.Beats; i++ {
+	for i := 1; i <= meter.Beats; i++ {
 		style := beatStyle
-		if i == m.currentBeat && m.metronome.IsPlaying {
+		if i == m.currentBeat && m.metronome.IsPlaying() {
 			// Animate the current beat
 			if m.beatAnimation > 0 {
 				style = style.
-					Background(lipgloss.Color("212")).
-					Foreground(lipgloss.Color("231"))
+					Background(m.colors.highlight).
+					Foreground(m.colors.bright)
 			} else {
 				style = style.
-					Background(lipgloss.Color("240")).
-					Foreground(lipgloss.Color("231"))
+					Background(m.colors.idle).
+					Foreground(m.colors.bright)
 			}
 		} else {
 			style = style.
-				Background(lipgloss.Color("236")).
-				Foreground(lipgloss.Color("244"))
+				Background(m.colors.selected).
+				Foreground(m.colors.faint)
 		}
 
 		if i == 1 {
@@ -729,9 +1175,18 @@ func (m Model) renderMainContent() string {
 	}
 
 	// Status
-	status := "Press SPACE to start"
-	if m.metronome.IsPlaying {
-		status = "Playing... Press SPACE to stop"
+	status := fmt.Sprintf("Press %s to start", m.keys.Space.Help().Key)
+	if m.metronome.IsPlaying() {
+		status = fmt.Sprintf("Playing... Press %s to stop", m.keys.Space.Help().Key)
+	}
+	if m.metronome.External() {
+		status = "🔗 Waiting for the external clock to start"
+		if m.metronome.IsPlaying() {
+			status = "🔗 Following an external clock"
+		}
+	}
+	if m.remoteNote != "" {
+		status += "  ·  📡 Remote: " + m.remoteNote
 	}
 	statusLine := statusStyle.Render(status)
 
@@ -739,14 +1194,35 @@ func (m Model) renderMainContent() string {
 	soundStatus := "🔇 Sound: OFF"
 	if m.soundEnabled {
 		soundStatus = "🔊 Sound: ON"
+		if m.player.SoundSet() == audio.SoundVoice {
+			soundStatus += " (spoken count)"
+		}
+	}
+	if m.metronome.Subdivision() > 1 {
+		soundStatus += fmt.Sprintf("  ·  %s", subdivisionNames[m.metronome.Subdivision()])
+	}
+	if swing := m.metronome.Swing(); swing > 0 {
+		soundStatus += fmt.Sprintf("  ·  Swing %d%%", swing)
+	}
+	if accents := m.metronome.Accents(); accents != nil {
+		soundStatus += "  ·  " + describeAccents(accents)
 	}
 	soundLine := statusStyle.Render(soundStatus)
 
+	// Setlist progress
+	songLine := ""
+	if m.setlist != nil {
+		songLine = statusStyle.Render(m.songProgress())
+	}
+	if song != nil {
+		songLine = lipgloss.JoinVertical(lipgloss.Center, songLine, statusStyle.Render(m.songProgressLine(song)))
+	}
+
 	// Gnome saying
-	saying := m.metronome.TimeSignature.GnomeSaying
+	saying := meter.GnomeSaying
 
 	// BPM description
-	bpmDesc := metronome.GetBPMDescription(m.metronome.BPM)
+	bpmDesc := metronome.GetBPMDescription(m.metronome.BPM())
 
 	// Beat counter gnomes
 	gnomes := m.getBeatGnomes()
@@ -773,7 +1249,7 @@ func (m Model) renderMainContent() string {
 		"",
 		statusLine,
 		soundLine,
-		"",
+		songLine,
 	)
 
 	// Help hint
@@ -781,8 +1257,8 @@ func (m Model) renderMainContent() string {
 
 	// Quit instruction
 	quitInstruction := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("241")).
-		Render("Press 'q' or Ctrl+C to quit")
+		Foreground(m.colors.dim).
+		Render(fmt.Sprintf("Press %s to quit", m.keys.Quit.Help().Key))
 
 	// Add help and quit instruction at the bottom
 	bottomContent := lipgloss.JoinVertical(
@@ -836,7 +1312,7 @@ func (m Model) getSwingArm() string {
 	pendulumLine := string(lineRunes)
 	
 	return lipgloss.NewStyle().
-		Foreground(lipgloss.Color("244")).
+		Foreground(m.colors.faint).
 		Render(pendulumLine)
 }
 
@@ -913,53 +1389,3 @@ func max(a, b int) int {
 	}
 	return b
 }
-
-// playSound plays a system sound based on the OS
-func playSound(isFirstBeat bool) {
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		if isFirstBeat {
-			// Play the regular beat sound + an extra blip for first beat
-			go exec.Command("afplay", "/System/Library/Sounds/Tink.aiff").Run() // Regular sound
-			go exec.Command("afplay", "/System/Library/Sounds/Pop.aiff").Run()  // Extra blip
-		} else {
-			exec.Command("afplay", "/System/Library/Sounds/Tink.aiff").Run()
-		}
-	case "linux":
-		if isFirstBeat {
-			// Play regular beat + extra higher blip
-			go func() {
-				exec.Command("beep", "-f", "440", "-l", "50").Run() // Regular sound
-			}()
-			go func() {
-				time.Sleep(10 * time.Millisecond) // Slight delay
-				exec.Command("beep", "-f", "880", "-l", "30").Run() // Extra blip
-			}()
-		} else {
-			// Regular beat
-			if err := exec.Command("beep", "-f", "440", "-l", "50").Run(); err != nil {
-				exec.Command("paplay", "/usr/share/sounds/freedesktop/stereo/message.oga").Run()
-			}
-		}
-	case "windows":
-		if isFirstBeat {
-			// Play regular beat + extra blip
-			go func() {
-				exec.Command("powershell", "-c", "[console]::beep(800,100)").Run() // Regular sound
-			}()
-			go func() {
-				time.Sleep(50 * time.Millisecond) // Slight delay
-				exec.Command("powershell", "-c", "[console]::beep(1200,50)").Run() // Extra blip
-			}()
-		} else {
-			exec.Command("powershell", "-c", "[console]::beep(800,100)").Run()
-		}
-	default:
-		// Fallback to terminal bell
-		if isFirstBeat {
-			fmt.Print("\a\a") // Double bell for first beat
-		} else {
-			fmt.Print("\a")
-		}
-	}
-}
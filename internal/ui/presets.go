@@ -0,0 +1,354 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// presetMode is what the preset screen is waiting for
+type presetMode int
+
+const (
+	presetBrowse   presetMode = iota // Choosing a preset
+	presetNaming                     // Typing the name of a new preset
+	presetRenaming                   // Typing a new name for the selected preset
+	presetDeleting                   // Waiting for the delete to be confirmed
+)
+
+// Extra keys on the preset screen
+var (
+	presetNewKey    = key.NewBinding(key.WithKeys("n"))
+	presetRenameKey = key.NewBinding(key.WithKeys("r"))
+	presetEditKey   = key.NewBinding(key.WithKeys("e"))
+	presetDeleteKey = key.NewBinding(key.WithKeys("d"))
+	presetEarlier   = key.NewBinding(key.WithKeys("shift+left", "<"))
+	presetLater     = key.NewBinding(key.WithKeys("shift+right", ">"))
+)
+
+// swingSteps are the swing settings the swing key cycles through
+var swingSteps = []int{0, 55, 60, 67, 75}
+
+// newPresetInput creates the text box used to name presets
+func newPresetInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "Name your rhythm"
+	ti.CharLimit = 40
+	ti.Width = 30
+	return ti
+}
+
+// presetCount returns the number of presets on the preset screen
+func (m Model) presetCount() int {
+	return len(metronome.CommonPresets) + m.store.Len()
+}
+
+// presetAt returns the preset at a position on the preset screen, and its
+// index in the store if it is one of the user's
+func (m Model) presetAt(i int) (metronome.Preset, int, bool) {
+	if i < len(metronome.CommonPresets) {
+		return metronome.CommonPresets[i], -1, false
+	}
+	i -= len(metronome.CommonPresets)
+	return m.store.Presets()[i], i, true
+}
+
+// updatePresets handles keys while the preset screen is open. It reports
+// whether the key was consumed.
+func (m Model) updatePresets(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch m.presetEdit {
+	case presetNaming, presetRenaming:
+		return m.updatePresetName(msg)
+	case presetDeleting:
+		m.presetEdit = presetBrowse
+		if key.Matches(msg, presetDeleteKey) {
+			_, i, _ := m.presetAt(m.selectedPreset)
+			m.presetErr = m.store.Delete(i)
+			if m.selectedPreset >= m.presetCount() {
+				m.selectedPreset = m.presetCount() - 1
+			}
+		}
+		return m, nil, true
+	}
+
+	preset, i, mine := m.presetAt(m.selectedPreset)
+	m.presetErr = nil
+	switch {
+	case key.Matches(msg, m.keys.Left):
+		if m.selectedPreset > 0 {
+			m.selectedPreset--
+		}
+	case key.Matches(msg, m.keys.Right):
+		if m.selectedPreset < m.presetCount()-1 {
+			m.selectedPreset++
+		}
+	case msg.Type == tea.KeyEnter:
+		if err := m.metronome.Apply(preset); err != nil {
+			m.presetErr = err
+			break
+		}
+		m.showPresets = false
+		// Reset beat animation state when preset changes
+		m.beatAnimation = 0
+		m.currentBeat = 1
+	case key.Matches(msg, presetNewKey):
+		m.presetEdit = presetNaming
+		m.presetInput.SetValue("")
+		return m, m.presetInput.Focus(), true
+	case !mine && (key.Matches(msg, presetRenameKey, presetEditKey, presetDeleteKey, presetEarlier, presetLater)):
+		m.presetErr = fmt.Errorf("%q is a built-in gnome preset and can't be changed; press n to save your own", preset.Name)
+	case key.Matches(msg, presetRenameKey):
+		m.presetEdit = presetRenaming
+		m.presetInput.SetValue(preset.Name)
+		m.presetInput.CursorEnd()
+		return m, m.presetInput.Focus(), true
+	case key.Matches(msg, presetEditKey):
+		m.presetErr = m.store.Update(i, m.metronome.Preset())
+	case key.Matches(msg, presetDeleteKey):
+		m.presetEdit = presetDeleting
+	case key.Matches(msg, presetEarlier), key.Matches(msg, presetLater):
+		to := i + 1
+		if key.Matches(msg, presetEarlier) {
+			to = i - 1
+		}
+		if to >= 0 && to < m.store.Len() {
+			m.presetErr = m.store.Move(i, to)
+			m.selectedPreset += to - i
+		}
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// updatePresetName handles typing in the preset name box
+func (m Model) updatePresetName(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.presetEdit = presetBrowse
+		m.presetInput.Blur()
+		return m, nil, true
+	case tea.KeyEnter:
+		name := strings.TrimSpace(m.presetInput.Value())
+		var err error
+		if m.presetEdit == presetNaming {
+			p := m.metronome.Preset()
+			p.Name = name
+			if err = m.store.Add(p); err == nil {
+				m.selectedPreset = m.presetCount() - 1
+			}
+		} else {
+			_, i, _ := m.presetAt(m.selectedPreset)
+			err = m.store.Rename(i, name)
+		}
+		m.presetErr = err
+		if err == nil {
+			m.presetEdit = presetBrowse
+			m.presetInput.Blur()
+		}
+		return m, nil, true
+	}
+
+	var cmd tea.Cmd
+	m.presetInput, cmd = m.presetInput.Update(msg)
+	return m, cmd, true
+}
+
+// describePreset summarizes a preset's settings on one line
+func describePreset(p metronome.Preset) string {
+	line := fmt.Sprintf("%s - %d BPM (%s)", p.Name, p.BPM, p.TimeSignature.Name)
+	if p.Subdivision > 1 {
+		line += fmt.Sprintf(" · %d per beat", p.Subdivision)
+	}
+	if p.Swing > 0 {
+		line += fmt.Sprintf(" · swing %d%%", p.Swing)
+	}
+	if p.Accents != nil {
+		line += " · " + strings.ToLower(describeAccents(p.Accents))
+	}
+	return line
+}
+
+// describeAccents lists the accented beats
+func describeAccents(accents []int) string {
+	if len(accents) == 0 {
+		return "No accents"
+	}
+	beats := make([]string, len(accents))
+	for i, a := range accents {
+		beats[i] = fmt.Sprint(a)
+	}
+	return "Accents on " + strings.Join(beats, ", ")
+}
+
+// accentPatterns lists the accent patterns the accent key cycles through for
+// a bar of the given length: the downbeat, the beat groups, every beat and
+// none. Odd bars are grouped in twos ending with a three, as in 7/8.
+func accentPatterns(beats int) [][]int {
+	patterns := [][]int{nil}
+
+	var groups []int
+	switch {
+	case beats > 3 && beats%3 == 0:
+		for b := 1; b <= beats; b += 3 {
+			groups = append(groups, b)
+		}
+	case beats > 3:
+		for b := 1; b < beats-beats%2; b += 2 {
+			groups = append(groups, b)
+		}
+	}
+	if groups != nil {
+		patterns = append(patterns, groups)
+	}
+
+	every := make([]int, beats)
+	for i := range every {
+		every[i] = i + 1
+	}
+	if beats > 1 {
+		patterns = append(patterns, every)
+	}
+	return append(patterns, []int{})
+}
+
+// nextAccents returns the accent pattern after the current one
+func nextAccents(current []int, beats int) []int {
+	patterns := accentPatterns(beats)
+	for i, p := range patterns {
+		if sameAccents(p, current) {
+			return patterns[(i+1)%len(patterns)]
+		}
+	}
+	return nil
+}
+
+// sameAccents reports whether two accent patterns are the same, telling nil
+// (the downbeat) apart from empty (no accents)
+func sameAccents(a, b []int) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nextSwing returns the swing setting after the current one
+func nextSwing(current int) int {
+	for i, s := range swingSteps {
+		if s == current {
+			return swingSteps[(i+1)%len(swingSteps)]
+		}
+	}
+	return 0
+}
+
+// renderPresets renders the preset selection view
+func (m Model) renderPresets() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(2)
+
+	sectionStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		MarginTop(1)
+
+	presetStyle := lipgloss.NewStyle().
+		PaddingLeft(2).
+		PaddingRight(2)
+
+	selectedStyle := presetStyle.Copy().
+		Foreground(m.colors.highlight).
+		Background(m.colors.selected).
+		Bold(true)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(m.colors.dim)
+
+	title := titleStyle.Render("🎵 Choose Your Garden Rhythm 🎵")
+
+	// Show a window of presets around the selection on short terminals
+	visible := m.height - 16
+	if visible < 5 {
+		visible = 5
+	}
+	first := m.selectedPreset - visible/2
+	if first > m.presetCount()-visible {
+		first = m.presetCount() - visible
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	var rows []string
+	for i := first; i < m.presetCount() && i < first+visible; i++ {
+		if i == 0 {
+			rows = append(rows, sectionStyle.Render("🍄 Gnome presets"))
+		}
+		if i == len(metronome.CommonPresets) {
+			rows = append(rows, sectionStyle.Render("🌱 Your presets"))
+		}
+
+		preset, _, _ := m.presetAt(i)
+		style := presetStyle
+		if i == m.selectedPreset {
+			style = selectedStyle
+		}
+		rows = append(rows, style.Render(describePreset(preset)))
+	}
+	if m.store.Len() == 0 {
+		rows = append(rows,
+			sectionStyle.Render("🌱 Your presets"),
+			dimStyle.Render("Nothing planted yet - press n to save the current settings"))
+	}
+
+	selected, _, _ := m.presetAt(m.selectedPreset)
+	detail := dimStyle.Copy().Italic(true).MarginTop(1).Render(selected.Description)
+
+	status := ""
+	switch m.presetEdit {
+	case presetNaming:
+		status = "Name for the new preset: " + m.presetInput.View()
+	case presetRenaming:
+		status = "New name: " + m.presetInput.View()
+	case presetDeleting:
+		status = fmt.Sprintf("Press d again to delete %q, any other key to keep it", selected.Name)
+	}
+	if m.presetErr != nil {
+		status = lipgloss.JoinVertical(lipgloss.Center, status,
+			lipgloss.NewStyle().Foreground(m.colors.err).Render(m.presetErr.Error()))
+	}
+
+	instructions := dimStyle.Copy().
+		MarginTop(2).
+		Render("←/→ select · ENTER play · n save current · r rename · e update from current\n" +
+			"d delete · shift+←/→ move · P to go back")
+	if m.presetEdit == presetNaming || m.presetEdit == presetRenaming {
+		instructions = dimStyle.Copy().MarginTop(2).Render("ENTER to save, ESC to cancel")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Center,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		detail,
+		status,
+		instructions,
+	)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
@@ -0,0 +1,32 @@
+package ui
+
+import (
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// RemoteMsg tells the model that the metronome was changed from outside the
+// TUI, such as over the HTTP control API
+type RemoteMsg struct {
+	Change string // What changed, such as "bpm 132"
+	Err    error  // A remote request or sync output that failed
+}
+
+// Metronome returns the metronome the model drives, so it can be shared
+// with a remote control
+func (m Model) Metronome() *metronome.Metronome {
+	return m.metronome
+}
+
+// handleRemote shows a remote change. Everything else on screen is read from
+// the metronome, so it already reflects the change.
+func (m Model) handleRemote(msg RemoteMsg) Model {
+	m.remoteNote = msg.Change
+	if msg.Err != nil {
+		m.remoteNote = "⚠ " + msg.Err.Error()
+	}
+	if !m.metronome.IsPlaying() {
+		m.beatAnimation = 0
+		m.currentBeat = 1
+	}
+	return m
+}
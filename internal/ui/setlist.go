@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/setlist"
+)
+
+// setlistAutoKey toggles auto-advance on the setlist screen
+var setlistAutoKey = key.NewBinding(key.WithKeys("t"))
+
+// WithSetlist returns the model with a setlist loaded and its first song
+// cued up
+func (m Model) WithSetlist(s *setlist.Setlist) (Model, error) {
+	if err := s.Validate(); err != nil {
+		return m, err
+	}
+	m.setlist = s
+	return m.cueSong(0)
+}
+
+// cueSong switches to song i of the setlist, applying all of its settings
+// at once so a playing metronome restarts a single time
+func (m Model) cueSong(i int) (Model, error) {
+	song := m.setlist.Songs[i]
+	if err := m.metronome.Apply(song.Preset); err != nil {
+		return m, err
+	}
+	m.song = i
+	m.setlistSel = i
+	m.songBar = 0
+	m.beatAnimation = 0
+	m.currentBeat = 1
+	return m, m.applyBarLimit()
+}
+
+// applyBarLimit makes the metronome stop at the end of the current song when
+// auto-advance is on, so the next one can be started
+func (m Model) applyBarLimit() error {
+	bars := 0
+	if m.setlist != nil && m.setlist.AutoAdvance {
+		bars = m.setlist.Songs[m.song].Bars
+	}
+	return m.metronome.SetBarLimit(bars)
+}
+
+// stepSong moves by delta songs through the setlist, staying within it
+func (m Model) stepSong(delta int) Model {
+	if m.setlist == nil {
+		return m
+	}
+	i := m.song + delta
+	if i < 0 || i >= len(m.setlist.Songs) {
+		return m
+	}
+	m, m.setlistErr = m.cueSong(i)
+	return m
+}
+
+// advanceSetlist starts the next song once the current one has played all
+// its bars
+func (m Model) advanceSetlist() Model {
+	if m.setlist == nil || !m.metronome.Finished() || m.song >= len(m.setlist.Songs)-1 {
+		return m
+	}
+	if m, m.setlistErr = m.cueSong(m.song + 1); m.setlistErr == nil {
+		m.metronome.Start()
+	}
+	return m
+}
+
+// updateSetlist handles keys while the setlist screen is open. It reports
+// whether the key was consumed.
+func (m Model) updateSetlist(msg tea.KeyMsg) (Model, bool) {
+	if m.setlist == nil {
+		return m, false
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Left):
+		if m.setlistSel > 0 {
+			m.setlistSel--
+		}
+	case key.Matches(msg, m.keys.Right):
+		if m.setlistSel < len(m.setlist.Songs)-1 {
+			m.setlistSel++
+		}
+	case msg.Type == tea.KeyEnter:
+		m, m.setlistErr = m.cueSong(m.setlistSel)
+	case key.Matches(msg, setlistAutoKey):
+		m.setlist.AutoAdvance = !m.setlist.AutoAdvance
+		m.setlistErr = m.applyBarLimit()
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// songLength describes how long a song lasts
+func songLength(song setlist.Song) string {
+	switch song.Bars {
+	case 0:
+		return "open-ended"
+	case 1:
+		return "1 bar"
+	}
+	return fmt.Sprintf("%d bars", song.Bars)
+}
+
+// songProgress describes where playback is in the current song, for the
+// main screen
+func (m Model) songProgress() string {
+	song := m.setlist.Songs[m.song]
+	line := fmt.Sprintf("🎶 Song %d of %d: %s", m.song+1, len(m.setlist.Songs), song.Name)
+	if song.Bars > 0 && m.songBar > 0 {
+		line += fmt.Sprintf(" · bar %d of %d", m.songBar, song.Bars)
+	}
+	if m.song < len(m.setlist.Songs)-1 {
+		line += " · next: " + m.setlist.Songs[m.song+1].Name
+	} else {
+		line += " · last song"
+	}
+	return line
+}
+
+// renderSetlist renders the setlist view
+func (m Model) renderSetlist() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(2)
+
+	songStyle := lipgloss.NewStyle().
+		PaddingLeft(2).
+		PaddingRight(2)
+
+	selectedStyle := songStyle.Copy().
+		Foreground(m.colors.highlight).
+		Background(m.colors.selected).
+		Bold(true)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(m.colors.dim)
+
+	back := dimStyle.Copy().
+		MarginTop(2).
+		Render(fmt.Sprintf("%s to go back", m.keys.Songs.Help().Key))
+
+	if m.setlist == nil {
+		return lipgloss.NewStyle().
+			Width(m.width).
+			Height(m.height).
+			Align(lipgloss.Center, lipgloss.Center).
+			Render(lipgloss.JoinVertical(
+				lipgloss.Center,
+				titleStyle.Render("🎶 Tonight's Setlist 🎶"),
+				"No setlist loaded - start with metrognome tui --setlist FILE",
+				back,
+			))
+	}
+
+	title := "🎶 Tonight's Setlist 🎶"
+	if m.setlist.Name != "" {
+		title = fmt.Sprintf("🎶 %s 🎶", m.setlist.Name)
+	}
+
+	// Show a window of songs around the selection on short terminals
+	visible := m.height - 18
+	if visible < 5 {
+		visible = 5
+	}
+	first := m.setlistSel - visible/2
+	if first > len(m.setlist.Songs)-visible {
+		first = len(m.setlist.Songs) - visible
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	var rows []string
+	for i := first; i < len(m.setlist.Songs) && i < first+visible; i++ {
+		song := m.setlist.Songs[i]
+		marker := "  "
+		switch i {
+		case m.song:
+			marker = "▶ "
+		case m.song + 1:
+			marker = "» "
+		}
+
+		style := songStyle
+		if i == m.setlistSel {
+			style = selectedStyle
+		}
+		rows = append(rows, style.Render(fmt.Sprintf("%s%2d. %s · %s", marker, i+1, describePreset(song.Preset), songLength(song))))
+	}
+
+	current := m.setlist.Songs[m.song]
+	detail := lipgloss.NewStyle().MarginTop(1).Render(m.songProgress())
+	if current.Notes != "" {
+		detail = lipgloss.JoinVertical(lipgloss.Center, detail,
+			dimStyle.Copy().Italic(true).Render("📝 "+current.Notes))
+	}
+
+	auto := "Auto-advance: OFF"
+	if m.setlist.AutoAdvance {
+		auto = "Auto-advance: ON - the next song starts when this one's bars are played"
+	}
+	status := dimStyle.Copy().MarginTop(1).Render(auto)
+	if m.setlistErr != nil {
+		status = lipgloss.JoinVertical(lipgloss.Center, status,
+			lipgloss.NewStyle().Foreground(m.colors.err).Render(m.setlistErr.Error()))
+	}
+
+	instructions := dimStyle.Copy().
+		MarginTop(2).
+		Render(fmt.Sprintf("←/→ select · ENTER cue · %s/%s previous/next song · t auto-advance · %s to go back",
+			m.keys.Prev.Help().Key, m.keys.Next.Help().Key, m.keys.Songs.Help().Key))
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Center,
+		titleStyle.Render(title),
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		detail,
+		status,
+		instructions,
+	)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
@@ -0,0 +1,447 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/songtext"
+)
+
+// songMode is what the song editor is waiting for
+type songMode int
+
+const (
+	songBrowse    songMode = iota // Moving around the sections
+	songNaming                    // Typing the name of a new song
+	songRenaming                  // Typing a new name for the song
+	sectionNaming                 // Typing a new name for the selected section
+	songDeleting                  // Waiting for the delete to be confirmed
+	songImporting                 // Typing the path of a song file to import
+)
+
+// Columns of the song editor
+const (
+	fieldName = iota
+	fieldBars
+	fieldMeter
+	fieldTempo
+	fieldRamp
+	fieldCount
+)
+
+// Extra keys in the song editor
+var (
+	songMoreKey       = key.NewBinding(key.WithKeys("+", "="))
+	songLessKey       = key.NewBinding(key.WithKeys("-"))
+	songNewKey        = key.NewBinding(key.WithKeys("N"))
+	songRenameKey     = key.NewBinding(key.WithKeys("R"))
+	songDeleteKey     = key.NewBinding(key.WithKeys("D"))
+	songFreeKey       = key.NewBinding(key.WithKeys("x"))
+	songImportKey     = key.NewBinding(key.WithKeys("i"))
+	sectionNewKey     = key.NewBinding(key.WithKeys("n"))
+	sectionRenameKey  = key.NewBinding(key.WithKeys("r"))
+	sectionDeleteKey  = key.NewBinding(key.WithKeys("d"))
+	sectionEarlierKey = key.NewBinding(key.WithKeys("<"))
+	sectionLaterKey   = key.NewBinding(key.WithKeys(">"))
+)
+
+// newSongInput creates the text box used to name songs and sections
+func newSongInput() textinput.Model {
+	ti := textinput.New()
+	ti.CharLimit = 40
+	ti.Width = 30
+	return ti
+}
+
+// WithSong returns the model following a song, as if it had been chosen in
+// the song builder
+func (m Model) WithSong(song metronome.Song) (Model, error) {
+	if err := m.metronome.SetSong(&song); err != nil {
+		return m, err
+	}
+	m.section, m.sectionBar = 0, 0
+	return m, nil
+}
+
+// editedSong returns the song open in the editor, if there is one
+func (m Model) editedSong() (metronome.Song, bool) {
+	if m.songSel >= m.songStore.Len() {
+		return metronome.Song{}, false
+	}
+	return m.songStore.Songs()[m.songSel], true
+}
+
+// saveSong stores an edited song, and reloads it into the metronome if it is
+// the one being followed so the change can be heard
+func (m Model) saveSong(song metronome.Song) Model {
+	playing := m.metronome.Song()
+	old, _ := m.editedSong()
+	if m.songErr = m.songStore.Update(m.songSel, song); m.songErr != nil {
+		return m
+	}
+	if playing != nil && playing.Name == old.Name {
+		m.songErr = m.metronome.SetSong(&song)
+		m.section, m.sectionBar = 0, 0
+	}
+	return m
+}
+
+// updateSongs handles keys while the song editor is open. It reports
+// whether the key was consumed.
+func (m Model) updateSongs(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch m.songEdit {
+	case songNaming, songRenaming, sectionNaming, songImporting:
+		return m.updateSongName(msg)
+	case songDeleting:
+		m.songEdit = songBrowse
+		if key.Matches(msg, songDeleteKey) {
+			m.songErr = m.songStore.Delete(m.songSel)
+			if m.songSel > 0 && m.songSel >= m.songStore.Len() {
+				m.songSel--
+			}
+			m.sectionSel = 0
+		}
+		return m, nil, true
+	}
+
+	m.songErr = nil
+	song, ok := m.editedSong()
+	if key.Matches(msg, songNewKey) {
+		m.songEdit = songNaming
+		m.songInput.SetValue("")
+		m.songInput.Placeholder = "Name your song"
+		return m, m.songInput.Focus(), true
+	}
+	if key.Matches(msg, songImportKey) {
+		m.songEdit = songImporting
+		m.songInput.SetValue("")
+		m.songInput.Placeholder = "blues.song"
+		return m, m.songInput.Focus(), true
+	}
+	if !ok {
+		return m, nil, key.Matches(msg, m.keys.Up, m.keys.Down, m.keys.Left, m.keys.Right, m.keys.Tab)
+	}
+
+	sec := song.Sections[m.sectionSel]
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.sectionSel > 0 {
+			m.sectionSel--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.sectionSel < len(song.Sections)-1 {
+			m.sectionSel++
+		}
+	case key.Matches(msg, m.keys.Left):
+		m.songField = (m.songField + fieldCount - 1) % fieldCount
+	case key.Matches(msg, m.keys.Right):
+		m.songField = (m.songField + 1) % fieldCount
+	case key.Matches(msg, m.keys.Tab):
+		m.songSel = (m.songSel + 1) % m.songStore.Len()
+		m.sectionSel = 0
+	case key.Matches(msg, songMoreKey), key.Matches(msg, songLessKey):
+		delta := 1
+		if key.Matches(msg, songLessKey) {
+			delta = -1
+		}
+		song.Sections[m.sectionSel] = adjustSection(sec, m.songField, delta)
+		m = m.saveSong(song)
+	case key.Matches(msg, sectionRenameKey):
+		m.songEdit = sectionNaming
+		m.songInput.SetValue(sec.Name)
+		m.songInput.CursorEnd()
+		return m, m.songInput.Focus(), true
+	case msg.Type == tea.KeyEnter:
+		if m.songErr = m.metronome.SetSong(&song); m.songErr == nil {
+			m.showSongs = false
+			m.section, m.sectionBar = 0, 0
+			m.beatAnimation = 0
+			m.currentBeat = 1
+		}
+	case key.Matches(msg, songFreeKey):
+		m.songErr = m.metronome.SetSong(nil)
+	case key.Matches(msg, sectionNewKey):
+		next := sec
+		next.Name = fmt.Sprintf("Section %d", len(song.Sections)+1)
+		next.EndBPM = 0
+		if sec.EndBPM != 0 {
+			next.BPM = sec.EndBPM
+		}
+		at := m.sectionSel + 1
+		song.Sections = append(song.Sections[:at], append([]metronome.Section{next}, song.Sections[at:]...)...)
+		if m = m.saveSong(song); m.songErr == nil {
+			m.sectionSel = at
+		}
+	case key.Matches(msg, sectionDeleteKey):
+		if len(song.Sections) == 1 {
+			m.songErr = fmt.Errorf("a song needs at least one section; press %s to delete the whole song", songDeleteKey.Keys()[0])
+			break
+		}
+		song.Sections = append(song.Sections[:m.sectionSel], song.Sections[m.sectionSel+1:]...)
+		if m = m.saveSong(song); m.songErr == nil && m.sectionSel >= len(song.Sections) {
+			m.sectionSel--
+		}
+	case key.Matches(msg, sectionEarlierKey), key.Matches(msg, sectionLaterKey):
+		to := m.sectionSel + 1
+		if key.Matches(msg, sectionEarlierKey) {
+			to = m.sectionSel - 1
+		}
+		if to < 0 || to >= len(song.Sections) {
+			break
+		}
+		song.Sections[m.sectionSel], song.Sections[to] = song.Sections[to], song.Sections[m.sectionSel]
+		if m = m.saveSong(song); m.songErr == nil {
+			m.sectionSel = to
+		}
+	case key.Matches(msg, songRenameKey):
+		m.songEdit = songRenaming
+		m.songInput.SetValue(song.Name)
+		m.songInput.CursorEnd()
+		return m, m.songInput.Focus(), true
+	case key.Matches(msg, songDeleteKey):
+		m.songEdit = songDeleting
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// updateSongName handles typing in the song editor's name box
+func (m Model) updateSongName(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.songEdit = songBrowse
+		m.songInput.Blur()
+		return m, nil, true
+	case tea.KeyEnter:
+		name := strings.TrimSpace(m.songInput.Value())
+		song, _ := m.editedSong()
+		switch m.songEdit {
+		case songNaming:
+			// Start from the current settings so the song sounds familiar
+			ts := m.metronome.TimeSignature()
+			song = metronome.Song{Name: name, Sections: []metronome.Section{
+				{Name: "Intro", Bars: 4, BPM: m.metronome.BPM(), TimeSignature: ts},
+			}}
+			if m.songErr = m.songStore.Add(song); m.songErr == nil {
+				m.songSel = m.songStore.Len() - 1
+				m.sectionSel = 0
+			}
+		case songRenaming:
+			song.Name = name
+			m = m.saveSong(song)
+		case sectionNaming:
+			song.Sections[m.sectionSel].Name = name
+			m = m.saveSong(song)
+		case songImporting:
+			if song, m.songErr = songtext.Load(name); m.songErr != nil {
+				break
+			}
+			if m.songErr = m.songStore.Add(song); m.songErr == nil {
+				m.songSel = m.songStore.Len() - 1
+				m.sectionSel = 0
+			}
+		}
+		if m.songErr == nil {
+			m.songEdit = songBrowse
+			m.songInput.Blur()
+		}
+		return m, nil, true
+	}
+
+	var cmd tea.Cmd
+	m.songInput, cmd = m.songInput.Update(msg)
+	return m, cmd, true
+}
+
+// adjustSection nudges one field of a section up or down
+func adjustSection(sec metronome.Section, field, delta int) metronome.Section {
+	switch field {
+	case fieldBars:
+		sec.Bars = clamp(sec.Bars+delta, 1, metronome.MaxSectionBars)
+	case fieldMeter:
+		i := 0
+		for j, ts := range metronome.CommonTimeSignatures {
+			if ts.Beats == sec.TimeSignature.Beats && ts.BeatValue == sec.TimeSignature.BeatValue {
+				i = (j + delta + len(metronome.CommonTimeSignatures)) % len(metronome.CommonTimeSignatures)
+			}
+		}
+		sec.TimeSignature = metronome.CommonTimeSignatures[i]
+	case fieldTempo:
+		sec.BPM = clamp(sec.BPM+delta, metronome.MinBPM, metronome.MaxBPM)
+		if sec.EndBPM == sec.BPM {
+			sec.EndBPM = 0
+		}
+	case fieldRamp:
+		end := sec.EndBPM
+		if end == 0 {
+			end = sec.BPM
+		}
+		sec.EndBPM = clamp(end+delta, metronome.MinBPM, metronome.MaxBPM)
+		if sec.EndBPM == sec.BPM {
+			sec.EndBPM = 0
+		}
+	}
+	return sec
+}
+
+// clamp limits n to the range lo to hi
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// meter returns the meter being played: the current section's while a song
+// is followed
+func (m Model) meter() metronome.TimeSignature {
+	if song := m.metronome.Song(); song != nil && m.section < len(song.Sections) {
+		return song.Sections[m.section].TimeSignature
+	}
+	return m.metronome.TimeSignature()
+}
+
+// songProgressLine describes where playback is in the song being followed,
+// for the main screen
+func (m Model) songProgressLine(song *metronome.Song) string {
+	line := "🎼 " + song.Name
+	if m.section >= len(song.Sections) {
+		return line
+	}
+	sec := song.Sections[m.section]
+	line += " · " + sec.Name
+	if m.metronome.IsPlaying() && m.sectionBar > 0 {
+		left := sec.Bars - m.sectionBar + 1
+		if left == 1 {
+			line += " · last bar"
+		} else {
+			line += fmt.Sprintf(" · %d bars left", left)
+		}
+	}
+	if ramp := sec.Ramp(); ramp != "" {
+		line += " · " + ramp
+	}
+	return line
+}
+
+// renderSongs renders the song editor
+func (m Model) renderSongs() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true).
+		MarginBottom(2)
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(m.colors.title).
+		Bold(true)
+
+	rowStyle := lipgloss.NewStyle().
+		Foreground(m.colors.text)
+
+	cellStyle := lipgloss.NewStyle().
+		Foreground(m.colors.highlight).
+		Background(m.colors.selected).
+		Bold(true)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(m.colors.dim)
+
+	widths := [fieldCount]int{18, 6, 22, 7, 26}
+	cell := func(field int, text string) string {
+		return fmt.Sprintf("%-*s", widths[field], text)
+	}
+
+	song, ok := m.editedSong()
+	title := titleStyle.Render("🎼 Song Builder 🎼")
+
+	var body string
+	if !ok {
+		body = dimStyle.Render("No songs yet - press N to start one from the current settings, or i to import a song file")
+	} else {
+		heading := fmt.Sprintf("%s  (song %d of %d · %d bars)", song.Name, m.songSel+1, m.songStore.Len(), song.Bars())
+		if playing := m.metronome.Song(); playing != nil && playing.Name == song.Name {
+			heading += " · following"
+		}
+
+		rows := []string{
+			headerStyle.Render(heading),
+			"",
+			headerStyle.Render(cell(fieldName, "Section") + cell(fieldBars, "Bars") + cell(fieldMeter, "Meter") +
+				cell(fieldTempo, "BPM") + cell(fieldRamp, "Ramp")),
+		}
+		for i, sec := range song.Sections {
+			ramp := sec.Ramp()
+			if ramp == "" {
+				ramp = "steady"
+			}
+			cells := [fieldCount]string{
+				cell(fieldName, sec.Name),
+				cell(fieldBars, fmt.Sprint(sec.Bars)),
+				cell(fieldMeter, sec.TimeSignature.Name),
+				cell(fieldTempo, fmt.Sprint(sec.BPM)),
+				cell(fieldRamp, ramp),
+			}
+
+			var row string
+			for field, text := range cells {
+				if i == m.sectionSel && field == m.songField {
+					row += cellStyle.Render(text)
+				} else {
+					row += rowStyle.Render(text)
+				}
+			}
+			rows = append(rows, row)
+		}
+		body = lipgloss.JoinVertical(lipgloss.Left, rows...)
+	}
+
+	status := ""
+	switch m.songEdit {
+	case songNaming:
+		status = "Name for the new song: " + m.songInput.View()
+	case songRenaming:
+		status = "New song name: " + m.songInput.View()
+	case sectionNaming:
+		status = "New section name: " + m.songInput.View()
+	case songDeleting:
+		status = fmt.Sprintf("Press D again to delete %q, any other key to keep it", song.Name)
+	case songImporting:
+		status = "Song file to import: " + m.songInput.View()
+	}
+	if m.songErr != nil {
+		status = lipgloss.JoinVertical(lipgloss.Center, status,
+			lipgloss.NewStyle().Foreground(m.colors.err).Render(m.songErr.Error()))
+	}
+
+	instructions := dimStyle.Copy().
+		MarginTop(2).
+		Render("↑/↓ section · ←/→ column · +/- adjust · ENTER play the song\n" +
+			"n add section · r rename · d delete · </> move · x back to free tempo\n" +
+			fmt.Sprintf("N new song · i import a song file · R rename song · D delete song · Tab next song · %s to go back", m.keys.Editor.Help().Key))
+	if m.songEdit != songBrowse && m.songEdit != songDeleting {
+		instructions = dimStyle.Copy().MarginTop(2).Render("ENTER to save, ESC to cancel")
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Center,
+		title,
+		body,
+		status,
+		instructions,
+	)
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+}
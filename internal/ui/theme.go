@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// palette is the set of colors a theme paints the garden with
+type palette struct {
+	title     lipgloss.Color // Titles and table headers
+	highlight lipgloss.Color // Selected items and the BPM
+	selected  lipgloss.Color // Background of selected items
+	text      lipgloss.Color
+	dim       lipgloss.Color // Hints and instructions
+	faint     lipgloss.Color
+	err       lipgloss.Color
+	border    lipgloss.Color
+	downbeat  lipgloss.Color // Gnome lit on the first beat
+	beat      lipgloss.Color // Gnome lit on every other beat
+	idle      lipgloss.Color // Gnomes and beats waiting their turn
+	bright    lipgloss.Color
+	// stars go from the dimmest, shown while stopped, to the brightest,
+	// flashed on the downbeat
+	stars []lipgloss.Color
+}
+
+// themes are the palettes that can be picked in the config file
+var themes = map[string]palette{
+	"garden": {
+		title: "86", highlight: "212", selected: "236", text: "252",
+		dim: "241", faint: "244", err: "203", border: "226",
+		downbeat: "51", beat: "226", idle: "240", bright: "231",
+		stars: []lipgloss.Color{"240", "244", "250", "254", "230", "226", "222", "86", "212", "231"},
+	},
+	"moonlight": {
+		title: "111", highlight: "183", selected: "236", text: "252",
+		dim: "241", faint: "244", err: "203", border: "69",
+		downbeat: "159", beat: "183", idle: "240", bright: "231",
+		stars: []lipgloss.Color{"236", "238", "60", "61", "67", "104", "111", "147", "183", "231"},
+	},
+	"mono": {
+		title: "255", highlight: "255", selected: "239", text: "252",
+		dim: "244", faint: "246", err: "255", border: "250",
+		downbeat: "255", beat: "250", idle: "238", bright: "255",
+		stars: []lipgloss.Color{"236", "238", "240", "242", "244", "246", "248", "250", "252", "255"},
+	},
+}
+
+// themeNames lists the themes in alphabetical order
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupTheme returns the palette for a theme name
+func lookupTheme(name string) (palette, error) {
+	p, ok := themes[name]
+	if !ok {
+		return palette{}, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(themeNames(), ", "))
+	}
+	return p, nil
+}
@@ -1,19 +1,188 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strings"
 
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/drj613/metrognome/internal/ui"
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/songtext"
 )
 
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// command is a metrognome subcommand
+type command struct {
+	name    string
+	args    string // Synopsis of the positional arguments
+	summary string
+	run     func(cmd *command, args []string) int
+}
+
+// commands returns every subcommand in the order they are listed in help
+func commands() []*command {
+	return []*command{
+		{name: "tui", summary: "Open the full-screen garden metronome (the default)", run: runTUI},
+		{name: "play", summary: "Play clicks without the full-screen interface", run: runPlay},
+		{name: "render", summary: "Render a click track to a WAV file", run: runRender},
+		{name: "serve", summary: "Play under the control of a local HTTP API", run: runServe},
+		{name: "ctl", args: "COMMAND [ARG...]", summary: "Send a command (status, start, stop, toggle, bpm, sig, preset) to a running metronome", run: runCtl},
+		{name: "presets", args: "[list | import FILE | export [NAME...]]", summary: "List, import and export preset rhythms", run: runPresets},
+		{name: "tap", summary: "Work out a tempo by tapping Enter", run: runTap},
+		{name: "version", summary: "Print the version", run: runVersion},
+	}
+}
+
 func main() {
-	fmt.Println("🎩 Welcome to Metrognome - Where Every Beat is Garden Fresh! 🌱")
-	fmt.Println()
+	args := os.Args[1:]
+
+	// With no subcommand (or only flags) open the TUI
+	name := "tui"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		name, args = "help", args[1:]
+	}
+
+	if name == "help" {
+		os.Exit(runHelp(args))
+	}
 
-	p := tea.NewProgram(ui.NewModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("could not start the garden metronome: %v", err)
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			os.Exit(cmd.run(cmd, args))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "metrognome: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+// runHelp prints the overall usage, or the usage of a single command
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(cmd, []string{"--help"})
+		}
+	}
+	fmt.Fprintf(os.Stderr, "metrognome help: unknown command %q\n", args[0])
+	return exitUsage
+}
+
+// printUsage lists every subcommand
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "🍄 Metrognome - a garden-fresh terminal metronome")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: metrognome [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'metrognome help <command>' or 'metrognome <command> --help' for details.")
+}
+
+// flags creates the flag set for a command with a usage message that
+// matches every other command
+func (c *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: metrognome %s [flags]", c.name)
+		if c.args != "" {
+			fmt.Fprintf(out, " %s", c.args)
+		}
+		fmt.Fprintf(out, "\n\n%s\n", c.summary)
+
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(out, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parse parses a command's flags. If it returns false the command should
+// exit with the returned code: 0 after --help, 2 for bad flags.
+func (c *command) parse(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// fail reports an error and returns the exit code for it
+func (c *command) fail(err error) int {
+	fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", c.name, err)
+	return exitError
+}
+
+// usageError reports invalid arguments and returns the exit code for them
+func (c *command) usageError(err error) int {
+	fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", c.name, err)
+	fmt.Fprintf(os.Stderr, "Run 'metrognome %s --help' for usage.\n", c.name)
+	return exitUsage
+}
+
+// tempoFlags are the flags shared by every command that plays or renders
+type tempoFlags struct {
+	bpm         int
+	sig         string
+	subdivision int
+	song        string
+}
+
+// addTempoFlags registers the tempo flags on a flag set
+func addTempoFlags(fs *flag.FlagSet) *tempoFlags {
+	t := &tempoFlags{}
+	fs.IntVar(&t.bpm, "bpm", 120, "tempo in beats per minute")
+	fs.StringVar(&t.sig, "sig", "4/4", "time signature, e.g. 7/8")
+	fs.IntVar(&t.subdivision, "subdivision", 1, "clicks per beat (1-4)")
+	fs.StringVar(&t.song, "song", "", "song file whose sections set the meter and tempo instead")
+	return t
+}
+
+// metronome builds a metronome from the flags, validated by the engine
+func (t *tempoFlags) metronome() (*metronome.Metronome, error) {
+	ts, err := metronome.ParseTimeSignature(t.sig)
+	if err != nil {
+		return nil, err
+	}
+
+	m := metronome.New(metronome.MinBPM, ts)
+	if err := m.SetBPM(t.bpm); err != nil {
+		return nil, err
+	}
+	if err := m.SetSubdivision(t.subdivision); err != nil {
+		return nil, err
+	}
+	if t.song != "" {
+		song, err := songtext.Load(t.song)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.SetSong(&song); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.song, err)
+		}
 	}
+	return m, nil
 }
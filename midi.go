@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/midi"
+)
+
+// midiFlags are the flags of commands that can send MIDI clock
+type midiFlags struct {
+	out string
+	in  string
+}
+
+// addMIDIFlags registers the MIDI flags on a flag set
+func addMIDIFlags(fs *flag.FlagSet) *midiFlags {
+	m := &midiFlags{}
+	fs.StringVar(&m.out, "midi-out", "", "raw MIDI device, FIFO or file to send MIDI clock and transport to, e.g. /dev/snd/midiC1D0")
+	return m
+}
+
+// addInFlag registers the flag for following an incoming MIDI clock
+func (m *midiFlags) addInFlag(fs *flag.FlagSet) {
+	fs.StringVar(&m.in, "midi-in", "", "raw MIDI device or FIFO whose MIDI clock sets the tempo and starts and stops playback")
+}
+
+// start follows and sends MIDI clock as the flags ask, returning a function
+// that stops both once followers have been sent Stop
+func (m *midiFlags) start(metro *metronome.Metronome, onError func(error)) (func(), error) {
+	stopIn := m.follow(metro, onError)
+	if m.out == "" {
+		return stopIn, nil
+	}
+
+	out, err := midi.OpenFile(m.out)
+	if err != nil {
+		stopIn()
+		return nil, err
+	}
+	clock := midi.NewClock(metro, out)
+	clock.OnError = onError
+
+	beats, unsubscribe := metro.Subscribe()
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		clock.Run(beats, stop)
+	}()
+	return func() {
+		close(stop)
+		<-done
+		unsubscribe()
+		out.Close()
+		stopIn()
+	}, nil
+}
+
+// follow puts the metronome under the control of the incoming MIDI clock,
+// if there is one. The input is opened in the background, since a FIFO
+// waits for something to write to it.
+func (m *midiFlags) follow(metro *metronome.Metronome, onError func(error)) func() {
+	if m.in == "" {
+		return func() {}
+	}
+
+	follower := midi.NewFollower(metro)
+	var mu sync.Mutex
+	var in *os.File
+	closed := false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			f, err := os.Open(m.in)
+			if err != nil {
+				onError(err)
+				return
+			}
+			mu.Lock()
+			if closed {
+				mu.Unlock()
+				f.Close()
+				return
+			}
+			in = f
+			mu.Unlock()
+
+			err = follower.Run(f)
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			if err != nil {
+				onError(fmt.Errorf("reading midi clock: %w", err))
+				return
+			}
+			// A FIFO ends when its writer goes away; wait for the next one
+			info, err := f.Stat()
+			mu.Lock()
+			in = nil
+			mu.Unlock()
+			f.Close()
+			if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+				onError(fmt.Errorf("midi clock input %s ended", m.in))
+				return
+			}
+		}
+	}()
+	return func() {
+		mu.Lock()
+		closed = true
+		opening := in == nil
+		if in != nil {
+			in.Close()
+		}
+		mu.Unlock()
+		if opening {
+			wakeFIFO(m.in, done)
+		}
+	}
+}
+
+// wakeFIFO frees a goroutine blocked opening a FIFO that has no writer, by
+// briefly opening it for writing, until done is closed. Anything but a FIFO
+// is left alone.
+func wakeFIFO(path string, done <-chan struct{}) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		return
+	}
+	for i := 0; i < 20; i++ {
+		// Fails with no reader yet, so keep trying until the opener is in
+		if w, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
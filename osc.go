@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"net"
+
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/osc"
+)
+
+// oscFlags are the flags of commands that can talk OSC
+type oscFlags struct {
+	listen string
+	send   string
+}
+
+// addOSCFlags registers the OSC flags on a flag set
+func addOSCFlags(fs *flag.FlagSet) *oscFlags {
+	o := &oscFlags{}
+	fs.StringVar(&o.listen, "osc", "", "UDP address to accept OSC commands on, e.g. :9000")
+	fs.StringVar(&o.send, "osc-send", "", "UDP address to send an OSC message to on every beat, e.g. 192.168.1.20:8000")
+	return o
+}
+
+// start listens for OSC commands and sends beats as the flags ask, returning
+// a function that stops both
+func (o *oscFlags) start(metro *metronome.Metronome, onChange func(string), onError func(error)) (func(), error) {
+	srv := osc.NewServer(metro)
+	srv.OnChange = onChange
+	srv.OnError = onError
+
+	var closers []func()
+	stop := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	if o.listen != "" {
+		conn, err := net.ListenPacket("udp", o.listen)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, func() { conn.Close() })
+		go func() {
+			if err := srv.Serve(conn); err != nil {
+				onError(err)
+			}
+		}()
+	}
+
+	if o.send != "" {
+		conn, err := net.Dial("udp", o.send)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		done := make(chan struct{})
+		closers = append(closers, func() {
+			close(done)
+			conn.Close()
+		})
+		go srv.SendBeats(conn, done)
+	}
+	return stop, nil
+}
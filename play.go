@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/drj613/metrognome/internal/audio"
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/stream"
+)
+
+// playOptions holds the parsed flags of the play command
+type playOptions struct {
+	bars     int
+	duration time.Duration
+	stream   *stream.Writer // Where to write each beat, if anywhere
+}
+
+// soundFlags are the flags shared by commands that make sound
+type soundFlags struct {
+	voice bool
+	kit   string
+}
+
+// addSoundFlags registers the sound flags on a flag set
+func addSoundFlags(fs *flag.FlagSet) *soundFlags {
+	s := &soundFlags{}
+	fs.BoolVar(&s.voice, "voice", false, "count out loud instead of clicking")
+	fs.StringVar(&s.kit, "kit", "", "directory of a click kit to use instead of the built-in one")
+	return s
+}
+
+// player builds an audio player from the flags
+func (s *soundFlags) player() (*audio.Player, error) {
+	kit := audio.DefaultKit()
+	if s.kit != "" {
+		k, err := audio.LoadKit(s.kit)
+		if err != nil {
+			return nil, err
+		}
+		kit = k
+	}
+
+	player := audio.NewPlayer(kit)
+	// The bell would end up in whatever stdout is piped to
+	player.SetBell(nil)
+	if s.voice {
+		if err := player.SetSoundSet(audio.SoundVoice); err != nil {
+			player.Close()
+			return nil, err
+		}
+	}
+	return player, nil
+}
+
+// runPlay plays clicks without the TUI until the requested number of bars or
+// duration has passed, or until interrupted
+func runPlay(cmd *command, args []string) int {
+	var opts playOptions
+	fs := cmd.flags()
+	tempo := addTempoFlags(fs)
+	sound := addSoundFlags(fs)
+	fs.IntVar(&opts.bars, "bars", 0, "stop after this many bars (0 plays forever)")
+	fs.DurationVar(&opts.duration, "duration", 0, "stop after this long, e.g. 10m (0 plays forever)")
+	format := fs.String("stream", "", "write each beat as it sounds, as json, text or bars lines")
+	streamFile := fs.String("stream-file", "-", "file to write the beat stream to, or - for stdout")
+	mute := fs.Bool("mute", false, "play silently, e.g. when only the beat stream is wanted")
+	countIn := fs.Int("count-in", 0, "bars to count in before the first bar")
+	midiOpts := addMIDIFlags(fs)
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return cmd.usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if opts.bars < 0 || opts.duration < 0 {
+		return cmd.usageError(errors.New("--bars and --duration can't be negative"))
+	}
+
+	metro, err := tempo.metronome()
+	if err != nil {
+		return cmd.usageError(err)
+	}
+	if err := metro.SetCountIn(*countIn); err != nil {
+		return cmd.usageError(err)
+	}
+
+	// Keep stdout clean for the stream when it is written there
+	status := io.Writer(os.Stdout)
+	var file *os.File
+	if *format != "" {
+		f, err := stream.ParseFormat(*format)
+		if err != nil {
+			return cmd.usageError(err)
+		}
+		out := io.Writer(os.Stdout)
+		if *streamFile != "-" {
+			file, err = os.Create(*streamFile)
+			if err != nil {
+				return cmd.fail(err)
+			}
+			out = file
+		} else {
+			status = os.Stderr
+		}
+		opts.stream = stream.NewWriter(out, f)
+	}
+
+	stopMIDI, err := midiOpts.start(metro, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopMIDI()
+
+	code := playback(cmd, metro, sound, *mute, opts, status)
+	// The stream isn't safely in the file until it is closed
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return cmd.fail(err)
+		}
+	}
+	return code
+}
+
+// playback sets up sound for a metronome and plays it with the given
+// options, printing the status line to status. It returns the exit code.
+func playback(cmd *command, metro *metronome.Metronome, sound *soundFlags, mute bool, opts playOptions, status io.Writer) int {
+	closeSound, err := startSound(cmd, metro, sound, mute)
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer closeSound()
+
+	stopHooks, err := startHooks(metro, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopHooks()
+
+	fmt.Fprintln(status, playStatus(metro, opts))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	return play(metro, opts, signals, os.Stderr)
+}
+
+// startSound makes a metronome audible with the configured latency, warning
+// if there is nothing to play sound with. The returned function silences it.
+func startSound(cmd *command, metro *metronome.Metronome, sound *soundFlags, mute bool) (func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	player, err := sound.player()
+	if err != nil {
+		return nil, err
+	}
+	if !player.Available() && !mute {
+		fmt.Fprintf(os.Stderr, "metrognome %s: no audio player found (install paplay or aplay), playing silently\n", cmd.name)
+	}
+
+	offset := cfg.LatencyOffset()
+	if offset < 0 {
+		offset = 0
+	}
+	metro.SetLookAhead(cfg.LookAhead() + offset)
+	scheduler := audio.NewScheduler(player, metro)
+	scheduler.SetOffset(offset)
+	scheduler.SetEnabled(!mute)
+
+	return func() {
+		scheduler.Close()
+		player.Close()
+	}, nil
+}
+
+// play runs the metronome until it is done or a signal arrives, returning the
+// exit code
+func play(metro *metronome.Metronome, opts playOptions, signals <-chan os.Signal, errOut io.Writer) int {
+	// The engine stops at the bar limit itself, once the final bar has rung
+	// out, so none of its clicks are cut off
+	if err := metro.SetBarLimit(opts.bars); err != nil {
+		fmt.Fprintf(errOut, "metrognome play: %v\n", err)
+		return exitError
+	}
+
+	var deadline <-chan time.Time
+	if opts.duration > 0 {
+		timer := time.NewTimer(opts.duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var streamErr <-chan error
+	stopStream := func() {}
+	if opts.stream != nil {
+		// Subscribe before starting so the first beat isn't missed
+		beats, unsubscribe := metro.Subscribe()
+		defer unsubscribe()
+
+		stop, done := make(chan struct{}), make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			defer close(done)
+			if err := opts.stream.Follow(metro, beats, stop); err != nil {
+				errs <- err
+			}
+		}()
+		// Let the stream finish its last line before returning
+		var once sync.Once
+		stopStream = func() {
+			once.Do(func() {
+				close(stop)
+				<-done
+				opts.stream.Flush()
+			})
+		}
+		defer stopStream()
+		streamErr = errs
+	}
+
+	metro.Start()
+	defer metro.Stop()
+	stopped := metro.Stopped()
+
+	for {
+		select {
+		case sig := <-signals:
+			metro.Stop()
+			stopStream()
+			fmt.Fprintln(errOut)
+			return exitCodeFor(sig)
+
+		case <-deadline:
+			return exitOK
+
+		case <-stopped:
+			// A song has played to its end, or the last of the bars
+			return exitOK
+
+		case err := <-streamErr:
+			metro.Stop()
+			fmt.Fprintf(errOut, "metrognome play: writing the beat stream: %v\n", err)
+			return exitError
+		}
+	}
+}
+
+// playStatus describes what the play command is doing in one line
+func playStatus(metro *metronome.Metronome, opts playOptions) string {
+	status := fmt.Sprintf("🎩 Playing %d BPM in %s", metro.BPM(), metro.TimeSignature().Name)
+	if song := metro.Song(); song != nil {
+		status = fmt.Sprintf("🎩 Playing %s (%d sections, %d bars)", song.Name, len(song.Sections), song.Bars())
+		if opts.bars == 0 && opts.duration == 0 {
+			return status + " (Ctrl+C to stop)"
+		}
+	}
+	switch {
+	case opts.bars > 0 && opts.duration > 0:
+		status += fmt.Sprintf(" for %d bars or %s", opts.bars, opts.duration)
+	case opts.bars > 0:
+		status += fmt.Sprintf(" for %d bars", opts.bars)
+	case opts.duration > 0:
+		status += fmt.Sprintf(" for %s", opts.duration)
+	default:
+		status += " until interrupted"
+	}
+	return status + " (Ctrl+C to stop)"
+}
+
+// exitCodeFor returns the conventional exit code for dying of a signal
+func exitCodeFor(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return exitError
+}
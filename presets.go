@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/presets"
+)
+
+// presetCommands are the actions of the presets command
+var presetCommands = []*command{
+	{name: "presets list", summary: "List the built-in preset rhythms and your own", run: runPresetsList},
+	{name: "presets import", args: "FILE", summary: "Add the presets in a shared preset file (- for stdin) to your own", run: runPresetsImport},
+	{name: "presets export", args: "[NAME...]", summary: "Write your presets, or the named ones, to a shared preset file", run: runPresetsExport},
+}
+
+// runPresets lists, imports or exports presets
+func runPresets(cmd *command, args []string) int {
+	switch {
+	case len(args) == 0:
+		return runPresetsList(presetCommands[0], args)
+	case args[0] == "-h" || args[0] == "--help":
+		fmt.Printf("Usage: metrognome presets %s\n\n%s\n\nActions:\n", cmd.args, cmd.summary)
+		for _, sub := range presetCommands {
+			fmt.Printf("  %-8s %s\n", strings.TrimPrefix(sub.name, "presets "), sub.summary)
+		}
+		fmt.Println("\nRun 'metrognome presets <action> --help' for details.")
+		return exitOK
+	case strings.HasPrefix(args[0], "-"):
+		return runPresetsList(presetCommands[0], args)
+	}
+
+	for _, sub := range presetCommands {
+		if sub.name == "presets "+args[0] {
+			return sub.run(sub, args[1:])
+		}
+	}
+	return cmd.usageError(fmt.Errorf("unknown action %q, expected list, import or export", args[0]))
+}
+
+// runPresetsList lists the built-in preset rhythms and the user's own
+func runPresetsList(cmd *command, args []string) int {
+	fs := cmd.flags()
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+
+	store, err := presets.Load()
+	if err != nil {
+		return cmd.fail(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBPM\tMETER\tFEEL\tDESCRIPTION")
+	for _, p := range metronome.CommonPresets {
+		printPreset(w, p)
+	}
+	if store.Len() > 0 {
+		fmt.Fprintln(w, "\t\t\t\t")
+		for _, p := range store.Presets() {
+			printPreset(w, p)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return cmd.fail(err)
+	}
+	return exitOK
+}
+
+// printPreset writes one row of the preset table
+func printPreset(w *tabwriter.Writer, p metronome.Preset) {
+	var feel []string
+	if p.Subdivision > 1 {
+		feel = append(feel, fmt.Sprintf("%d/beat", p.Subdivision))
+	}
+	if p.Swing > 0 {
+		feel = append(feel, fmt.Sprintf("swing %d%%", p.Swing))
+	}
+	if len(feel) == 0 {
+		feel = append(feel, "-")
+	}
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.Name, p.BPM, p.TimeSignature, strings.Join(feel, " "), p.Description)
+}
+
+// runPresetsImport adds the presets in a shared file to the user's own
+func runPresetsImport(cmd *command, args []string) int {
+	fs := cmd.flags()
+	mode := fs.String("duplicates", "skip", "what to do with presets whose name is taken: skip, replace or rename")
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return cmd.usageError(errors.New("expected one preset file to import"))
+	}
+	dup, err := presets.ParseDuplicates(*mode)
+	if err != nil {
+		return cmd.usageError(err)
+	}
+
+	path := fs.Arg(0)
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	incoming, err := presets.Decode(r)
+	if err != nil {
+		return cmd.fail(fmt.Errorf("%s: %w", path, err))
+	}
+
+	store, err := presets.Load()
+	if err != nil {
+		return cmd.fail(err)
+	}
+	result, err := store.Import(incoming, dup)
+	if err != nil {
+		return cmd.fail(err)
+	}
+
+	report := func(verb string, names []string) {
+		for _, name := range names {
+			fmt.Printf("  %s %s\n", verb, name)
+		}
+	}
+	report("added   ", result.Added)
+	report("replaced", result.Replaced)
+	report("renamed ", result.Renamed)
+	report("skipped ", result.Skipped)
+	fmt.Printf("🌱 Planted %d of %d presets", len(result.Added)+len(result.Replaced)+len(result.Renamed), len(incoming))
+	if len(result.Skipped) > 0 {
+		fmt.Printf(" (%d already taken; use --duplicates replace or rename)", len(result.Skipped))
+	}
+	fmt.Println()
+	return exitOK
+}
+
+// runPresetsExport writes presets to a shared file
+func runPresetsExport(cmd *command, args []string) int {
+	fs := cmd.flags()
+	out := fs.String("o", "-", "file to write, or - for stdout")
+	builtin := fs.Bool("builtin", false, "include the built-in gnome presets")
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+
+	store, err := presets.Load()
+	if err != nil {
+		return cmd.fail(err)
+	}
+	available := store.Presets()
+	if *builtin {
+		available = append(append([]metronome.Preset(nil), metronome.CommonPresets...), available...)
+	}
+
+	chosen := available
+	if fs.NArg() > 0 {
+		chosen = nil
+		for _, name := range fs.Args() {
+			p, ok := findPreset(available, name)
+			if !ok {
+				return cmd.usageError(fmt.Errorf("no preset called %q", name))
+			}
+			chosen = append(chosen, p)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *out != "-" {
+		f, err = os.Create(*out)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		w = f
+	}
+	err = presets.Encode(w, chosen)
+	// The presets aren't safely in the file until it is closed
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return cmd.fail(err)
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "🎵 Exported %d presets to %s\n", len(chosen), *out)
+	}
+	return exitOK
+}
+
+// findPreset looks up a preset by name, ignoring case
+func findPreset(list []metronome.Preset, name string) (metronome.Preset, bool) {
+	for _, p := range list {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return metronome.Preset{}, false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runRender writes a click track to a WAV file
+func runRender(cmd *command, args []string) int {
+	fs := cmd.flags()
+	tempo := addTempoFlags(fs)
+	sound := addSoundFlags(fs)
+	bars := fs.Int("bars", 0, "number of bars to render (default 4, or the whole --song)")
+	out := fs.String("o", "", "output WAV file, or - for stdout (required)")
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return cmd.usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if *out == "" {
+		return cmd.usageError(errors.New("an output file is required (-o click.wav)"))
+	}
+	if *bars < 0 {
+		return cmd.usageError(fmt.Errorf("--bars must be at least 1, got %d", *bars))
+	}
+
+	metro, err := tempo.metronome()
+	if err != nil {
+		return cmd.usageError(err)
+	}
+	song := metro.Song()
+	if *bars == 0 {
+		*bars = 4
+		if song != nil {
+			*bars = song.Bars()
+		}
+	}
+
+	player, err := sound.player()
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer player.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var start time.Time
+	beats, end := metro.Plan(start, *bars)
+	length := end.Sub(start)
+	if err := player.Render(w, beats, start, length); err != nil {
+		return cmd.fail(err)
+	}
+
+	if *out != "-" {
+		what := fmt.Sprintf("%d BPM in %s", metro.BPM(), metro.TimeSignature().Name)
+		if song != nil {
+			what = fmt.Sprintf("of %s", song.Name)
+			*bars = min(*bars, song.Bars())
+		}
+		fmt.Fprintf(os.Stderr, "🎵 Rendered %d bars %s to %s (%s)\n",
+			*bars, what, *out, length.Round(time.Millisecond))
+	}
+	return exitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/drj613/metrognome/internal/remote"
+)
+
+// runServe plays the metronome under the control of the HTTP API, without
+// the TUI, until interrupted
+func runServe(cmd *command, args []string) int {
+	fs := cmd.flags()
+	tempo := addTempoFlags(fs)
+	sound := addSoundFlags(fs)
+	listen := fs.String("listen", remote.DefaultAddr, "address to serve the control API on")
+	oscOpts := addOSCFlags(fs)
+	midiOpts := addMIDIFlags(fs)
+	midiOpts.addInFlag(fs)
+	syncOpts := addSyncFlags(fs)
+	controlOpts := addControlFlags(fs)
+	mute := fs.Bool("mute", false, "stay silent, e.g. when only the API is wanted")
+	start := fs.Bool("start", false, "start playing straight away instead of waiting for /start")
+	countIn := fs.Int("count-in", 0, "bars to count in before the first bar")
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return cmd.usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if err := syncOpts.check(midiOpts); err != nil {
+		return cmd.usageError(err)
+	}
+
+	metro, err := tempo.metronome()
+	if err != nil {
+		return cmd.usageError(err)
+	}
+	if err := metro.SetCountIn(*countIn); err != nil {
+		return cmd.usageError(err)
+	}
+
+	closeSound, err := startSound(cmd, metro, sound, *mute)
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer closeSound()
+
+	api := remote.New(metro)
+	api.OnChange = func(change string) {
+		fmt.Printf("🎩 %s\n", change)
+	}
+	addr, shutdown, err := serveRemote(api, *listen)
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer shutdown()
+
+	stopOSC, err := oscOpts.start(metro, api.OnChange, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: osc: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopOSC()
+
+	stopMIDI, err := midiOpts.start(metro, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopMIDI()
+
+	stopSync, err := syncOpts.start(metro, api.OnChange, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopSync()
+
+	stopHooks, err := startHooks(metro, func(err error) {
+		fmt.Fprintf(os.Stderr, "metrognome %s: %v\n", cmd.name, err)
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopHooks()
+
+	// Another metronome may have the socket already; the API still works
+	stopControl, err := controlOpts.start(metro, api.OnChange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "metrognome %s: control socket: %v\n", cmd.name, err)
+	} else {
+		defer stopControl()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	fmt.Printf("🎩 Serving the metronome at http://%s (Ctrl+C to stop)\n", addr)
+	if *start {
+		metro.Start()
+	}
+	defer metro.Stop()
+
+	sig := <-signals
+	fmt.Fprintln(os.Stderr)
+	return exitCodeFor(sig)
+}
+
+// serveRemote serves the control API on addr in the background, returning
+// the listening address and a function that shuts it down
+func serveRemote(api *remote.Server, addr string) (net.Addr, func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := &http.Server{Handler: api, ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+	return ln.Addr(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
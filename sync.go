@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/drj613/metrognome/internal/lansync"
+	"github.com/drj613/metrognome/internal/link"
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// syncFlags are the flags for keeping metronomes on a network in step
+type syncFlags struct {
+	lead   string
+	follow string
+	link   bool
+}
+
+// addSyncFlags registers the sync flags on a flag set
+func addSyncFlags(fs *flag.FlagSet) *syncFlags {
+	s := &syncFlags{}
+	fs.StringVar(&s.lead, "sync-lead", "", "lead other metronomes, sending to a multicast group or one follower, e.g. "+lansync.DefaultGroup)
+	fs.StringVar(&s.follow, "sync-follow", "", "follow a leader, listening on a multicast group or local address, e.g. "+lansync.DefaultGroup)
+	fs.BoolVar(&s.link, "link", false, "join or start an Ableton Link session, sharing tempo and lining up bars")
+	return s
+}
+
+// check rejects flags that would have two clocks in charge at once
+func (s *syncFlags) check(midi *midiFlags) error {
+	if s.lead != "" && s.follow != "" {
+		return errors.New("--sync-lead and --sync-follow can't be used together")
+	}
+	if s.follow != "" && midi.in != "" {
+		return errors.New("--sync-follow and --midi-in can't be used together")
+	}
+	if s.link && (s.follow != "" || midi.in != "") {
+		return errors.New("--link can't be used with --sync-follow or --midi-in")
+	}
+	return nil
+}
+
+// start leads, follows or links as the flags ask, returning a function that
+// stops
+func (s *syncFlags) start(metro *metronome.Metronome, onChange func(string), onError func(error)) (func(), error) {
+	var closers []func()
+	stop := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	// run runs a task until stopped, waiting for it to finish
+	run := func(task func(stop <-chan struct{})) {
+		done, quit := make(chan struct{}), make(chan struct{})
+		closers = append(closers, func() {
+			close(quit)
+			<-done
+		})
+		go func() {
+			defer close(done)
+			task(quit)
+		}()
+	}
+
+	switch {
+	case s.lead != "":
+		leader, err := lansync.NewLeader(metro, s.lead)
+		if err != nil {
+			return nil, err
+		}
+		leader.OnError = onError
+		beats, unsubscribe := metro.Subscribe()
+		run(func(stop <-chan struct{}) {
+			defer unsubscribe()
+			leader.Run(beats, stop)
+		})
+
+	case s.follow != "":
+		follower, err := lansync.NewFollower(metro, s.follow)
+		if err != nil {
+			return nil, err
+		}
+		follower.OnChange = onChange
+		run(follower.Run)
+	}
+
+	if s.link {
+		peer, err := link.NewPeer(metro)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		peer.OnChange = onChange
+		peer.OnError = onError
+		run(peer.Run)
+	}
+	return stop, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/drj613/metrognome/internal/metronome"
+)
+
+// runTap works out a tempo from Enter presses on stdin
+func runTap(cmd *command, args []string) int {
+	fs := cmd.flags()
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+
+	fmt.Println("🥁 Tap Enter in time with the music, then type q and Enter (or Ctrl+D) to finish.")
+
+	var tapper metronome.TapTempo
+	bpm, ok := 0, false
+	scanner := bufio.NewScanner(os.Stdin)
+	for taps := 1; scanner.Scan(); taps++ {
+		if strings.TrimSpace(scanner.Text()) == "q" {
+			break
+		}
+		if bpm, ok = tapper.Tap(time.Now()); ok {
+			fmt.Printf("  %d BPM - %s\n", bpm, metronome.GetBPMDescription(bpm))
+		} else {
+			fmt.Println("  keep tapping...")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cmd.fail(err)
+	}
+
+	if !ok {
+		fmt.Println("Not enough taps to find a tempo.")
+		return exitError
+	}
+	fmt.Printf("♩ = %d\n", bpm)
+	return exitOK
+}
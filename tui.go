@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drj613/metrognome/internal/audio"
+	"github.com/drj613/metrognome/internal/config"
+	"github.com/drj613/metrognome/internal/metronome"
+	"github.com/drj613/metrognome/internal/remote"
+	"github.com/drj613/metrognome/internal/setlist"
+	"github.com/drj613/metrognome/internal/songtext"
+	"github.com/drj613/metrognome/internal/stream"
+	"github.com/drj613/metrognome/internal/ui"
+	"golang.org/x/term"
+)
+
+// runTUI opens the full-screen metronome, or plays with a plain line per bar
+// when stdout is not a terminal that can show it
+func runTUI(cmd *command, args []string) int {
+	fs := cmd.flags()
+	noAltScreen := fs.Bool("no-alt-screen", false, "draw inline in the scrollback instead of taking over the screen")
+	setlistFile := fs.String("setlist", "", "setlist file to step through, starting at its first song")
+	songFile := fs.String("song", "", "song file to follow, whose sections set the meter and tempo")
+	listen := fs.String("listen", "", "also serve the HTTP control API on this address, e.g. "+remote.DefaultAddr)
+	oscOpts := addOSCFlags(fs)
+	midiOpts := addMIDIFlags(fs)
+	midiOpts.addInFlag(fs)
+	syncOpts := addSyncFlags(fs)
+	controlOpts := addControlFlags(fs)
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return cmd.usageError(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+	}
+	if err := syncOpts.check(midiOpts); err != nil {
+		return cmd.usageError(err)
+	}
+
+	if !isTerminal(os.Stdout) {
+		// The line renderer only plays the saved settings
+		var unsupported []string
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "no-alt-screen" {
+				unsupported = append(unsupported, "--"+f.Name)
+			}
+		})
+		if len(unsupported) > 0 {
+			return cmd.usageError(fmt.Errorf("%s need a terminal; without one, use 'metrognome serve' or 'metrognome play'",
+				strings.Join(unsupported, ", ")))
+		}
+		return runLines(cmd)
+	}
+
+	fmt.Println("🎩 Welcome to Metrognome - Where Every Beat is Garden Fresh! 🌱")
+	fmt.Println()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return cmd.fail(fmt.Errorf("could not read the garden settings: %w", err))
+	}
+
+	var opts []tea.ProgramOption
+	if !*noAltScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	model, err := ui.NewModel(cfg)
+	if err != nil {
+		path, _ := config.Path()
+		return cmd.fail(fmt.Errorf("%s: %w", path, err))
+	}
+	if *setlistFile != "" {
+		list, err := setlist.Load(*setlistFile)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		if model, err = model.WithSetlist(list); err != nil {
+			return cmd.fail(fmt.Errorf("%s: %w", *setlistFile, err))
+		}
+	}
+	if *songFile != "" {
+		song, err := songtext.Load(*songFile)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		if model, err = model.WithSong(song); err != nil {
+			return cmd.fail(fmt.Errorf("%s: %w", *songFile, err))
+		}
+	}
+
+	p := tea.NewProgram(model, opts...)
+	if *listen != "" {
+		api := remote.New(model.Metronome())
+		api.OnChange = func(change string) {
+			p.Send(ui.RemoteMsg{Change: change})
+		}
+		_, shutdown, err := serveRemote(api, *listen)
+		if err != nil {
+			return cmd.fail(err)
+		}
+		defer shutdown()
+	}
+	stopOSC, err := oscOpts.start(model.Metronome(), func(change string) {
+		p.Send(ui.RemoteMsg{Change: change})
+	}, func(err error) {
+		p.Send(ui.RemoteMsg{Err: fmt.Errorf("osc: %w", err)})
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopOSC()
+	stopMIDI, err := midiOpts.start(model.Metronome(), func(err error) {
+		p.Send(ui.RemoteMsg{Err: err})
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopMIDI()
+	stopSync, err := syncOpts.start(model.Metronome(), func(change string) {
+		p.Send(ui.RemoteMsg{Change: change})
+	}, func(err error) {
+		p.Send(ui.RemoteMsg{Err: err})
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopSync()
+	stopHooks, err := startHooks(model.Metronome(), func(err error) {
+		p.Send(ui.RemoteMsg{Err: err})
+	})
+	if err != nil {
+		return cmd.fail(err)
+	}
+	defer stopHooks()
+	stopControl, err := controlOpts.start(model.Metronome(), func(change string) {
+		p.Send(ui.RemoteMsg{Change: change})
+	})
+	if err != nil {
+		// Another metronome may have the socket already; carry on without
+		go p.Send(ui.RemoteMsg{Err: fmt.Errorf("control socket: %w", err)})
+	} else {
+		defer stopControl()
+	}
+
+	final, err := p.Run()
+	if err != nil {
+		return cmd.fail(fmt.Errorf("could not start the garden metronome: %w", err))
+	}
+
+	if m, ok := final.(ui.Model); ok && cfg.SaveOnQuit {
+		if err := config.Save(m.Config()); err != nil {
+			return cmd.fail(fmt.Errorf("could not save the garden settings: %w", err))
+		}
+	}
+	return exitOK
+}
+
+// runLines plays the metronome with one plain line of output per bar, for
+// pipes, CI logs and dumb consoles
+func runLines(cmd *command) int {
+	cfg, err := config.Load()
+	if err != nil {
+		return cmd.fail(fmt.Errorf("could not read the garden settings: %w", err))
+	}
+
+	metro := metronome.New(cfg.BPM, cfg.Meter())
+	metro.SetSubdivision(cfg.Subdivision)
+	metro.SetCountIn(cfg.CountIn)
+	sound := &soundFlags{voice: cfg.Voice}
+	if cfg.Kit != "" {
+		root, err := audio.KitsDir()
+		if err != nil {
+			return cmd.fail(err)
+		}
+		sound.kit = filepath.Join(root, cfg.Kit)
+	}
+
+	opts := playOptions{stream: stream.NewWriter(os.Stdout, stream.FormatBars)}
+	return playback(cmd, metro, sound, !cfg.Sound, opts, os.Stderr)
+}
+
+// isTerminal reports whether f is a terminal that can draw the full-screen
+// interface
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd())) && os.Getenv("TERM") != "dumb"
+}
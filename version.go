@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = ""
+
+// runVersion prints the version
+func runVersion(cmd *command, args []string) int {
+	fs := cmd.flags()
+	if code, ok := cmd.parse(fs, args); !ok {
+		return code
+	}
+
+	v := version
+	if v == "" {
+		// Fall back to the module version recorded by go install
+		v = "dev"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+	}
+	fmt.Printf("metrognome %s\n", v)
+	return exitOK
+}